@@ -0,0 +1,209 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Direction of a captured packet relative to the local game
+type Direction uint8
+
+const (
+	Inbound Direction = iota + 1
+	Outbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Inbound:
+		return "inbound"
+	case Outbound:
+		return "outbound"
+	default:
+		return fmt.Sprintf("Direction(%d)", uint8(d))
+	}
+}
+
+// FileExtension is the extension given to capture files created by Create
+const FileExtension = ".o2cap"
+
+// fileMagic starts every capture file; the last byte is the file format version
+var fileMagic = [8]byte{'O', '2', 'C', 'A', 'P', 0, 0, 1}
+
+// maxRecordSize guards against allocating huge buffers when reading a corrupt capture
+const maxRecordSize = 1 << 20
+
+var ErrBadMagic = errors.New("capture: not a capture file")
+var ErrRecordTooLarge = errors.New("capture: record too large")
+
+// Record is a single captured packet. Each record is stored as its timestamp (int64 nanoseconds since the Unix
+// epoch), its direction (uint8), its size (uint32) and then its data; all little-endian.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Data      []byte
+}
+
+// Writer appends records to a capture. It is safe for concurrent use.
+type Writer struct {
+	lock sync.Mutex
+	bw   *bufio.Writer
+	c    io.Closer
+}
+
+// NewWriter writes the capture file header to w and returns a Writer for appending records to it
+func NewWriter(w io.Writer) (cw *Writer, err error) {
+	cw = &Writer{bw: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		cw.c = c
+	}
+
+	if _, err = cw.bw.Write(fileMagic[:]); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Create creates a new capture file in dir named after prefix and the local time now
+func Create(dir string, prefix string, now time.Time) (cw *Writer, path string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	path = filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, now.Format("20060102-150405"), FileExtension))
+	var f *os.File
+	f, err = os.Create(path)
+	if err != nil {
+		return
+	}
+
+	cw, err = NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+	}
+	return
+}
+
+// Write appends a record; records are buffered until Flush or Close is called
+func (w *Writer) Write(direction Direction, t time.Time, data []byte) (err error) {
+	var hdr [13]byte
+	binary.LittleEndian.PutUint64(hdr[0:8], uint64(t.UnixNano()))
+	hdr[8] = uint8(direction)
+	binary.LittleEndian.PutUint32(hdr[9:13], uint32(len(data)))
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, err = w.bw.Write(hdr[:]); err != nil {
+		return
+	}
+	_, err = w.bw.Write(data)
+	return
+}
+
+func (w *Writer) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.bw.Flush()
+}
+
+// Close flushes buffered records and closes the underlying writer if it is an io.Closer
+func (w *Writer) Close() (err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	err = w.bw.Flush()
+	if w.c != nil {
+		if cerr := w.c.Close(); err == nil {
+			err = cerr
+		}
+		w.c = nil
+	}
+	return
+}
+
+// Reader reads records from a capture
+type Reader struct {
+	br *bufio.Reader
+}
+
+// NewReader verifies the capture file header read from r and returns a Reader for its records
+func NewReader(r io.Reader) (cr *Reader, err error) {
+	cr = &Reader{br: bufio.NewReader(r)}
+
+	var magic [8]byte
+	if _, err = io.ReadFull(cr.br, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != fileMagic {
+		return nil, ErrBadMagic
+	}
+	return
+}
+
+// Next reads the next record and returns io.EOF when there are no more records
+func (r *Reader) Next() (rec Record, err error) {
+	var hdr [13]byte
+	if _, err = io.ReadFull(r.br, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("capture: truncated record header: %w", err)
+		}
+		return
+	}
+
+	size := binary.LittleEndian.Uint32(hdr[9:13])
+	if size > maxRecordSize {
+		err = ErrRecordTooLarge
+		return
+	}
+
+	rec.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(hdr[0:8])))
+	rec.Direction = Direction(hdr[8])
+	rec.Data = make([]byte, size)
+	if _, err = io.ReadFull(r.br, rec.Data); err != nil {
+		err = fmt.Errorf("capture: truncated record: %w", err)
+	}
+	return
+}
+
+// ReadAll reads all records from a capture
+func ReadAll(r io.Reader) (records []Record, err error) {
+	var cr *Reader
+	cr, err = NewReader(r)
+	if err != nil {
+		return
+	}
+
+	for {
+		var rec Record
+		rec, err = cr.Next()
+		if errors.Is(err, io.EOF) {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		records = append(records, rec)
+	}
+}
+
+// ReadFile reads all records from the capture file at path
+func ReadFile(path string) (records []Record, err error) {
+	var f *os.File
+	f, err = os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	return ReadAll(f)
+}
@@ -0,0 +1,114 @@
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	now := time.Unix(1600000000, 123456789)
+	want := []Record{
+		{Time: now, Direction: Outbound, Data: []byte{0x1f, 0x65, 0x03, 0x01}},
+		{Time: now.Add(17 * time.Millisecond), Direction: Inbound, Data: []byte{0x1f, 0x65, 0x03}},
+		{Time: now.Add(34 * time.Millisecond), Direction: Inbound, Data: []byte{}},
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range want {
+		if err = w.Write(rec.Direction, rec.Time, rec.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Direction != want[i].Direction || !bytes.Equal(got[i].Data, want[i].Data) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestReadAll_Errors(t *testing.T) {
+	if _, err := ReadAll(bytes.NewReader([]byte("not a capture file"))); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("expected ErrBadMagic, got %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	w, _ := NewWriter(buf)
+	_ = w.Write(Inbound, time.Now(), []byte{1, 2, 3, 4})
+	_ = w.Flush()
+	truncated := buf.Bytes()[:buf.Len()-1]
+	if _, err := ReadAll(bytes.NewReader(truncated)); err == nil {
+		t.Error("expected an error for a truncated record")
+	}
+}
+
+func TestReplayClient(t *testing.T) {
+	now := time.Now()
+	records := []Record{
+		{Time: now, Direction: Inbound, Data: []byte{1}},
+		{Time: now.Add(time.Millisecond), Direction: Outbound, Data: []byte{2}},
+		{Time: now.Add(2 * time.Millisecond), Direction: Inbound, Data: []byte{3}},
+	}
+
+	t.Run("Step", func(t *testing.T) {
+		c := NewReplayClient("test", records)
+		defer c.Close()
+
+		if expected, actual := 2, c.Remaining(); expected != actual {
+			t.Fatalf("expected %d remaining, got %d", expected, actual)
+		}
+
+		// writes are discarded:
+		c.Write() <- []byte{0xFF}
+
+		var got [][]byte
+		for c.Step() {
+			got = append(got, <-c.Read())
+		}
+		if expected := [][]byte{{1}, {3}}; !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+		if !c.IsConnected() {
+			t.Error("expected client to remain connected after stepping")
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		c := NewReplayClient("test", records)
+		defer c.Close()
+		c.Speed = 0
+
+		c.Run()
+
+		var got [][]byte
+		for b := range c.Read() {
+			if b == nil {
+				break
+			}
+			got = append(got, b)
+		}
+		if expected := [][]byte{{1}, {3}}; !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+		if c.IsConnected() {
+			t.Error("expected client to be disconnected after the replay")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package capture
+
+import (
+	"o2/util"
+	"sync"
+	"time"
+)
+
+// ReplayClient implements games.Client by feeding the inbound packets of a capture back to its reader. Packets
+// written to it are discarded. Use Run to replay at the recorded timing or Step to replay deterministically.
+type ReplayClient struct {
+	group [20]byte
+
+	// Speed scales the recorded timing used by Run; 2 replays twice as fast. Zero or less replays without delays.
+	Speed float64
+
+	lock        sync.Mutex
+	inbound     []Record
+	next        int
+	isConnected bool
+
+	read  chan []byte
+	write chan []byte
+	stop  chan struct{}
+}
+
+// NewReplayClient creates a connected ReplayClient in the given group for the inbound records of a capture
+func NewReplayClient(group string, records []Record) *ReplayClient {
+	c := &ReplayClient{
+		Speed:       1,
+		inbound:     make([]Record, 0, len(records)),
+		isConnected: true,
+		read:        make(chan []byte, 64),
+		write:       make(chan []byte, 64),
+		stop:        make(chan struct{}),
+	}
+
+	n := copy(c.group[:], group)
+	for ; n < 20; n++ {
+		c.group[n] = ' '
+	}
+
+	for _, rec := range records {
+		if rec.Direction != Inbound {
+			continue
+		}
+		c.inbound = append(c.inbound, rec)
+	}
+
+	go c.discardWrites()
+
+	return c
+}
+
+func (c *ReplayClient) Group() []byte { return c.group[:] }
+
+func (c *ReplayClient) IsConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.isConnected
+}
+
+func (c *ReplayClient) Write() chan<- []byte { return c.write }
+func (c *ReplayClient) Read() <-chan []byte  { return c.read }
+
+// Remaining returns the number of inbound packets not yet replayed
+func (c *ReplayClient) Remaining() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.inbound) - c.next
+}
+
+// Step immediately delivers the next inbound packet to the reader and returns false when the capture is exhausted
+func (c *ReplayClient) Step() bool {
+	c.lock.Lock()
+	if c.next >= len(c.inbound) {
+		c.lock.Unlock()
+		return false
+	}
+	rec := c.inbound[c.next]
+	c.next++
+	c.lock.Unlock()
+
+	c.read <- rec.Data
+	return true
+}
+
+// Run delivers the remaining inbound packets at their recorded timing relative to the first remaining packet and
+// then signals a disconnect to the reader. Run returns early if Close is called.
+func (c *ReplayClient) Run() {
+	c.lock.Lock()
+	remaining := c.inbound[c.next:]
+	c.lock.Unlock()
+	if len(remaining) == 0 {
+		c.disconnect()
+		return
+	}
+
+	start := time.Now()
+	first := remaining[0].Time
+	for range remaining {
+		c.lock.Lock()
+		rec := c.inbound[c.next]
+		c.lock.Unlock()
+
+		if c.Speed > 0 {
+			due := start.Add(time.Duration(float64(rec.Time.Sub(first)) / c.Speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-c.stop:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		c.Step()
+	}
+
+	c.disconnect()
+}
+
+// Close stops Run and discarding written packets
+func (c *ReplayClient) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+}
+
+func (c *ReplayClient) disconnect() {
+	c.lock.Lock()
+	if !c.isConnected {
+		c.lock.Unlock()
+		return
+	}
+	c.isConnected = false
+	c.lock.Unlock()
+
+	// signal a disconnect took place:
+	c.read <- nil
+}
+
+// must run in a goroutine
+func (c *ReplayClient) discardWrites() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	for {
+		select {
+		case <-c.write:
+		case <-c.stop:
+			return
+		}
+	}
+}
@@ -1,23 +1,67 @@
 package client
 
 import (
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"io"
 	"log"
-	"o2/udpclient"
+	"o2/client/protocol03"
+	"o2/util"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrUnauthenticatedProtocol = errors.New("client: protocol does not support authentication")
+
+// Version is the application version announced to peers; set by the application at startup
+var Version = "v0.0.0"
+
 type Client struct {
-	udpclient.UDPClient
+	// transport carries packets to and from the server; nil until connected:
+	transportLock sync.Mutex
+	transport     Transport
+
+	// MinBackoff and MaxBackoff bound the delay between reconnect attempts:
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnStateChanged, if set, is called whenever the connection state changes:
+	OnStateChanged func(state ConnectionState, retryIn time.Duration)
+
+	// connection supervision state:
+	stateLock sync.Mutex
+	state     ConnectionState
+	retryIn   time.Duration
+	scheme    string
+	hostPort  string
+	stop      chan struct{}
 
 	// model state:
 	group    [20]byte
 	hostName string
+
+	// group key used to sign and verify protocol 03 messages; nil when no group secret is set:
+	keyLock     sync.Mutex
+	groupSecret string
+	key         []byte
+
+	droppedPackets uint64
+
+	read  chan []byte
+	write chan []byte
 }
 
 func NewClient() *Client {
 	c := &Client{
-		group: [20]byte{},
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		group:      [20]byte{},
+		read:       make(chan []byte, 64),
+		write:      make(chan []byte, 64),
 	}
-	udpclient.MakeUDPClient("client", &c.UDPClient)
+
+	go c.writeLoop()
+
 	return c
 }
 
@@ -28,7 +72,160 @@ func (c *Client) SetGroup(group string) {
 		c.group[n] = ' '
 	}
 	log.Printf("client: actual group name '%s'\n", c.group[:])
+
+	c.deriveKey()
 }
 
 func (c *Client) SetHostName(hostName string) { c.hostName = hostName }
 func (c *Client) HostName() string            { return c.hostName }
+
+// SetGroupSecret sets the shared secret of the group; when set, all sent messages are signed and received messages
+// that fail verification are dropped. An empty secret disables authentication.
+func (c *Client) SetGroupSecret(secret string) {
+	c.keyLock.Lock()
+	c.groupSecret = secret
+	c.keyLock.Unlock()
+
+	c.deriveKey()
+}
+
+func (c *Client) deriveKey() {
+	c.keyLock.Lock()
+	defer c.keyLock.Unlock()
+
+	if c.groupSecret == "" {
+		c.key = nil
+		return
+	}
+	c.key = protocol03.DeriveKey(c.groupSecret, string(c.group[:]))
+}
+
+func (c *Client) groupKey() []byte {
+	c.keyLock.Lock()
+	defer c.keyLock.Unlock()
+	return c.key
+}
+
+// DroppedPackets returns the number of received packets dropped for failing authentication
+func (c *Client) DroppedPackets() uint64 { return atomic.LoadUint64(&c.droppedPackets) }
+
+func (c *Client) Write() chan<- []byte { return c.write }
+func (c *Client) Read() <-chan []byte  { return c.read }
+
+func (c *Client) Close() {
+	c.Disconnect()
+	if c.write != nil {
+		close(c.write)
+	}
+	c.write = nil
+}
+
+// readLoop relays packets read from the transport until it signals a disconnect; must run in a goroutine
+func (c *Client) readLoop(t Transport) {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	for b := range t.Read() {
+		if b == nil {
+			// signal a disconnect took place:
+			c.read <- nil
+			c.transportLost(t)
+			return
+		}
+
+		if key := c.groupKey(); key != nil {
+			if err := verify(b, key); err != nil {
+				n := atomic.AddUint64(&c.droppedPackets, 1)
+				if n&255 == 1 {
+					log.Printf("client: dropped unauthenticated packet: %v; %d dropped so far\n", err, n)
+				}
+				continue
+			}
+		}
+
+		c.read <- b
+	}
+}
+
+// must run in a goroutine
+func (c *Client) writeLoop() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	for b := range c.write {
+		if b != nil {
+			if key := c.groupKey(); key != nil {
+				var err error
+				b, err = sign(b, key)
+				if err != nil {
+					log.Printf("client: sign: %v\n", err)
+					continue
+				}
+			}
+		}
+
+		t := c.currentTransport()
+		if t == nil || !t.IsConnected() {
+			// drop packets written while disconnected:
+			continue
+		}
+		t.Write() <- b
+	}
+}
+
+func parseGroupMessage(b []byte) (gm *protocol03.GroupMessage, protocol uint8, err error) {
+	var r io.Reader
+	r, err = ParseHeader(b, &protocol)
+	if err != nil {
+		return
+	}
+	if protocol != 0x03 {
+		return
+	}
+
+	var pb []byte
+	pb, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	gm = &protocol03.GroupMessage{}
+	err = proto.Unmarshal(pb, gm)
+	return
+}
+
+func sign(b []byte, key []byte) ([]byte, error) {
+	gm, protocol, err := parseGroupMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	if protocol != 0x03 {
+		// only protocol 03 messages can be signed:
+		return b, nil
+	}
+
+	if err = protocol03.Sign(gm, key); err != nil {
+		return nil, err
+	}
+
+	pkt := MakePacket(0x03)
+	return proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), gm)
+}
+
+func verify(b []byte, key []byte) error {
+	gm, protocol, err := parseGroupMessage(b)
+	if err != nil {
+		return err
+	}
+	if protocol != 0x03 {
+		return ErrUnauthenticatedProtocol
+	}
+
+	return protocol03.Verify(gm, key)
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"o2/client/protocol03"
+	"testing"
+)
+
+func testPacket(t testing.TB, gm *protocol03.GroupMessage) []byte {
+	b, err := proto.MarshalOptions{}.MarshalAppend(MakePacket(0x03).Bytes(), gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// relay emulates the server stamping the sender's player index and server time on the message
+func relay(t testing.TB, b []byte, index uint32) []byte {
+	gm, _, err := parseGroupMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.PlayerIndex = index
+	gm.ServerTime = 1234
+	return testPacket(t, gm)
+}
+
+func TestSignVerify(t *testing.T) {
+	key := protocol03.DeriveKey("hunter2", "group               ")
+	if other := protocol03.DeriveKey("hunter2", "GROUP"); string(other) != string(key) {
+		t.Fatal("key must be derived from the normalized group name")
+	}
+
+	b := testPacket(t, &protocol03.GroupMessage{
+		Group:        "group               ",
+		PlayerTime:   5678,
+		BroadcastAll: &protocol03.BroadcastAll{Data: []byte{0x14, 0, 1}},
+	})
+
+	signed, err := sign(b, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = verify(relay(t, signed, 3), key); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if err = verify(relay(t, b, 3), key); !errors.Is(err, protocol03.ErrMissingHMAC) {
+		t.Errorf("unsigned: expected ErrMissingHMAC; got %v", err)
+	}
+	if err = verify(relay(t, signed, 3), protocol03.DeriveKey("wrong", "group")); !errors.Is(err, protocol03.ErrBadHMAC) {
+		t.Errorf("wrong secret: expected ErrBadHMAC; got %v", err)
+	}
+	if err = verify(relay(t, signed, 3), protocol03.DeriveKey("hunter2", "other")); !errors.Is(err, protocol03.ErrBadHMAC) {
+		t.Errorf("wrong group: expected ErrBadHMAC; got %v", err)
+	}
+
+	// tamper with the payload:
+	gm, _, err := parseGroupMessage(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm.GetBroadcastAll().Data[2] = 2
+	if err = verify(testPacket(t, gm), key); !errors.Is(err, protocol03.ErrBadHMAC) {
+		t.Errorf("tampered: expected ErrBadHMAC; got %v", err)
+	}
+
+	// older protocols cannot be authenticated:
+	if err = verify(MakePacket(0x02).Bytes(), key); !errors.Is(err, ErrUnauthenticatedProtocol) {
+		t.Errorf("protocol 02: expected ErrUnauthenticatedProtocol; got %v", err)
+	}
+}
@@ -0,0 +1,612 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log"
+	"net"
+	"o2/client/protocol03"
+	"o2/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLANGroupAddress is the multicast address LAN peers meet on when a lan:// server URL gives no host
+const DefaultLANGroupAddress = "239.255.79.50"
+
+const (
+	// DefaultLANDiscoveryTime is how long to listen for peers before claiming a player index
+	DefaultLANDiscoveryTime = time.Second
+	// DefaultLANPeerTimeout is how long a silent peer keeps its claim on a player index
+	DefaultLANPeerTimeout = 5 * time.Second
+
+	// lanAnnounceInterval is how often each peer announces its claimed player index
+	lanAnnounceInterval = 250 * time.Millisecond
+	// lanMaxPlayers matches the player index limit of the server
+	lanMaxPlayers = 256
+	// lanMaxDatagram bounds the size of a received datagram
+	lanMaxDatagram = 65536
+)
+
+// lanMagic starts every LAN datagram; the last byte is the datagram format version
+var lanMagic = [4]byte{'O', '2', 'L', 2}
+
+// LAN datagram kinds:
+const (
+	// lanAnnounce carries the sender's group, claimed player index and group clock
+	lanAnnounce uint8 = iota + 1
+	// lanPacket carries a protocol 03 packet
+	lanPacket
+)
+
+var ErrBadLANDatagram = errors.New("lan: bad datagram")
+
+// lanConn sends datagrams to every LAN peer and receives theirs
+type lanConn interface {
+	WriteMulticast(b []byte) error
+	ReadFrom(b []byte) (n int, err error)
+	Close() error
+}
+
+type lanPeer struct {
+	// index is the claimed player index or -1 if none is claimed yet
+	index    int
+	lastSeen time.Time
+}
+
+// LAN is a serverless Transport that exchanges protocol 03 GroupMessages directly with peers on the local network
+// by UDP multicast. Each peer plays the part of the server for its own player: it negotiates a player index with
+// the other peers in the same group, answers JoinGroup and Echo messages itself and routes broadcasts and acks the
+// same way the server would.
+//
+// There is no server clock to stamp ServerTime with, so the peers share a group clock instead: of the peers holding a
+// player index, the one with the lowest id is the timebase, and every other peer offsets its own clock to match the
+// group clock the timebase announces. A joining peer follows the group clock before it claims an index and a peer
+// keeps its offset when the timebase leaves, so a new timebase carries on with the same clock.
+//
+// Every datagram starts with lanMagic, the datagram kind (uint8) and the sender's random peer id (uint64); all
+// little-endian. Announcements follow with the normalized group name (uint8 length and bytes), the claimed player
+// index (uint16, 0xFFFF for none) and the sender's group clock (int64 unix nanoseconds). Packets follow with the
+// protocol 03 packet as written by the game.
+type LAN struct {
+	// Now returns the current time; defaults to time.Now
+	Now func() time.Time
+	// DiscoveryTime defaults to DefaultLANDiscoveryTime
+	DiscoveryTime time.Duration
+	// PeerTimeout defaults to DefaultLANPeerTimeout
+	PeerTimeout time.Duration
+
+	conn lanConn
+	id   uint64
+
+	lock        sync.Mutex
+	isConnected bool
+	started     time.Time
+	// normalized group name learned from the messages written by the game:
+	group  string
+	sector uint64
+	// claimed player index or -1:
+	index int
+	// offset from our clock to the group clock of the timebase peer:
+	clockOffset time.Duration
+	// last JoinGroup written by the game; echoed back once an index is claimed:
+	join  *protocol03.GroupMessage
+	peers map[uint64]*lanPeer
+
+	read  chan []byte
+	write chan []byte
+	stop  chan struct{}
+}
+
+// ListenLAN joins the multicast group at hostPort and starts exchanging messages with the peers found there
+func ListenLAN(hostPort string) (l *LAN, err error) {
+	var conn *udpMulticastConn
+	conn, err = listenUDPMulticast(hostPort)
+	if err != nil {
+		return
+	}
+
+	l = newLAN(conn)
+	l.start()
+	return
+}
+
+func newLAN(conn lanConn) *LAN {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	return &LAN{
+		Now:           time.Now,
+		DiscoveryTime: DefaultLANDiscoveryTime,
+		PeerTimeout:   DefaultLANPeerTimeout,
+		conn:          conn,
+		id:            binary.LittleEndian.Uint64(id[:]),
+		isConnected:   true,
+		index:         -1,
+		peers:         make(map[uint64]*lanPeer),
+		read:          make(chan []byte, 64),
+		write:         make(chan []byte, 64),
+		stop:          make(chan struct{}),
+	}
+}
+
+func (l *LAN) start() {
+	l.started = l.now()
+
+	go l.writeLoop()
+	go l.readLoop()
+	go l.tickLoop()
+}
+
+func (l *LAN) now() time.Time {
+	if l.Now == nil {
+		return time.Now()
+	}
+	return l.Now()
+}
+
+func (l *LAN) IsConnected() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.isConnected
+}
+
+func (l *LAN) Write() chan<- []byte { return l.write }
+func (l *LAN) Read() <-chan []byte  { return l.read }
+
+// Index returns the claimed player index or -1 if none is claimed yet
+func (l *LAN) Index() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.index
+}
+
+func (l *LAN) Disconnect() {
+	l.lock.Lock()
+	if !l.isConnected {
+		l.lock.Unlock()
+		return
+	}
+	l.isConnected = false
+	close(l.stop)
+	l.lock.Unlock()
+
+	_ = l.conn.Close()
+
+	// signal a disconnect took place:
+	l.read <- nil
+}
+
+// must run in a goroutine
+func (l *LAN) writeLoop() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	for {
+		select {
+		case b := <-l.write:
+			if b == nil {
+				continue
+			}
+			l.handleWrite(b, l.now())
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// must run in a goroutine
+func (l *LAN) readLoop() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	buf := make([]byte, lanMaxDatagram)
+	for {
+		n, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if l.IsConnected() {
+				log.Printf("lan: read: %v\n", err)
+				l.Disconnect()
+			}
+			return
+		}
+
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		if err = l.handleDatagram(b, l.now()); err != nil {
+			log.Printf("lan: %v\n", err)
+		}
+	}
+}
+
+// must run in a goroutine
+func (l *LAN) tickLoop() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	t := time.NewTicker(lanAnnounceInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			l.tick(l.now())
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// groupNow returns the group clock at our local time now; must be called under lock
+func (l *LAN) groupNow(now time.Time) time.Time {
+	return now.Add(l.clockOffset)
+}
+
+// isTimebase reports whether id is the lowest id of the peers in the group that hold a player index, ourselves
+// included; must be called under lock
+func (l *LAN) isTimebase(id uint64) bool {
+	if p := l.peers[id]; p == nil || p.index < 0 {
+		return false
+	}
+	if l.index >= 0 && l.id < id {
+		return false
+	}
+	for pid, p := range l.peers {
+		if p.index >= 0 && pid < id {
+			return false
+		}
+	}
+	return true
+}
+
+// lanGroupKey normalizes a group name the same way as server.GroupKey
+func lanGroupKey(group string) string {
+	return strings.ToLower(strings.Trim(group, " \t\r\n\000"))
+}
+
+// handleWrite answers or sends a packet written by the game
+func (l *LAN) handleWrite(b []byte, now time.Time) {
+	gm, protocol, err := parseGroupMessage(b)
+	if err != nil {
+		log.Printf("lan: write: %v\n", err)
+		return
+	}
+	if protocol != 0x03 {
+		// only protocol 03 is supported between peers:
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.group = lanGroupKey(gm.GetGroup())
+	if protocol03.ReportsSector(gm) {
+		l.sector = gm.GetPlayerInSector()
+	}
+
+	if gm.GetJoinGroup() != nil {
+		l.join = gm
+		if l.index < 0 {
+			l.tryClaim(now)
+		}
+		if l.index >= 0 {
+			l.replyJoin(now)
+		}
+		return
+	}
+
+	if l.index < 0 {
+		// not joined yet:
+		return
+	}
+
+	gm.PlayerIndex = uint32(l.index)
+	gm.ServerTime = l.groupNow(now).UnixNano()
+
+	if gm.GetEcho() != nil {
+		// we are our own server so echo straight back; ServerTime is already on the group clock:
+		l.deliver(gm)
+		return
+	}
+
+	var pkt []byte
+	pkt, err = marshalGroupMessage(gm)
+	if err != nil {
+		log.Printf("lan: write: %v\n", err)
+		return
+	}
+	l.send(lanPacket, pkt)
+}
+
+// handleDatagram handles a datagram received from a peer
+func (l *LAN) handleDatagram(b []byte, now time.Time) (err error) {
+	r := bytes.NewReader(b)
+
+	var hdr struct {
+		Magic [4]byte
+		Kind  uint8
+		ID    uint64
+	}
+	if err = binary.Read(r, binary.LittleEndian, &hdr); err != nil || hdr.Magic != lanMagic {
+		return ErrBadLANDatagram
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if hdr.ID == l.id {
+		// our own multicast looped back:
+		return
+	}
+
+	switch hdr.Kind {
+	case lanAnnounce:
+		var n uint8
+		if n, err = r.ReadByte(); err != nil {
+			return ErrBadLANDatagram
+		}
+		group := make([]byte, n)
+		var index uint16
+		var clock int64
+		if _, err = io.ReadFull(r, group); err != nil {
+			return ErrBadLANDatagram
+		}
+		if err = binary.Read(r, binary.LittleEndian, &index); err != nil {
+			return ErrBadLANDatagram
+		}
+		if err = binary.Read(r, binary.LittleEndian, &clock); err != nil {
+			return ErrBadLANDatagram
+		}
+		if string(group) != l.group {
+			return
+		}
+
+		p := l.peer(hdr.ID, now)
+		p.index = -1
+		if index < lanMaxPlayers {
+			p.index = int(index)
+		}
+
+		if l.isTimebase(hdr.ID) {
+			// follow the timebase's group clock; LAN latency is small enough to ignore:
+			l.clockOffset = time.Unix(0, clock).Sub(now)
+		}
+
+		if p.index >= 0 && p.index == l.index && hdr.ID < l.id {
+			// the peer with the lowest id keeps a contested index:
+			log.Printf("lan: player index %d is claimed by another peer; claiming a new index\n", l.index)
+			l.index = -1
+			l.tryClaim(now)
+			if l.index >= 0 && l.join != nil {
+				// tell the game its new index:
+				l.replyJoin(now)
+			}
+		}
+	case lanPacket:
+		var protocol uint8
+		var gm *protocol03.GroupMessage
+		gm, protocol, err = parseGroupMessage(b[len(b)-r.Len():])
+		if err != nil {
+			return
+		}
+		if protocol != 0x03 || lanGroupKey(gm.GetGroup()) != l.group {
+			return
+		}
+
+		l.peer(hdr.ID, now).index = int(gm.GetPlayerIndex())
+		if l.index < 0 {
+			return
+		}
+
+		// route the same way the server would:
+		if gm.GetBroadcastAll() != nil || gm.GetChat() != nil {
+			l.deliver(gm)
+		} else if bs := gm.GetBroadcastSector(); bs != nil {
+			if bs.GetTargetSector() == l.sector {
+				l.deliver(gm)
+			}
+		} else if ack := gm.GetAck(); ack != nil {
+			if int(ack.GetTargetPlayerIndex()) == l.index {
+				l.deliver(gm)
+			}
+		}
+	default:
+		return ErrBadLANDatagram
+	}
+
+	return
+}
+
+// tick announces our claim, expires silent peers and answers a pending JoinGroup once discovery is over
+func (l *LAN) tick(now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	timeout := l.PeerTimeout
+	if timeout <= 0 {
+		timeout = DefaultLANPeerTimeout
+	}
+	for id, p := range l.peers {
+		if now.Sub(p.lastSeen) >= timeout {
+			delete(l.peers, id)
+		}
+	}
+
+	if l.index < 0 && l.join != nil {
+		l.tryClaim(now)
+		if l.index >= 0 {
+			l.replyJoin(now)
+		}
+	}
+
+	if l.group != "" {
+		l.announce(now)
+	}
+}
+
+func (l *LAN) peer(id uint64, now time.Time) (p *lanPeer) {
+	p = l.peers[id]
+	if p == nil {
+		p = &lanPeer{index: -1}
+		l.peers[id] = p
+	}
+	p.lastSeen = now
+	return
+}
+
+// tryClaim claims the requested player index if it is free, otherwise the lowest free player index, once we have
+// listened for peers long enough to know which indexes are taken; must be called under lock
+func (l *LAN) tryClaim(now time.Time) {
+	discovery := l.DiscoveryTime
+	if discovery < 0 {
+		discovery = 0
+	}
+	if now.Sub(l.started) < discovery {
+		return
+	}
+
+	var taken [lanMaxPlayers]bool
+	for _, p := range l.peers {
+		if p.index >= 0 {
+			taken[p.index] = true
+		}
+	}
+
+	requested := -1
+	if l.join != nil && l.join.GetJoinGroup().RequestedPlayerIndex != nil {
+		requested = int(l.join.GetJoinGroup().GetRequestedPlayerIndex())
+	}
+	if requested >= 0 && requested < lanMaxPlayers && !taken[requested] {
+		l.index = requested
+	} else {
+		for i := range taken {
+			if !taken[i] {
+				l.index = i
+				break
+			}
+		}
+	}
+	if l.index < 0 {
+		log.Printf("lan: group '%s' is full\n", l.group)
+		return
+	}
+
+	// announce right away to settle contested claims sooner:
+	l.announce(now)
+}
+
+// replyJoin echoes the game's JoinGroup back with our claimed index; must be called under lock
+func (l *LAN) replyJoin(now time.Time) {
+	gm := proto.Clone(l.join).(*protocol03.GroupMessage)
+	gm.PlayerIndex = uint32(l.index)
+	gm.ServerTime = l.groupNow(now).UnixNano()
+	l.deliver(gm)
+}
+
+// announce must be called under lock
+func (l *LAN) announce(now time.Time) {
+	b := &bytes.Buffer{}
+	b.WriteByte(uint8(len(l.group)))
+	b.WriteString(l.group)
+	index := uint16(0xFFFF)
+	if l.index >= 0 {
+		index = uint16(l.index)
+	}
+	_ = binary.Write(b, binary.LittleEndian, index)
+	_ = binary.Write(b, binary.LittleEndian, l.groupNow(now).UnixNano())
+
+	l.send(lanAnnounce, b.Bytes())
+}
+
+// send must be called under lock
+func (l *LAN) send(kind uint8, payload []byte) {
+	b := &bytes.Buffer{}
+	b.Write(lanMagic[:])
+	b.WriteByte(kind)
+	_ = binary.Write(b, binary.LittleEndian, l.id)
+	b.Write(payload)
+
+	if err := l.conn.WriteMulticast(b.Bytes()); err != nil {
+		log.Printf("lan: write: %v\n", err)
+	}
+}
+
+// deliver passes a GroupMessage to the game; must be called under lock
+func (l *LAN) deliver(gm *protocol03.GroupMessage) {
+	b, err := marshalGroupMessage(gm)
+	if err != nil {
+		log.Printf("lan: deliver: %v\n", err)
+		return
+	}
+
+	select {
+	case l.read <- b:
+	default:
+		// like UDP, drop the packet if the reader isn't keeping up:
+	}
+}
+
+func marshalGroupMessage(gm *protocol03.GroupMessage) ([]byte, error) {
+	pkt := MakePacket(0x03)
+	return proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), gm)
+}
+
+// udpMulticastConn receives on a multicast group and sends to it from a separate socket so that peers on the same
+// host receive each other's datagrams
+type udpMulticastConn struct {
+	recv *net.UDPConn
+	send *net.UDPConn
+}
+
+func listenUDPMulticast(hostPort string) (c *udpMulticastConn, err error) {
+	var addr *net.UDPAddr
+	addr, err = net.ResolveUDPAddr("udp4", hostPort)
+	if err != nil {
+		return
+	}
+	if !addr.IP.IsMulticast() {
+		err = errors.New("lan: " + addr.IP.String() + " is not a multicast address")
+		return
+	}
+
+	c = &udpMulticastConn{}
+	c.recv, err = net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.send, err = net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		_ = c.recv.Close()
+		return nil, err
+	}
+	return
+}
+
+func (c *udpMulticastConn) WriteMulticast(b []byte) (err error) {
+	_, err = c.send.Write(b)
+	return
+}
+
+func (c *udpMulticastConn) ReadFrom(b []byte) (n int, err error) {
+	n, _, err = c.recv.ReadFromUDP(b)
+	return
+}
+
+func (c *udpMulticastConn) Close() error {
+	_ = c.send.Close()
+	return c.recv.Close()
+}
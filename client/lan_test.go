@@ -0,0 +1,308 @@
+package client
+
+import (
+	"o2/client/protocol03"
+	"testing"
+	"time"
+)
+
+// testLANNetwork delivers every datagram to every connection including the sender's, as multicast loopback does
+type testLANNetwork struct {
+	conns []*testLANConn
+}
+
+type testLANConn struct {
+	network *testLANNetwork
+	in      [][]byte
+}
+
+func (c *testLANConn) WriteMulticast(b []byte) error {
+	for _, o := range c.network.conns {
+		o.in = append(o.in, append([]byte(nil), b...))
+	}
+	return nil
+}
+
+func (c *testLANConn) ReadFrom(b []byte) (n int, err error) { select {} }
+func (c *testLANConn) Close() error                         { return nil }
+
+type testLANPeers struct {
+	t       *testing.T
+	network testLANNetwork
+	peers   []*LAN
+	now     time.Time
+	// skew of each peer's clock from now:
+	skew map[*LAN]time.Duration
+}
+
+func newTestLANPeers(t *testing.T, count int) *testLANPeers {
+	tp := &testLANPeers{t: t, now: time.Unix(1_600_000_000, 0), skew: make(map[*LAN]time.Duration)}
+	for i := 0; i < count; i++ {
+		conn := &testLANConn{network: &tp.network}
+		tp.network.conns = append(tp.network.conns, conn)
+
+		l := newLAN(conn)
+		// give peers a deterministic order for contested player indexes:
+		l.id = uint64(i + 1)
+		l.Now = func() time.Time { return tp.clock(l) }
+		l.started = tp.now
+		tp.peers = append(tp.peers, l)
+	}
+	return tp
+}
+
+// clock returns the time on l's clock
+func (tp *testLANPeers) clock(l *LAN) time.Time {
+	return tp.now.Add(tp.skew[l])
+}
+
+// pump delivers all datagrams sent so far
+func (tp *testLANPeers) pump() {
+	for more := true; more; {
+		more = false
+		for i, conn := range tp.network.conns {
+			in := conn.in
+			conn.in = nil
+			for _, b := range in {
+				more = true
+				if err := tp.peers[i].handleDatagram(b, tp.clock(tp.peers[i])); err != nil {
+					tp.t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func (tp *testLANPeers) tick() {
+	for _, l := range tp.peers {
+		l.tick(tp.clock(l))
+	}
+	tp.pump()
+}
+
+func (tp *testLANPeers) write(l *LAN, gm *protocol03.GroupMessage) {
+	b, err := marshalGroupMessage(gm)
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	l.handleWrite(b, tp.clock(l))
+	tp.pump()
+}
+
+// readAll returns all GroupMessages delivered to the game so far
+func (tp *testLANPeers) readAll(l *LAN) (msgs []*protocol03.GroupMessage) {
+	for {
+		select {
+		case b := <-l.Read():
+			gm, _, err := parseGroupMessage(b)
+			if err != nil {
+				tp.t.Fatal(err)
+			}
+			msgs = append(msgs, gm)
+		default:
+			return
+		}
+	}
+}
+
+// join joins every peer to group after discovery and returns the player index each was told
+func (tp *testLANPeers) join(group string) (indexes []int) {
+	for _, l := range tp.peers {
+		tp.write(l, &protocol03.GroupMessage{Group: group, JoinGroup: &protocol03.JoinGroup{}})
+		if msgs := tp.readAll(l); len(msgs) != 0 {
+			tp.t.Fatalf("expected no join reply during discovery; got %v", msgs)
+		}
+	}
+
+	tp.now = tp.now.Add(DefaultLANDiscoveryTime)
+	tp.tick()
+
+	for _, l := range tp.peers {
+		index := -1
+		for _, gm := range tp.readAll(l) {
+			if gm.GetJoinGroup() != nil {
+				index = int(gm.GetPlayerIndex())
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	return
+}
+
+func TestLAN_NegotiatesPlayerIndexes(t *testing.T) {
+	tp := newTestLANPeers(t, 3)
+
+	indexes := tp.join("group")
+
+	seen := make(map[int]bool)
+	for i, index := range indexes {
+		if index < 0 || index >= len(tp.peers) {
+			t.Fatalf("peer %d: index = %d, want 0..%d", i, index, len(tp.peers)-1)
+		}
+		if seen[index] {
+			t.Fatalf("peer %d: index %d assigned twice; indexes = %v", i, index, indexes)
+		}
+		seen[index] = true
+		if actual := tp.peers[i].Index(); actual != index {
+			t.Errorf("peer %d: Index() = %d, want last join reply %d", i, actual, index)
+		}
+	}
+
+	// a late peer requesting a taken index gets a free one instead:
+	late := newTestLANPeers(t, 1).peers[0]
+	late.id = 100
+	late.Now = func() time.Time { return tp.now }
+	late.started = tp.now
+	conn := late.conn.(*testLANConn)
+	conn.network = &tp.network
+	tp.network.conns = append(tp.network.conns, conn)
+	tp.peers = append(tp.peers, late)
+
+	requested := uint32(indexes[0])
+	tp.write(late, &protocol03.GroupMessage{Group: "GROUP ", JoinGroup: &protocol03.JoinGroup{RequestedPlayerIndex: &requested}})
+	tp.tick()
+	tp.now = tp.now.Add(DefaultLANDiscoveryTime)
+	tp.tick()
+
+	if expected, actual := 3, late.Index(); expected != actual {
+		t.Errorf("late peer: index = %d, want %d", actual, expected)
+	}
+}
+
+func TestLAN_Routing(t *testing.T) {
+	tp := newTestLANPeers(t, 3)
+	indexes := tp.join("group")
+	a, b, c := tp.peers[0], tp.peers[1], tp.peers[2]
+
+	// b is in sector 5 and c is in sector 6:
+	tp.write(b, &protocol03.GroupMessage{Group: "group", PlayerInSector: 5, BroadcastAll: &protocol03.BroadcastAll{}})
+	tp.write(c, &protocol03.GroupMessage{Group: "group", PlayerInSector: 6, BroadcastAll: &protocol03.BroadcastAll{}})
+	for _, l := range tp.peers {
+		tp.readAll(l)
+	}
+	// an echo does not carry the sender's location:
+	tp.write(c, &protocol03.GroupMessage{Group: "group", PlayerInSector: 5, Echo: &protocol03.Echo{}})
+	if msgs := tp.readAll(c); len(msgs) != 1 || msgs[0].GetEcho() == nil {
+		t.Fatalf("expected an echo reply; got %v", msgs)
+	}
+
+	tp.write(a, &protocol03.GroupMessage{Group: "group", BroadcastAll: &protocol03.BroadcastAll{Data: []byte{1}}})
+	for _, l := range []*LAN{b, c} {
+		msgs := tp.readAll(l)
+		if len(msgs) != 1 || msgs[0].GetBroadcastAll() == nil || int(msgs[0].GetPlayerIndex()) != indexes[0] {
+			t.Errorf("expected broadcast from player %d; got %v", indexes[0], msgs)
+		}
+	}
+	if msgs := tp.readAll(a); len(msgs) != 0 {
+		t.Errorf("expected sender not to receive its own broadcast; got %v", msgs)
+	}
+
+	tp.write(a, &protocol03.GroupMessage{Group: "group", Chat: &protocol03.Chat{Text: "hi"}})
+	for _, l := range []*LAN{b, c} {
+		if msgs := tp.readAll(l); len(msgs) != 1 || msgs[0].GetChat().GetText() != "hi" {
+			t.Errorf("expected chat from player %d; got %v", indexes[0], msgs)
+		}
+	}
+
+	tp.write(a, &protocol03.GroupMessage{Group: "group", BroadcastSector: &protocol03.BroadcastSector{TargetSector: 5}})
+	if msgs := tp.readAll(b); len(msgs) != 1 {
+		t.Errorf("expected sector broadcast in sector 5; got %v", msgs)
+	}
+	if msgs := tp.readAll(c); len(msgs) != 0 {
+		t.Errorf("expected no sector broadcast outside sector 5; got %v", msgs)
+	}
+
+	tp.write(a, &protocol03.GroupMessage{Group: "group", Ack: &protocol03.Ack{TargetPlayerIndex: uint32(indexes[2])}})
+	if msgs := tp.readAll(b); len(msgs) != 0 {
+		t.Errorf("expected no ack for another player; got %v", msgs)
+	}
+	if msgs := tp.readAll(c); len(msgs) != 1 || msgs[0].GetAck() == nil {
+		t.Errorf("expected ack; got %v", msgs)
+	}
+
+	// peers in other groups are not involved:
+	tp.write(a, &protocol03.GroupMessage{Group: "other", BroadcastAll: &protocol03.BroadcastAll{}})
+	for _, l := range []*LAN{b, c} {
+		if msgs := tp.readAll(l); len(msgs) != 0 {
+			t.Errorf("expected no broadcast from another group; got %v", msgs)
+		}
+	}
+}
+
+func TestLAN_ExpiresPeers(t *testing.T) {
+	tp := newTestLANPeers(t, 2)
+	tp.join("group")
+	a := tp.peers[0]
+
+	if expected, actual := 1, len(a.peers); expected != actual {
+		t.Fatalf("expected %d peer, got %d", expected, actual)
+	}
+
+	// only a keeps announcing:
+	tp.peers = tp.peers[:1]
+	tp.network.conns = tp.network.conns[:1]
+	tp.now = tp.now.Add(DefaultLANPeerTimeout)
+	tp.tick()
+
+	if expected, actual := 0, len(a.peers); expected != actual {
+		t.Errorf("expected %d peers, got %d", expected, actual)
+	}
+}
+
+func TestLAN_SharesGroupClock(t *testing.T) {
+	tp := newTestLANPeers(t, 3)
+	a, b, c := tp.peers[0], tp.peers[1], tp.peers[2]
+	tp.skew[b] = time.Hour
+	tp.skew[c] = -30 * time.Minute
+	b.started, c.started = tp.clock(b), tp.clock(c)
+	tp.join("group")
+	tp.tick()
+
+	// a has the lowest id so every peer stamps ServerTime with a's clock:
+	expectGroupClock := func(name string, l *LAN) {
+		t.Helper()
+		tp.write(l, &protocol03.GroupMessage{Group: "group", Echo: &protocol03.Echo{}})
+		msgs := tp.readAll(l)
+		if len(msgs) != 1 || msgs[0].GetEcho() == nil {
+			t.Fatalf("%s: expected an echo reply; got %v", name, msgs)
+		}
+		if expected, actual := tp.now.UnixNano(), msgs[0].GetServerTime(); expected != actual {
+			t.Errorf("%s: ServerTime = %v, want %v", name, time.Unix(0, actual), time.Unix(0, expected))
+		}
+	}
+	expectGroupClock("a", a)
+	expectGroupClock("b", b)
+	expectGroupClock("c", c)
+
+	// a late peer with the lowest id follows the group clock instead of imposing its own:
+	late := newLAN(&testLANConn{network: &tp.network})
+	late.id = 0
+	late.Now = func() time.Time { return tp.clock(late) }
+	tp.skew[late] = 2 * time.Hour
+	late.started = tp.clock(late)
+	tp.network.conns = append(tp.network.conns, late.conn.(*testLANConn))
+	tp.peers = append(tp.peers, late)
+
+	tp.write(late, &protocol03.GroupMessage{Group: "group", JoinGroup: &protocol03.JoinGroup{}})
+	tp.tick()
+	tp.now = tp.now.Add(DefaultLANDiscoveryTime)
+	tp.tick()
+	if late.Index() < 0 {
+		t.Fatal("late peer did not claim an index")
+	}
+	tp.tick()
+	tp.readAll(late)
+
+	expectGroupClock("late", late)
+	expectGroupClock("b", b)
+
+	// the group clock carries on when a leaves:
+	tp.peers = append(tp.peers[1:3:3], late)
+	tp.network.conns = append(tp.network.conns[1:3:3], late.conn.(*testLANConn))
+	tp.now = tp.now.Add(DefaultLANPeerTimeout)
+	tp.tick()
+	tp.tick()
+
+	expectGroupClock("c", c)
+	expectGroupClock("late", late)
+}
@@ -0,0 +1,215 @@
+package loopback
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"o2/server"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// chanSize is large enough that deterministic tests never drop packets between calls to Hub.Flush
+const chanSize = 256
+
+type addr int
+
+func (a addr) Network() string { return "loopback" }
+func (a addr) String() string  { return fmt.Sprintf("loopback:%d", int(a)) }
+
+// Hub is an in-process stand-in for an O2 server which hands out connected games.Client endpoints.
+// Packets written by a Client are routed by an embedded server.Server exactly as the UDP server would route them.
+type Hub struct {
+	Server *server.Server
+
+	lock    sync.Mutex
+	clients []*Client
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		Server:  server.NewServer(),
+		clients: make([]*Client, 0, 8),
+	}
+}
+
+// SetNow overrides the server's clock to allow for deterministic server timestamps
+func (h *Hub) SetNow(now func() time.Time) {
+	h.Server.Now = now
+}
+
+// NewClient creates a new connected Client joined to the given group name
+func (h *Hub) NewClient(group string) *Client {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	c := &Client{
+		hub:         h,
+		addr:        addr(len(h.clients)),
+		isConnected: true,
+		read:        make(chan []byte, chanSize),
+		write:       make(chan []byte, chanSize),
+	}
+	c.SetGroup(group)
+	h.clients = append(h.clients, c)
+
+	return c
+}
+
+// Clients returns all Clients created by this Hub in creation order
+func (h *Hub) Clients() []*Client {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return append([]*Client(nil), h.clients...)
+}
+
+// Flush routes all pending written packets, client by client in creation order, and returns the number routed.
+// Use Flush for deterministic tests; use Run for asynchronous delivery.
+func (h *Hub) Flush() (n int) {
+	for _, c := range h.Clients() {
+		for more := true; more; {
+			select {
+			case b := <-c.write:
+				if b == nil {
+					continue
+				}
+				h.route(c, b)
+				n++
+			default:
+				more = false
+			}
+		}
+	}
+	return
+}
+
+// Run routes written packets as they arrive until ctx is done. Only Clients created before Run is called are served.
+func (h *Hub) Run(ctx context.Context) {
+	clients := h.Clients()
+
+	cases := make([]reflect.SelectCase, 0, len(clients)+1)
+	for _, c := range clients {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(c.write),
+		})
+	}
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	})
+
+	for {
+		i, rcv, ok := reflect.Select(cases)
+		if i == len(clients) {
+			return
+		}
+		if !ok {
+			// stop selecting on a closed channel:
+			cases[i].Chan = reflect.ValueOf((chan []byte)(nil))
+			continue
+		}
+
+		b := rcv.Bytes()
+		if b == nil {
+			continue
+		}
+		h.route(clients[i], b)
+	}
+}
+
+func (h *Hub) route(from *Client, b []byte) {
+	if !from.IsConnected() {
+		return
+	}
+
+	out, err := h.Server.HandlePacket(from.addr, b)
+	if err != nil {
+		log.Printf("loopback: %s: %v\n", from.addr, err)
+		return
+	}
+
+	for _, o := range out {
+		a, ok := o.Addr.(addr)
+		if !ok {
+			continue
+		}
+
+		h.lock.Lock()
+		to := h.clients[a]
+		h.lock.Unlock()
+
+		to.deliver(o.Data)
+	}
+}
+
+// Client implements games.Client
+type Client struct {
+	hub  *Hub
+	addr addr
+
+	group [20]byte
+
+	lock        sync.Mutex
+	isConnected bool
+
+	read  chan []byte
+	write chan []byte
+}
+
+func (c *Client) Group() []byte { return c.group[:] }
+
+// SetGroup pads the group name with spaces the same way client.Client does
+func (c *Client) SetGroup(group string) {
+	n := copy(c.group[:], group)
+	for ; n < 20; n++ {
+		c.group[n] = ' '
+	}
+}
+
+func (c *Client) IsConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.isConnected
+}
+
+func (c *Client) Write() chan<- []byte { return c.write }
+func (c *Client) Read() <-chan []byte  { return c.read }
+
+// Disconnect removes the client from the hub's groups and signals the disconnect to the reader with a nil packet
+func (c *Client) Disconnect() {
+	c.lock.Lock()
+	if !c.isConnected {
+		c.lock.Unlock()
+		return
+	}
+	c.isConnected = false
+	c.lock.Unlock()
+
+	c.hub.Server.Leave(c.addr)
+
+	// signal a disconnect took place:
+	c.read <- nil
+}
+
+// Reconnect marks the client as connected again; it will be assigned a player index upon its next message
+func (c *Client) Reconnect() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.isConnected = true
+}
+
+func (c *Client) deliver(b []byte) {
+	if !c.IsConnected() {
+		return
+	}
+
+	select {
+	case c.read <- b:
+	default:
+		// emulate UDP and drop the packet if the reader isn't keeping up:
+		log.Printf("loopback: %s: read queue full; dropped packet\n", c.addr)
+	}
+}
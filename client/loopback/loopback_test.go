@@ -0,0 +1,132 @@
+package loopback
+
+import (
+	"context"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"o2/client"
+	"o2/client/protocol03"
+	"o2/games"
+	"testing"
+	"time"
+)
+
+var _ games.Client = (*Client)(nil)
+
+func send(t testing.TB, c *Client, gm *protocol03.GroupMessage) {
+	gm.Group = string(c.Group())
+	b, err := proto.MarshalOptions{}.MarshalAppend(client.MakePacket(0x03).Bytes(), gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Write() <- b
+}
+
+func recv(t testing.TB, c *Client) *protocol03.GroupMessage {
+	var b []byte
+	select {
+	case b = <-c.Read():
+	default:
+		t.Fatalf("%s: expected a packet", c.addr)
+	}
+
+	var protocol uint8
+	r, err := client.ParseHeader(b, &protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm := &protocol03.GroupMessage{}
+	if err = proto.Unmarshal(pb, gm); err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+func TestHub_Flush(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	h := NewHub()
+	h.SetNow(func() time.Time { return now })
+
+	c0 := h.NewClient("group")
+	c1 := h.NewClient("group")
+	c2 := h.NewClient("other")
+
+	for _, c := range []*Client{c0, c1, c2} {
+		send(t, c, &protocol03.GroupMessage{JoinGroup: &protocol03.JoinGroup{}})
+	}
+	if n := h.Flush(); n != 3 {
+		t.Fatalf("Flush() = %d, want 3", n)
+	}
+
+	for c, want := range map[*Client]uint32{c0: 0, c1: 1, c2: 0} {
+		gm := recv(t, c)
+		if gm.GetJoinGroup() == nil {
+			t.Fatalf("%s: expected joinGroup reply", c.addr)
+		}
+		if gm.GetPlayerIndex() != want {
+			t.Errorf("%s: playerIndex = %d, want %d", c.addr, gm.GetPlayerIndex(), want)
+		}
+		if gm.GetServerTime() != now.UnixNano() {
+			t.Errorf("%s: serverTime = %d, want %d", c.addr, gm.GetServerTime(), now.UnixNano())
+		}
+	}
+
+	send(t, c1, &protocol03.GroupMessage{BroadcastAll: &protocol03.BroadcastAll{Data: []byte{1}}})
+	h.Flush()
+
+	if gm := recv(t, c0); gm.GetPlayerIndex() != 1 || gm.GetBroadcastAll() == nil {
+		t.Errorf("c0: unexpected message %v", gm)
+	}
+	if len(c1.Read()) != 0 || len(c2.Read()) != 0 {
+		t.Errorf("broadcast must only reach other players in the same group")
+	}
+
+	c1.Disconnect()
+	if b := <-c1.Read(); b != nil {
+		t.Errorf("expected nil packet to signal disconnect")
+	}
+	send(t, c0, &protocol03.GroupMessage{BroadcastAll: &protocol03.BroadcastAll{Data: []byte{1}}})
+	h.Flush()
+	if len(c1.Read()) != 0 {
+		t.Errorf("disconnected client must not receive packets")
+	}
+}
+
+func TestHub_Run(t *testing.T) {
+	h := NewHub()
+	c0 := h.NewClient("group")
+	c1 := h.NewClient("group")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+
+	waitFor := func(c *Client, n int) {
+		timeout := time.After(time.Second)
+		for len(c.Read()) < n {
+			select {
+			case <-timeout:
+				t.Fatalf("%s: timed out waiting for packets; got %d", c.addr, len(c.Read()))
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	send(t, c0, &protocol03.GroupMessage{JoinGroup: &protocol03.JoinGroup{}})
+	waitFor(c0, 1)
+	if gm := recv(t, c0); gm.GetJoinGroup() == nil {
+		t.Errorf("expected joinGroup reply")
+	}
+
+	send(t, c1, &protocol03.GroupMessage{JoinGroup: &protocol03.JoinGroup{}})
+	send(t, c1, &protocol03.GroupMessage{BroadcastAll: &protocol03.BroadcastAll{Data: []byte{1}}})
+	waitFor(c0, 1)
+	if gm := recv(t, c0); gm.GetBroadcastAll() == nil || gm.GetPlayerIndex() != 1 {
+		t.Errorf("expected broadcastAll from player[01]; got %v", gm)
+	}
+}
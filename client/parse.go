@@ -3,25 +3,53 @@ package client
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
 
+// ErrBadHeader is returned when a packet does not start with the expected magic number
+var ErrBadHeader = errors.New("bad message header")
+
+// ParseError reports which field of a malformed packet failed to parse and at what byte offset into the packet
+type ParseError struct {
+	Field  string
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing %s at offset %d: %v", e.Field, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ReadField reads a fixed-size little-endian value at offset; a short read is reported as io.ErrUnexpectedEOF
+func ReadField(r io.Reader, field string, offset int, data any) (err error) {
+	if err = binary.Read(r, binary.LittleEndian, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return &ParseError{Field: field, Offset: offset, Err: err}
+	}
+	return
+}
+
 func ParseHeader(msg []byte, protocol *uint8) (r io.Reader, err error) {
 	var hdr uint16
 
 	r = bytes.NewReader(msg)
-	err = binary.Read(r, binary.LittleEndian, &hdr)
-	if err != nil {
+	if err = ReadField(r, "header", 0, &hdr); err != nil {
 		return
 	}
 	if hdr != 25887 {
-		err = fmt.Errorf("bad message header")
+		err = &ParseError{Field: "header", Offset: 0, Err: ErrBadHeader}
 		return
 	}
 
-	err = binary.Read(r, binary.LittleEndian, protocol)
-	if err != nil {
+	if err = ReadField(r, "protocol", 2, protocol); err != nil {
 		return
 	}
 
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        []byte
+		wantField  string
+		wantOffset int
+		wantErr    error
+	}{
+		{"empty", []byte{}, "header", 0, io.ErrUnexpectedEOF},
+		{"short header", []byte{0x1F}, "header", 0, io.ErrUnexpectedEOF},
+		{"bad header", []byte{0x00, 0x00, 0x03}, "header", 0, ErrBadHeader},
+		{"missing protocol", []byte{0x1F, 0x65}, "protocol", 2, io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var protocol uint8
+			_, err := ParseHeader(tt.msg, &protocol)
+
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected a ParseError, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantField != perr.Field || tt.wantOffset != perr.Offset {
+				t.Errorf("expected %s at offset %d, got %s at offset %d", tt.wantField, tt.wantOffset, perr.Field, perr.Offset)
+			}
+		})
+	}
+
+	var protocol uint8
+	if _, err := ParseHeader(MakePacket(0x03).Bytes(), &protocol); err != nil {
+		t.Fatal(err)
+	}
+	if protocol != 0x03 {
+		t.Errorf("expected protocol 03, got %02x", protocol)
+	}
+}
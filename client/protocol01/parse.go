@@ -19,12 +19,7 @@ func readTinyString(r io.Reader) (value string, err error) {
 	}
 
 	valueBytes := make([]byte, valueLength)
-	var n int
-	n, err = r.Read(valueBytes)
-	if err != nil {
-		return
-	}
-	if n < int(valueLength) {
+	if _, err = io.ReadFull(r, valueBytes); err != nil {
 		return
 	}
 
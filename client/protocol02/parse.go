@@ -1,9 +1,8 @@
 package protocol02
 
 import (
-	"encoding/binary"
-	"fmt"
 	"io"
+	"o2/client"
 )
 
 type Header struct {
@@ -12,22 +11,19 @@ type Header struct {
 	Index uint16
 }
 
+// headerOffset is the offset of the protocol 02 header into the packet, after the common packet header
+const headerOffset = 3
+
 func Parse(r io.Reader, header *Header) (err error) {
-	if _, err = r.Read(header.Group[:]); err != nil {
-		// TODO: diagnostics
-		panic(fmt.Errorf("error reading group: %w", err))
+	if err = client.ReadField(r, "protocol 02 group", headerOffset, header.Group[:]); err != nil {
 		return
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, &header.Kind); err != nil {
-		// TODO: diagnostics
-		panic(fmt.Errorf("error reading kind: %w", err))
+	if err = client.ReadField(r, "protocol 02 kind", headerOffset+20, &header.Kind); err != nil {
 		return
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, &header.Index); err != nil {
-		// TODO: diagnostics
-		panic(fmt.Errorf("error reading index: %w", err))
+	if err = client.ReadField(r, "protocol 02 index", headerOffset+21, &header.Index); err != nil {
 		return
 	}
 
@@ -0,0 +1,893 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        v3.17.0
+// source: alttp.proto
+
+package protocol03
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ALTTP game payload carried in BroadcastAll.data and BroadcastSector.data. It is sent prefixed with a single $FF
+// byte to tell it apart from the binary format, which starts with its serialization version byte, and only once
+// every peer announces Capabilities.protobufPayload. Record field numbers match the binary format's message types.
+type AlttpPayload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// serialization version of the sender; informational only since records and fields unknown to the receiver are
+	// skipped rather than rejected:
+	SerializationVersion uint32 `protobuf:"varint,1,opt,name=serializationVersion,proto3" json:"serializationVersion,omitempty"`
+	Team                 uint32 `protobuf:"varint,2,opt,name=team,proto3" json:"team,omitempty"`
+	// frame number to correlate separate packets together:
+	Frame   uint32         `protobuf:"varint,3,opt,name=frame,proto3" json:"frame,omitempty"`
+	Records []*AlttpRecord `protobuf:"bytes,4,rep,name=records,proto3" json:"records,omitempty"`
+}
+
+func (x *AlttpPayload) Reset() {
+	*x = AlttpPayload{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpPayload) ProtoMessage() {}
+
+func (x *AlttpPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpPayload.ProtoReflect.Descriptor instead.
+func (*AlttpPayload) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AlttpPayload) GetSerializationVersion() uint32 {
+	if x != nil {
+		return x.SerializationVersion
+	}
+	return 0
+}
+
+func (x *AlttpPayload) GetTeam() uint32 {
+	if x != nil {
+		return x.Team
+	}
+	return 0
+}
+
+func (x *AlttpPayload) GetFrame() uint32 {
+	if x != nil {
+		return x.Frame
+	}
+	return 0
+}
+
+func (x *AlttpPayload) GetRecords() []*AlttpRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type AlttpRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Record:
+	//	*AlttpRecord_Location
+	//	*AlttpRecord_Wram
+	//	*AlttpRecord_Sram
+	//	*AlttpRecord_PlayerName
+	//	*AlttpRecord_SramDelta
+	Record isAlttpRecord_Record `protobuf_oneof:"record"`
+}
+
+func (x *AlttpRecord) Reset() {
+	*x = AlttpRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpRecord) ProtoMessage() {}
+
+func (x *AlttpRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpRecord.ProtoReflect.Descriptor instead.
+func (*AlttpRecord) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{1}
+}
+
+func (m *AlttpRecord) GetRecord() isAlttpRecord_Record {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+func (x *AlttpRecord) GetLocation() *AlttpLocation {
+	if x, ok := x.GetRecord().(*AlttpRecord_Location); ok {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *AlttpRecord) GetWram() *AlttpWRAM {
+	if x, ok := x.GetRecord().(*AlttpRecord_Wram); ok {
+		return x.Wram
+	}
+	return nil
+}
+
+func (x *AlttpRecord) GetSram() *AlttpSRAM {
+	if x, ok := x.GetRecord().(*AlttpRecord_Sram); ok {
+		return x.Sram
+	}
+	return nil
+}
+
+func (x *AlttpRecord) GetPlayerName() *AlttpPlayerName {
+	if x, ok := x.GetRecord().(*AlttpRecord_PlayerName); ok {
+		return x.PlayerName
+	}
+	return nil
+}
+
+func (x *AlttpRecord) GetSramDelta() *AlttpSRAMDelta {
+	if x, ok := x.GetRecord().(*AlttpRecord_SramDelta); ok {
+		return x.SramDelta
+	}
+	return nil
+}
+
+type isAlttpRecord_Record interface {
+	isAlttpRecord_Record()
+}
+
+type AlttpRecord_Location struct {
+	Location *AlttpLocation `protobuf:"bytes,1,opt,name=location,proto3,oneof"`
+}
+
+type AlttpRecord_Wram struct {
+	Wram *AlttpWRAM `protobuf:"bytes,5,opt,name=wram,proto3,oneof"`
+}
+
+type AlttpRecord_Sram struct {
+	Sram *AlttpSRAM `protobuf:"bytes,6,opt,name=sram,proto3,oneof"`
+}
+
+type AlttpRecord_PlayerName struct {
+	PlayerName *AlttpPlayerName `protobuf:"bytes,12,opt,name=playerName,proto3,oneof"`
+}
+
+type AlttpRecord_SramDelta struct {
+	SramDelta *AlttpSRAMDelta `protobuf:"bytes,13,opt,name=sramDelta,proto3,oneof"`
+}
+
+func (*AlttpRecord_Location) isAlttpRecord_Record() {}
+
+func (*AlttpRecord_Wram) isAlttpRecord_Record() {}
+
+func (*AlttpRecord_Sram) isAlttpRecord_Record() {}
+
+func (*AlttpRecord_PlayerName) isAlttpRecord_Record() {}
+
+func (*AlttpRecord_SramDelta) isAlttpRecord_Record() {}
+
+// where the player is and what they look like:
+type AlttpLocation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Module       uint32 `protobuf:"varint,1,opt,name=module,proto3" json:"module,omitempty"`
+	SubModule    uint32 `protobuf:"varint,2,opt,name=subModule,proto3" json:"subModule,omitempty"`
+	SubSubModule uint32 `protobuf:"varint,3,opt,name=subSubModule,proto3" json:"subSubModule,omitempty"`
+	// dungeon room when bit 16 is set, otherwise overworld area:
+	Location        uint32 `protobuf:"varint,4,opt,name=location,proto3" json:"location,omitempty"`
+	X               uint32 `protobuf:"varint,5,opt,name=x,proto3" json:"x,omitempty"`
+	Y               uint32 `protobuf:"varint,6,opt,name=y,proto3" json:"y,omitempty"`
+	Dungeon         uint32 `protobuf:"varint,7,opt,name=dungeon,proto3" json:"dungeon,omitempty"`
+	DungeonEntrance uint32 `protobuf:"varint,8,opt,name=dungeonEntrance,proto3" json:"dungeonEntrance,omitempty"`
+	LastOverworldX  uint32 `protobuf:"varint,9,opt,name=lastOverworldX,proto3" json:"lastOverworldX,omitempty"`
+	LastOverworldY  uint32 `protobuf:"varint,10,opt,name=lastOverworldY,proto3" json:"lastOverworldY,omitempty"`
+	XOffs           int32  `protobuf:"zigzag32,11,opt,name=xOffs,proto3" json:"xOffs,omitempty"`
+	YOffs           int32  `protobuf:"zigzag32,12,opt,name=yOffs,proto3" json:"yOffs,omitempty"`
+	PlayerColor     uint32 `protobuf:"varint,13,opt,name=playerColor,proto3" json:"playerColor,omitempty"`
+}
+
+func (x *AlttpLocation) Reset() {
+	*x = AlttpLocation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpLocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpLocation) ProtoMessage() {}
+
+func (x *AlttpLocation) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpLocation.ProtoReflect.Descriptor instead.
+func (*AlttpLocation) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AlttpLocation) GetModule() uint32 {
+	if x != nil {
+		return x.Module
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetSubModule() uint32 {
+	if x != nil {
+		return x.SubModule
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetSubSubModule() uint32 {
+	if x != nil {
+		return x.SubSubModule
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetLocation() uint32 {
+	if x != nil {
+		return x.Location
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetX() uint32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetY() uint32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetDungeon() uint32 {
+	if x != nil {
+		return x.Dungeon
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetDungeonEntrance() uint32 {
+	if x != nil {
+		return x.DungeonEntrance
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetLastOverworldX() uint32 {
+	if x != nil {
+		return x.LastOverworldX
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetLastOverworldY() uint32 {
+	if x != nil {
+		return x.LastOverworldY
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetXOffs() int32 {
+	if x != nil {
+		return x.XOffs
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetYOffs() int32 {
+	if x != nil {
+		return x.YOffs
+	}
+	return 0
+}
+
+func (x *AlttpLocation) GetPlayerColor() uint32 {
+	if x != nil {
+		return x.PlayerColor
+	}
+	return 0
+}
+
+// a range of timestamped WRAM words, e.g. small key counters:
+type AlttpWRAM struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// WRAM offset of the first word:
+	Start uint32           `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	Words []*AlttpWRAMWord `protobuf:"bytes,2,rep,name=words,proto3" json:"words,omitempty"`
+}
+
+func (x *AlttpWRAM) Reset() {
+	*x = AlttpWRAM{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpWRAM) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpWRAM) ProtoMessage() {}
+
+func (x *AlttpWRAM) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpWRAM.ProtoReflect.Descriptor instead.
+func (*AlttpWRAM) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AlttpWRAM) GetStart() uint32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *AlttpWRAM) GetWords() []*AlttpWRAMWord {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+type AlttpWRAMWord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// server SNES frame timestamp of the last write:
+	Timestamp uint32 `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Value     uint32 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *AlttpWRAMWord) Reset() {
+	*x = AlttpWRAMWord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpWRAMWord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpWRAMWord) ProtoMessage() {}
+
+func (x *AlttpWRAMWord) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpWRAMWord.ProtoReflect.Descriptor instead.
+func (*AlttpWRAMWord) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AlttpWRAMWord) GetTimestamp() uint32 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *AlttpWRAMWord) GetValue() uint32 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+// a range of SRAM bytes:
+type AlttpSRAM struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Start uint32 `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	Data  []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *AlttpSRAM) Reset() {
+	*x = AlttpSRAM{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpSRAM) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpSRAM) ProtoMessage() {}
+
+func (x *AlttpSRAM) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpSRAM.ProtoReflect.Descriptor instead.
+func (*AlttpSRAM) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AlttpSRAM) GetStart() uint32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *AlttpSRAM) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// runs of SRAM bytes that changed since the last keyframe:
+type AlttpSRAMDelta struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Runs []*AlttpSRAM `protobuf:"bytes,1,rep,name=runs,proto3" json:"runs,omitempty"`
+}
+
+func (x *AlttpSRAMDelta) Reset() {
+	*x = AlttpSRAMDelta{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpSRAMDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpSRAMDelta) ProtoMessage() {}
+
+func (x *AlttpSRAMDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpSRAMDelta.ProtoReflect.Descriptor instead.
+func (*AlttpSRAMDelta) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AlttpSRAMDelta) GetRuns() []*AlttpSRAM {
+	if x != nil {
+		return x.Runs
+	}
+	return nil
+}
+
+type AlttpPlayerName struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Unix nanoseconds; 0 when not started or not finished:
+	GameStartTime  int64 `protobuf:"varint,2,opt,name=gameStartTime,proto3" json:"gameStartTime,omitempty"`
+	GameFinishTime int64 `protobuf:"varint,3,opt,name=gameFinishTime,proto3" json:"gameFinishTime,omitempty"`
+}
+
+func (x *AlttpPlayerName) Reset() {
+	*x = AlttpPlayerName{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_alttp_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AlttpPlayerName) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlttpPlayerName) ProtoMessage() {}
+
+func (x *AlttpPlayerName) ProtoReflect() protoreflect.Message {
+	mi := &file_alttp_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlttpPlayerName.ProtoReflect.Descriptor instead.
+func (*AlttpPlayerName) Descriptor() ([]byte, []int) {
+	return file_alttp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *AlttpPlayerName) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AlttpPlayerName) GetGameStartTime() int64 {
+	if x != nil {
+		return x.GameStartTime
+	}
+	return 0
+}
+
+func (x *AlttpPlayerName) GetGameFinishTime() int64 {
+	if x != nil {
+		return x.GameFinishTime
+	}
+	return 0
+}
+
+var File_alttp_proto protoreflect.FileDescriptor
+
+var file_alttp_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x61, 0x6c, 0x74, 0x74, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x94, 0x01,
+	0x0a, 0x0c, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x32,
+	0x0a, 0x14, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x14, 0x73, 0x65,
+	0x72, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x61, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x04, 0x74, 0x65, 0x61, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x12, 0x26, 0x0a, 0x07,
+	0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e,
+	0x41, 0x6c, 0x74, 0x74, 0x70, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x07, 0x72, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x73, 0x22, 0xee, 0x01, 0x0a, 0x0b, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x52, 0x65,
+	0x63, 0x6f, 0x72, 0x64, 0x12, 0x2c, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x4c, 0x6f,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x20, 0x0a, 0x04, 0x77, 0x72, 0x61, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0a, 0x2e, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x57, 0x52, 0x41, 0x4d, 0x48, 0x00, 0x52, 0x04,
+	0x77, 0x72, 0x61, 0x6d, 0x12, 0x20, 0x0a, 0x04, 0x73, 0x72, 0x61, 0x6d, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x53, 0x52, 0x41, 0x4d, 0x48, 0x00,
+	0x52, 0x04, 0x73, 0x72, 0x61, 0x6d, 0x12, 0x32, 0x0a, 0x0a, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72,
+	0x4e, 0x61, 0x6d, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x41, 0x6c, 0x74,
+	0x74, 0x70, 0x50, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x4e, 0x61, 0x6d, 0x65, 0x48, 0x00, 0x52, 0x0a,
+	0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2f, 0x0a, 0x09, 0x73, 0x72,
+	0x61, 0x6d, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
+	0x41, 0x6c, 0x74, 0x74, 0x70, 0x53, 0x52, 0x41, 0x4d, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x48, 0x00,
+	0x52, 0x09, 0x73, 0x72, 0x61, 0x6d, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x42, 0x08, 0x0a, 0x06, 0x72,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x22, 0x83, 0x03, 0x0a, 0x0d, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x4c,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x75, 0x62, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x09, 0x73, 0x75, 0x62, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x22, 0x0a,
+	0x0c, 0x73, 0x75, 0x62, 0x53, 0x75, 0x62, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0c, 0x73, 0x75, 0x62, 0x53, 0x75, 0x62, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0c, 0x0a,
+	0x01, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x01, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x64, 0x75, 0x6e,
+	0x67, 0x65, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x64, 0x75, 0x6e, 0x67,
+	0x65, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x0f, 0x64, 0x75, 0x6e, 0x67, 0x65, 0x6f, 0x6e, 0x45, 0x6e,
+	0x74, 0x72, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x64, 0x75,
+	0x6e, 0x67, 0x65, 0x6f, 0x6e, 0x45, 0x6e, 0x74, 0x72, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x26, 0x0a,
+	0x0e, 0x6c, 0x61, 0x73, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x77, 0x6f, 0x72, 0x6c, 0x64, 0x58, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6c, 0x61, 0x73, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x77,
+	0x6f, 0x72, 0x6c, 0x64, 0x58, 0x12, 0x26, 0x0a, 0x0e, 0x6c, 0x61, 0x73, 0x74, 0x4f, 0x76, 0x65,
+	0x72, 0x77, 0x6f, 0x72, 0x6c, 0x64, 0x59, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6c,
+	0x61, 0x73, 0x74, 0x4f, 0x76, 0x65, 0x72, 0x77, 0x6f, 0x72, 0x6c, 0x64, 0x59, 0x12, 0x14, 0x0a,
+	0x05, 0x78, 0x4f, 0x66, 0x66, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x11, 0x52, 0x05, 0x78, 0x4f,
+	0x66, 0x66, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x79, 0x4f, 0x66, 0x66, 0x73, 0x18, 0x0c, 0x20, 0x01,
+	0x28, 0x11, 0x52, 0x05, 0x79, 0x4f, 0x66, 0x66, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x6c, 0x61,
+	0x79, 0x65, 0x72, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b,
+	0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x22, 0x47, 0x0a, 0x09, 0x41,
+	0x6c, 0x74, 0x74, 0x70, 0x57, 0x52, 0x41, 0x4d, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x24,
+	0x0a, 0x05, 0x77, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e,
+	0x41, 0x6c, 0x74, 0x74, 0x70, 0x57, 0x52, 0x41, 0x4d, 0x57, 0x6f, 0x72, 0x64, 0x52, 0x05, 0x77,
+	0x6f, 0x72, 0x64, 0x73, 0x22, 0x43, 0x0a, 0x0d, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x57, 0x52, 0x41,
+	0x4d, 0x57, 0x6f, 0x72, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x35, 0x0a, 0x09, 0x41, 0x6c, 0x74,
+	0x74, 0x70, 0x53, 0x52, 0x41, 0x4d, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x22, 0x30, 0x0a, 0x0e, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x53, 0x52, 0x41, 0x4d, 0x44, 0x65, 0x6c,
+	0x74, 0x61, 0x12, 0x1e, 0x0a, 0x04, 0x72, 0x75, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0a, 0x2e, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x53, 0x52, 0x41, 0x4d, 0x52, 0x04, 0x72, 0x75,
+	0x6e, 0x73, 0x22, 0x73, 0x0a, 0x0f, 0x41, 0x6c, 0x74, 0x74, 0x70, 0x50, 0x6c, 0x61, 0x79, 0x65,
+	0x72, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x67, 0x61, 0x6d,
+	0x65, 0x53, 0x74, 0x61, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0d, 0x67, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x72, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12,
+	0x26, 0x0a, 0x0e, 0x67, 0x61, 0x6d, 0x65, 0x46, 0x69, 0x6e, 0x69, 0x73, 0x68, 0x54, 0x69, 0x6d,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x67, 0x61, 0x6d, 0x65, 0x46, 0x69, 0x6e,
+	0x69, 0x73, 0x68, 0x54, 0x69, 0x6d, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_alttp_proto_rawDescOnce sync.Once
+	file_alttp_proto_rawDescData = file_alttp_proto_rawDesc
+)
+
+func file_alttp_proto_rawDescGZIP() []byte {
+	file_alttp_proto_rawDescOnce.Do(func() {
+		file_alttp_proto_rawDescData = protoimpl.X.CompressGZIP(file_alttp_proto_rawDescData)
+	})
+	return file_alttp_proto_rawDescData
+}
+
+var file_alttp_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_alttp_proto_goTypes = []interface{}{
+	(*AlttpPayload)(nil),    // 0: AlttpPayload
+	(*AlttpRecord)(nil),     // 1: AlttpRecord
+	(*AlttpLocation)(nil),   // 2: AlttpLocation
+	(*AlttpWRAM)(nil),       // 3: AlttpWRAM
+	(*AlttpWRAMWord)(nil),   // 4: AlttpWRAMWord
+	(*AlttpSRAM)(nil),       // 5: AlttpSRAM
+	(*AlttpSRAMDelta)(nil),  // 6: AlttpSRAMDelta
+	(*AlttpPlayerName)(nil), // 7: AlttpPlayerName
+}
+var file_alttp_proto_depIdxs = []int32{
+	1, // 0: AlttpPayload.records:type_name -> AlttpRecord
+	2, // 1: AlttpRecord.location:type_name -> AlttpLocation
+	3, // 2: AlttpRecord.wram:type_name -> AlttpWRAM
+	5, // 3: AlttpRecord.sram:type_name -> AlttpSRAM
+	7, // 4: AlttpRecord.playerName:type_name -> AlttpPlayerName
+	6, // 5: AlttpRecord.sramDelta:type_name -> AlttpSRAMDelta
+	4, // 6: AlttpWRAM.words:type_name -> AlttpWRAMWord
+	5, // 7: AlttpSRAMDelta.runs:type_name -> AlttpSRAM
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_alttp_proto_init() }
+func file_alttp_proto_init() {
+	if File_alttp_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_alttp_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpPayload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpLocation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpWRAM); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpWRAMWord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpSRAM); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpSRAMDelta); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_alttp_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AlttpPlayerName); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_alttp_proto_msgTypes[1].OneofWrappers = []interface{}{
+		(*AlttpRecord_Location)(nil),
+		(*AlttpRecord_Wram)(nil),
+		(*AlttpRecord_Sram)(nil),
+		(*AlttpRecord_PlayerName)(nil),
+		(*AlttpRecord_SramDelta)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_alttp_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_alttp_proto_goTypes,
+		DependencyIndexes: file_alttp_proto_depIdxs,
+		MessageInfos:      file_alttp_proto_msgTypes,
+	}.Build()
+	File_alttp_proto = out.File
+	file_alttp_proto_rawDesc = nil
+	file_alttp_proto_goTypes = nil
+	file_alttp_proto_depIdxs = nil
+}
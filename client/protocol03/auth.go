@@ -0,0 +1,71 @@
+package protocol03
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"strings"
+)
+
+var ErrMissingHMAC = errors.New("protocol03: missing hmac")
+var ErrBadHMAC = errors.New("protocol03: hmac mismatch")
+
+// DeriveKey derives the key used to sign GroupMessages from a group's shared secret. The key is bound to the
+// normalized group name so that the same secret used in different groups produces different keys.
+func DeriveKey(secret string, group string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte("o2 group key\000"))
+	h.Write([]byte(strings.ToLower(strings.Trim(group, " \t\r\n\000"))))
+	return h.Sum(nil)
+}
+
+// computeHMAC signs everything in gm except for the fields the server stamps on relay and the hmac itself
+func computeHMAC(gm *GroupMessage, key []byte) (sum []byte, err error) {
+	m := proto.Clone(gm).(*GroupMessage)
+	m.PlayerIndex = 0
+	m.ServerTime = 0
+	m.Replay = false
+	m.Hmac = nil
+
+	var b []byte
+	b, err = proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+	sum = h.Sum(nil)
+	return
+}
+
+// Sign sets gm.Hmac to the HMAC of gm using the given group key
+func Sign(gm *GroupMessage, key []byte) (err error) {
+	var sum []byte
+	sum, err = computeHMAC(gm, key)
+	if err != nil {
+		return
+	}
+
+	gm.Hmac = sum
+	return
+}
+
+// Verify checks gm.Hmac against the HMAC of gm using the given group key
+func Verify(gm *GroupMessage, key []byte) (err error) {
+	if len(gm.GetHmac()) == 0 {
+		return ErrMissingHMAC
+	}
+
+	var sum []byte
+	sum, err = computeHMAC(gm, key)
+	if err != nil {
+		return
+	}
+
+	if !hmac.Equal(sum, gm.GetHmac()) {
+		return ErrBadHMAC
+	}
+	return
+}
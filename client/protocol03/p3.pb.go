@@ -20,16 +20,112 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// describes a client so that peers can detect incompatible or mismatched configurations:
+type Capabilities struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientVersion string `protobuf:"bytes,1,opt,name=clientVersion,proto3" json:"clientVersion,omitempty"`
+	// name of the game factory, e.g. "ALTTP":
+	GameName             string `protobuf:"bytes,2,opt,name=gameName,proto3" json:"gameName,omitempty"`
+	SerializationVersion uint32 `protobuf:"varint,3,opt,name=serializationVersion,proto3" json:"serializationVersion,omitempty"`
+	// identifies the ROM being played:
+	RomHash []byte `protobuf:"bytes,4,opt,name=romHash,proto3" json:"romHash,omitempty"`
+	// game-specific bit flags of the enabled sync options:
+	SyncFlags uint64 `protobuf:"varint,5,opt,name=syncFlags,proto3" json:"syncFlags,omitempty"`
+	// the client accepts game payloads encoded as protobuf, e.g. AlttpPayload:
+	ProtobufPayload bool `protobuf:"varint,6,opt,name=protobufPayload,proto3" json:"protobufPayload,omitempty"`
+}
+
+func (x *Capabilities) Reset() {
+	*x = Capabilities{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p3_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Capabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Capabilities) ProtoMessage() {}
+
+func (x *Capabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_p3_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Capabilities.ProtoReflect.Descriptor instead.
+func (*Capabilities) Descriptor() ([]byte, []int) {
+	return file_p3_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Capabilities) GetClientVersion() string {
+	if x != nil {
+		return x.ClientVersion
+	}
+	return ""
+}
+
+func (x *Capabilities) GetGameName() string {
+	if x != nil {
+		return x.GameName
+	}
+	return ""
+}
+
+func (x *Capabilities) GetSerializationVersion() uint32 {
+	if x != nil {
+		return x.SerializationVersion
+	}
+	return 0
+}
+
+func (x *Capabilities) GetRomHash() []byte {
+	if x != nil {
+		return x.RomHash
+	}
+	return nil
+}
+
+func (x *Capabilities) GetSyncFlags() uint64 {
+	if x != nil {
+		return x.SyncFlags
+	}
+	return 0
+}
+
+func (x *Capabilities) GetProtobufPayload() bool {
+	if x != nil {
+		return x.ProtobufPayload
+	}
+	return false
+}
+
 type JoinGroup struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	// player index to keep when rejoining; honored by the server if it is free:
+	RequestedPlayerIndex *uint32       `protobuf:"varint,1,opt,name=requestedPlayerIndex,proto3,oneof" json:"requestedPlayerIndex,omitempty"`
+	Capabilities         *Capabilities `protobuf:"bytes,2,opt,name=capabilities,proto3,oneof" json:"capabilities,omitempty"`
 }
 
 func (x *JoinGroup) Reset() {
 	*x = JoinGroup{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p3_proto_msgTypes[0]
+		mi := &file_p3_proto_msgTypes[1]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -42,7 +138,7 @@ func (x *JoinGroup) String() string {
 func (*JoinGroup) ProtoMessage() {}
 
 func (x *JoinGroup) ProtoReflect() protoreflect.Message {
-	mi := &file_p3_proto_msgTypes[0]
+	mi := &file_p3_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -55,7 +151,21 @@ func (x *JoinGroup) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use JoinGroup.ProtoReflect.Descriptor instead.
 func (*JoinGroup) Descriptor() ([]byte, []int) {
-	return file_p3_proto_rawDescGZIP(), []int{0}
+	return file_p3_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *JoinGroup) GetRequestedPlayerIndex() uint32 {
+	if x != nil && x.RequestedPlayerIndex != nil {
+		return *x.RequestedPlayerIndex
+	}
+	return 0
+}
+
+func (x *JoinGroup) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
 }
 
 type BroadcastAll struct {
@@ -64,12 +174,15 @@ type BroadcastAll struct {
 	unknownFields protoimpl.UnknownFields
 
 	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// nonzero marks data as a full snapshot of some of the sender's state; the server keeps the latest snapshot per
+	// sender and key and replays it to players that join the group later:
+	Snapshot uint32 `protobuf:"varint,2,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
 }
 
 func (x *BroadcastAll) Reset() {
 	*x = BroadcastAll{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p3_proto_msgTypes[1]
+		mi := &file_p3_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -82,7 +195,7 @@ func (x *BroadcastAll) String() string {
 func (*BroadcastAll) ProtoMessage() {}
 
 func (x *BroadcastAll) ProtoReflect() protoreflect.Message {
-	mi := &file_p3_proto_msgTypes[1]
+	mi := &file_p3_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -95,7 +208,7 @@ func (x *BroadcastAll) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BroadcastAll.ProtoReflect.Descriptor instead.
 func (*BroadcastAll) Descriptor() ([]byte, []int) {
-	return file_p3_proto_rawDescGZIP(), []int{1}
+	return file_p3_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *BroadcastAll) GetData() []byte {
@@ -105,6 +218,13 @@ func (x *BroadcastAll) GetData() []byte {
 	return nil
 }
 
+func (x *BroadcastAll) GetSnapshot() uint32 {
+	if x != nil {
+		return x.Snapshot
+	}
+	return 0
+}
+
 type BroadcastSector struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -117,7 +237,7 @@ type BroadcastSector struct {
 func (x *BroadcastSector) Reset() {
 	*x = BroadcastSector{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p3_proto_msgTypes[2]
+		mi := &file_p3_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -130,7 +250,7 @@ func (x *BroadcastSector) String() string {
 func (*BroadcastSector) ProtoMessage() {}
 
 func (x *BroadcastSector) ProtoReflect() protoreflect.Message {
-	mi := &file_p3_proto_msgTypes[2]
+	mi := &file_p3_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -143,7 +263,7 @@ func (x *BroadcastSector) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BroadcastSector.ProtoReflect.Descriptor instead.
 func (*BroadcastSector) Descriptor() ([]byte, []int) {
-	return file_p3_proto_rawDescGZIP(), []int{2}
+	return file_p3_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *BroadcastSector) GetTargetSector() uint64 {
@@ -172,7 +292,7 @@ type Echo struct {
 func (x *Echo) Reset() {
 	*x = Echo{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p3_proto_msgTypes[3]
+		mi := &file_p3_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -185,7 +305,7 @@ func (x *Echo) String() string {
 func (*Echo) ProtoMessage() {}
 
 func (x *Echo) ProtoReflect() protoreflect.Message {
-	mi := &file_p3_proto_msgTypes[3]
+	mi := &file_p3_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -198,7 +318,7 @@ func (x *Echo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Echo.ProtoReflect.Descriptor instead.
 func (*Echo) Descriptor() ([]byte, []int) {
-	return file_p3_proto_rawDescGZIP(), []int{3}
+	return file_p3_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *Echo) GetData() []byte {
@@ -208,26 +328,307 @@ func (x *Echo) GetData() []byte {
 	return nil
 }
 
+// marks a broadcast as requiring acknowledgement from each receiving player:
+type Reliable struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Channel  uint32 `protobuf:"varint,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Sequence uint32 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// chosen at random each time the sender starts a new session; a change tells receivers the sender restarted its
+	// sequences:
+	Epoch uint32 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"`
+}
+
+func (x *Reliable) Reset() {
+	*x = Reliable{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p3_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Reliable) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reliable) ProtoMessage() {}
+
+func (x *Reliable) ProtoReflect() protoreflect.Message {
+	mi := &file_p3_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reliable.ProtoReflect.Descriptor instead.
+func (*Reliable) Descriptor() ([]byte, []int) {
+	return file_p3_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Reliable) GetChannel() uint32 {
+	if x != nil {
+		return x.Channel
+	}
+	return 0
+}
+
+func (x *Reliable) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Reliable) GetEpoch() uint32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+// acknowledges Reliable broadcasts received on a channel; routed only to the target player:
+type Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TargetPlayerIndex uint32 `protobuf:"varint,1,opt,name=targetPlayerIndex,proto3" json:"targetPlayerIndex,omitempty"`
+	Channel           uint32 `protobuf:"varint,2,opt,name=channel,proto3" json:"channel,omitempty"`
+	// highest sequence received in order:
+	Sequence uint32 `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// bit N set means sequence+1+N was received out of order:
+	Received uint64 `protobuf:"varint,4,opt,name=received,proto3" json:"received,omitempty"`
+	// epoch of the Reliable broadcasts being acked:
+	Epoch uint32 `protobuf:"varint,5,opt,name=epoch,proto3" json:"epoch,omitempty"`
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p3_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_p3_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_p3_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Ack) GetTargetPlayerIndex() uint32 {
+	if x != nil {
+		return x.TargetPlayerIndex
+	}
+	return 0
+}
+
+func (x *Ack) GetChannel() uint32 {
+	if x != nil {
+		return x.Channel
+	}
+	return 0
+}
+
+func (x *Ack) GetSequence() uint32 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Ack) GetReceived() uint64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *Ack) GetEpoch() uint32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+// marks a spot on the map for other players to see:
+type Ping struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// same encoding as the game's player location, e.g. for ALTTP bit 16 is set for underworld rooms:
+	Location uint32 `protobuf:"varint,1,opt,name=location,proto3" json:"location,omitempty"`
+	X        uint32 `protobuf:"varint,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y        uint32 `protobuf:"varint,3,opt,name=y,proto3" json:"y,omitempty"`
+}
+
+func (x *Ping) Reset() {
+	*x = Ping{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p3_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Ping) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ping) ProtoMessage() {}
+
+func (x *Ping) ProtoReflect() protoreflect.Message {
+	mi := &file_p3_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ping.ProtoReflect.Descriptor instead.
+func (*Ping) Descriptor() ([]byte, []int) {
+	return file_p3_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Ping) GetLocation() uint32 {
+	if x != nil {
+		return x.Location
+	}
+	return 0
+}
+
+func (x *Ping) GetX() uint32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Ping) GetY() uint32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// a short text message and/or map ping relayed to all other players in the group:
+type Chat struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Text string `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Ping *Ping  `protobuf:"bytes,2,opt,name=ping,proto3,oneof" json:"ping,omitempty"`
+}
+
+func (x *Chat) Reset() {
+	*x = Chat{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p3_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Chat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chat) ProtoMessage() {}
+
+func (x *Chat) ProtoReflect() protoreflect.Message {
+	mi := &file_p3_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chat.ProtoReflect.Descriptor instead.
+func (*Chat) Descriptor() ([]byte, []int) {
+	return file_p3_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Chat) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Chat) GetPing() *Ping {
+	if x != nil {
+		return x.Ping
+	}
+	return nil
+}
+
 type GroupMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Group           string           `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
-	PlayerTime      int64            `protobuf:"varint,2,opt,name=playerTime,proto3" json:"playerTime,omitempty"`
-	ServerTime      int64            `protobuf:"varint,3,opt,name=serverTime,proto3" json:"serverTime,omitempty"`
-	PlayerIndex     uint32           `protobuf:"varint,4,opt,name=playerIndex,proto3" json:"playerIndex,omitempty"`
-	PlayerInSector  uint64           `protobuf:"varint,5,opt,name=playerInSector,proto3" json:"playerInSector,omitempty"`
+	Group          string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	PlayerTime     int64  `protobuf:"varint,2,opt,name=playerTime,proto3" json:"playerTime,omitempty"`
+	ServerTime     int64  `protobuf:"varint,3,opt,name=serverTime,proto3" json:"serverTime,omitempty"`
+	PlayerIndex    uint32 `protobuf:"varint,4,opt,name=playerIndex,proto3" json:"playerIndex,omitempty"`
+	PlayerInSector uint64 `protobuf:"varint,5,opt,name=playerInSector,proto3" json:"playerInSector,omitempty"`
+	// random identifier generated once by the sender that survives reconnects, unlike playerIndex:
+	PlayerId []byte `protobuf:"bytes,6,opt,name=playerId,proto3" json:"playerId,omitempty"`
+	// stamped by the server on snapshots it replays to a joining player; see BroadcastAll.snapshot:
+	Replay          bool             `protobuf:"varint,7,opt,name=replay,proto3" json:"replay,omitempty"`
 	JoinGroup       *JoinGroup       `protobuf:"bytes,10,opt,name=joinGroup,proto3,oneof" json:"joinGroup,omitempty"`
 	BroadcastAll    *BroadcastAll    `protobuf:"bytes,11,opt,name=broadcastAll,proto3,oneof" json:"broadcastAll,omitempty"`
 	BroadcastSector *BroadcastSector `protobuf:"bytes,12,opt,name=broadcastSector,proto3,oneof" json:"broadcastSector,omitempty"`
 	Echo            *Echo            `protobuf:"bytes,13,opt,name=echo,proto3,oneof" json:"echo,omitempty"`
+	Ack             *Ack             `protobuf:"bytes,14,opt,name=ack,proto3,oneof" json:"ack,omitempty"`
+	// announces the sender's capabilities to its peers alongside a broadcast:
+	Capabilities *Capabilities `protobuf:"bytes,15,opt,name=capabilities,proto3,oneof" json:"capabilities,omitempty"`
+	Chat         *Chat         `protobuf:"bytes,16,opt,name=chat,proto3,oneof" json:"chat,omitempty"`
+	Reliable     *Reliable     `protobuf:"bytes,20,opt,name=reliable,proto3,oneof" json:"reliable,omitempty"`
+	// HMAC-SHA256 of this message signed with the group key; see DeriveKey:
+	Hmac []byte `protobuf:"bytes,21,opt,name=hmac,proto3" json:"hmac,omitempty"`
 }
 
 func (x *GroupMessage) Reset() {
 	*x = GroupMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p3_proto_msgTypes[4]
+		mi := &file_p3_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -240,7 +641,7 @@ func (x *GroupMessage) String() string {
 func (*GroupMessage) ProtoMessage() {}
 
 func (x *GroupMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_p3_proto_msgTypes[4]
+	mi := &file_p3_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -253,7 +654,7 @@ func (x *GroupMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GroupMessage.ProtoReflect.Descriptor instead.
 func (*GroupMessage) Descriptor() ([]byte, []int) {
-	return file_p3_proto_rawDescGZIP(), []int{4}
+	return file_p3_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *GroupMessage) GetGroup() string {
@@ -291,6 +692,20 @@ func (x *GroupMessage) GetPlayerInSector() uint64 {
 	return 0
 }
 
+func (x *GroupMessage) GetPlayerId() []byte {
+	if x != nil {
+		return x.PlayerId
+	}
+	return nil
+}
+
+func (x *GroupMessage) GetReplay() bool {
+	if x != nil {
+		return x.Replay
+	}
+	return false
+}
+
 func (x *GroupMessage) GetJoinGroup() *JoinGroup {
 	if x != nil {
 		return x.JoinGroup
@@ -319,31 +734,119 @@ func (x *GroupMessage) GetEcho() *Echo {
 	return nil
 }
 
+func (x *GroupMessage) GetAck() *Ack {
+	if x != nil {
+		return x.Ack
+	}
+	return nil
+}
+
+func (x *GroupMessage) GetCapabilities() *Capabilities {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *GroupMessage) GetChat() *Chat {
+	if x != nil {
+		return x.Chat
+	}
+	return nil
+}
+
+func (x *GroupMessage) GetReliable() *Reliable {
+	if x != nil {
+		return x.Reliable
+	}
+	return nil
+}
+
+func (x *GroupMessage) GetHmac() []byte {
+	if x != nil {
+		return x.Hmac
+	}
+	return nil
+}
+
 var File_p3_proto protoreflect.FileDescriptor
 
 var file_p3_proto_rawDesc = []byte{
-	0x0a, 0x08, 0x70, 0x33, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x0b, 0x0a, 0x09, 0x4a, 0x6f,
-	0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x22, 0x22, 0x0a, 0x0c, 0x42, 0x72, 0x6f, 0x61, 0x64,
-	0x63, 0x61, 0x73, 0x74, 0x41, 0x6c, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x49, 0x0a, 0x0f, 0x42,
-	0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x22,
-	0x0a, 0x0c, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x53, 0x65, 0x63, 0x74,
-	0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x1a, 0x0a, 0x04, 0x45, 0x63, 0x68, 0x6f, 0x12, 0x12,
-	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
-	0x74, 0x61, 0x22, 0xb2, 0x03, 0x0a, 0x0c, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x6c, 0x61,
-	0x79, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x70,
-	0x6c, 0x61, 0x79, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x73, 0x65, 0x72,
-	0x76, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x73,
-	0x65, 0x72, 0x76, 0x65, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x6c, 0x61,
-	0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b,
-	0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x26, 0x0a, 0x0e, 0x70,
-	0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x05, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x0e, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x53, 0x65, 0x63,
-	0x74, 0x6f, 0x72, 0x12, 0x2d, 0x0a, 0x09, 0x6a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70,
+	0x0a, 0x08, 0x70, 0x33, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xe6, 0x01, 0x0a, 0x0c, 0x43,
+	0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x67, 0x61, 0x6d, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x67, 0x61, 0x6d, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x32, 0x0a,
+	0x14, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x14, 0x73, 0x65, 0x72,
+	0x69, 0x61, 0x6c, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x6f, 0x6d, 0x48, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x07, 0x72, 0x6f, 0x6d, 0x48, 0x61, 0x73, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x73,
+	0x79, 0x6e, 0x63, 0x46, 0x6c, 0x61, 0x67, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
+	0x73, 0x79, 0x6e, 0x63, 0x46, 0x6c, 0x61, 0x67, 0x73, 0x12, 0x28, 0x0a, 0x0f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x50, 0x61, 0x79, 0x6c,
+	0x6f, 0x61, 0x64, 0x22, 0xa6, 0x01, 0x0a, 0x09, 0x4a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75,
+	0x70, 0x12, 0x37, 0x0a, 0x14, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x50, 0x6c,
+	0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x48,
+	0x00, 0x52, 0x14, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64, 0x50, 0x6c, 0x61, 0x79,
+	0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x88, 0x01, 0x01, 0x12, 0x36, 0x0a, 0x0c, 0x63, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0d, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x48,
+	0x01, 0x52, 0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x88,
+	0x01, 0x01, 0x42, 0x17, 0x0a, 0x15, 0x5f, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x65, 0x64,
+	0x50, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x42, 0x0f, 0x0a, 0x0d, 0x5f,
+	0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x22, 0x3e, 0x0a, 0x0c,
+	0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x41, 0x6c, 0x6c, 0x12, 0x12, 0x0a, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x1a, 0x0a, 0x08, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x08, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x22, 0x49, 0x0a, 0x0f,
+	0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12,
+	0x22, 0x0a, 0x0c, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x53, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x1a, 0x0a, 0x04, 0x45, 0x63, 0x68, 0x6f, 0x12,
+	0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x61, 0x22, 0x56, 0x0a, 0x08, 0x52, 0x65, 0x6c, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x12,
+	0x18, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x73, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x9b, 0x01, 0x0a, 0x03,
+	0x41, 0x63, 0x6b, 0x12, 0x2c, 0x0a, 0x11, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6c, 0x61,
+	0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x11,
+	0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x64, 0x65,
+	0x78, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x73,
+	0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x73,
+	0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65, 0x63, 0x65, 0x69,
+	0x76, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x72, 0x65, 0x63, 0x65, 0x69,
+	0x76, 0x65, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x3e, 0x0a, 0x04, 0x50, 0x69, 0x6e,
+	0x67, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0c, 0x0a,
+	0x01, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x01, 0x79, 0x22, 0x43, 0x0a, 0x04, 0x43, 0x68, 0x61,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x1e, 0x0a, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x48, 0x00, 0x52, 0x04, 0x70, 0x69,
+	0x6e, 0x67, 0x88, 0x01, 0x01, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x70, 0x69, 0x6e, 0x67, 0x22, 0xca,
+	0x05, 0x0a, 0x0c, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x54,
+	0x69, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x70, 0x6c, 0x61, 0x79, 0x65,
+	0x72, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x54,
+	0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x73, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49,
+	0x6e, 0x64, 0x65, 0x78, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x70, 0x6c, 0x61, 0x79,
+	0x65, 0x72, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x26, 0x0a, 0x0e, 0x70, 0x6c, 0x61, 0x79, 0x65,
+	0x72, 0x49, 0x6e, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x0e, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x6e, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12,
+	0x1a, 0x0a, 0x08, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x08, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x72,
+	0x65, 0x70, 0x6c, 0x61, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x72, 0x65, 0x70,
+	0x6c, 0x61, 0x79, 0x12, 0x2d, 0x0a, 0x09, 0x6a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70,
 	0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x4a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f,
 	0x75, 0x70, 0x48, 0x00, 0x52, 0x09, 0x6a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x88,
 	0x01, 0x01, 0x12, 0x36, 0x0a, 0x0c, 0x62, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x41,
@@ -355,11 +858,26 @@ var file_p3_proto_rawDesc = []byte{
 	0x65, 0x63, 0x74, 0x6f, 0x72, 0x48, 0x02, 0x52, 0x0f, 0x62, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61,
 	0x73, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x88, 0x01, 0x01, 0x12, 0x1e, 0x0a, 0x04, 0x65,
 	0x63, 0x68, 0x6f, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x45, 0x63, 0x68, 0x6f,
-	0x48, 0x03, 0x52, 0x04, 0x65, 0x63, 0x68, 0x6f, 0x88, 0x01, 0x01, 0x42, 0x0c, 0x0a, 0x0a, 0x5f,
-	0x6a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x62, 0x72,
-	0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x41, 0x6c, 0x6c, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x62,
-	0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x42, 0x07,
-	0x0a, 0x05, 0x5f, 0x65, 0x63, 0x68, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x48, 0x03, 0x52, 0x04, 0x65, 0x63, 0x68, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x03, 0x61,
+	0x63, 0x6b, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x04, 0x2e, 0x41, 0x63, 0x6b, 0x48, 0x04,
+	0x52, 0x03, 0x61, 0x63, 0x6b, 0x88, 0x01, 0x01, 0x12, 0x36, 0x0a, 0x0c, 0x63, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d,
+	0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x48, 0x05, 0x52,
+	0x0c, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x88, 0x01, 0x01,
+	0x12, 0x1e, 0x0a, 0x04, 0x63, 0x68, 0x61, 0x74, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x05,
+	0x2e, 0x43, 0x68, 0x61, 0x74, 0x48, 0x06, 0x52, 0x04, 0x63, 0x68, 0x61, 0x74, 0x88, 0x01, 0x01,
+	0x12, 0x2a, 0x0a, 0x08, 0x72, 0x65, 0x6c, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x14, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x09, 0x2e, 0x52, 0x65, 0x6c, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x48, 0x07, 0x52,
+	0x08, 0x72, 0x65, 0x6c, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x88, 0x01, 0x01, 0x12, 0x12, 0x0a, 0x04,
+	0x68, 0x6d, 0x61, 0x63, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x6d, 0x61, 0x63,
+	0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x6a, 0x6f, 0x69, 0x6e, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x42, 0x0f,
+	0x0a, 0x0d, 0x5f, 0x62, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x41, 0x6c, 0x6c, 0x42,
+	0x12, 0x0a, 0x10, 0x5f, 0x62, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x53, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x65, 0x63, 0x68, 0x6f, 0x42, 0x06, 0x0a, 0x04,
+	0x5f, 0x61, 0x63, 0x6b, 0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x69, 0x65, 0x73, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x63, 0x68, 0x61, 0x74, 0x42, 0x0b,
+	0x0a, 0x09, 0x5f, 0x72, 0x65, 0x6c, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -374,24 +892,35 @@ func file_p3_proto_rawDescGZIP() []byte {
 	return file_p3_proto_rawDescData
 }
 
-var file_p3_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_p3_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_p3_proto_goTypes = []interface{}{
-	(*JoinGroup)(nil),       // 0: JoinGroup
-	(*BroadcastAll)(nil),    // 1: BroadcastAll
-	(*BroadcastSector)(nil), // 2: BroadcastSector
-	(*Echo)(nil),            // 3: Echo
-	(*GroupMessage)(nil),    // 4: GroupMessage
+	(*Capabilities)(nil),    // 0: Capabilities
+	(*JoinGroup)(nil),       // 1: JoinGroup
+	(*BroadcastAll)(nil),    // 2: BroadcastAll
+	(*BroadcastSector)(nil), // 3: BroadcastSector
+	(*Echo)(nil),            // 4: Echo
+	(*Reliable)(nil),        // 5: Reliable
+	(*Ack)(nil),             // 6: Ack
+	(*Ping)(nil),            // 7: Ping
+	(*Chat)(nil),            // 8: Chat
+	(*GroupMessage)(nil),    // 9: GroupMessage
 }
 var file_p3_proto_depIdxs = []int32{
-	0, // 0: GroupMessage.joinGroup:type_name -> JoinGroup
-	1, // 1: GroupMessage.broadcastAll:type_name -> BroadcastAll
-	2, // 2: GroupMessage.broadcastSector:type_name -> BroadcastSector
-	3, // 3: GroupMessage.echo:type_name -> Echo
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	0,  // 0: JoinGroup.capabilities:type_name -> Capabilities
+	7,  // 1: Chat.ping:type_name -> Ping
+	1,  // 2: GroupMessage.joinGroup:type_name -> JoinGroup
+	2,  // 3: GroupMessage.broadcastAll:type_name -> BroadcastAll
+	3,  // 4: GroupMessage.broadcastSector:type_name -> BroadcastSector
+	4,  // 5: GroupMessage.echo:type_name -> Echo
+	6,  // 6: GroupMessage.ack:type_name -> Ack
+	0,  // 7: GroupMessage.capabilities:type_name -> Capabilities
+	8,  // 8: GroupMessage.chat:type_name -> Chat
+	5,  // 9: GroupMessage.reliable:type_name -> Reliable
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
 }
 
 func init() { file_p3_proto_init() }
@@ -401,7 +930,7 @@ func file_p3_proto_init() {
 	}
 	if !protoimpl.UnsafeEnabled {
 		file_p3_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*JoinGroup); i {
+			switch v := v.(*Capabilities); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -413,7 +942,7 @@ func file_p3_proto_init() {
 			}
 		}
 		file_p3_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BroadcastAll); i {
+			switch v := v.(*JoinGroup); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -425,7 +954,7 @@ func file_p3_proto_init() {
 			}
 		}
 		file_p3_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BroadcastSector); i {
+			switch v := v.(*BroadcastAll); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -437,7 +966,7 @@ func file_p3_proto_init() {
 			}
 		}
 		file_p3_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Echo); i {
+			switch v := v.(*BroadcastSector); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -449,6 +978,66 @@ func file_p3_proto_init() {
 			}
 		}
 		file_p3_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Echo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p3_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Reliable); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p3_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p3_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ping); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p3_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Chat); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p3_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GroupMessage); i {
 			case 0:
 				return &v.state
@@ -461,14 +1050,16 @@ func file_p3_proto_init() {
 			}
 		}
 	}
-	file_p3_proto_msgTypes[4].OneofWrappers = []interface{}{}
+	file_p3_proto_msgTypes[1].OneofWrappers = []interface{}{}
+	file_p3_proto_msgTypes[8].OneofWrappers = []interface{}{}
+	file_p3_proto_msgTypes[9].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_p3_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1 +1 @@
-protoc -I. --go_out=. --go_opt=module=github.com/alttpo/o2/client/protocol03 --go_opt=Mp3.proto=github.com/alttpo/o2/client/protocol03 p3.proto
+protoc -I. --go_out=. --go_opt=module=github.com/alttpo/o2/client/protocol03 --go_opt=Mp3.proto=github.com/alttpo/o2/client/protocol03 --go_opt=Malttp.proto=github.com/alttpo/o2/client/protocol03 p3.proto alttp.proto
@@ -0,0 +1,11 @@
+package protocol03
+
+// ReportsSector reports whether gm carries the sender's current sector in PlayerInSector. Only joins and unreliable
+// broadcasts, which carry the sender's location, count; replayed snapshots and retransmitted reliable broadcasts
+// carry the sector from when they were first sent.
+func ReportsSector(gm *GroupMessage) bool {
+	if gm.GetReplay() || gm.GetReliable() != nil {
+		return false
+	}
+	return gm.GetJoinGroup() != nil || gm.GetBroadcastAll() != nil || gm.GetBroadcastSector() != nil
+}
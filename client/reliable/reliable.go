@@ -0,0 +1,358 @@
+package reliable
+
+import (
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"o2/client/protocol03"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetransmitTimeout is how long to wait for acks before retransmitting a reliable broadcast
+	DefaultRetransmitTimeout = 150 * time.Millisecond
+	// DefaultMaxRetransmits is how many times a reliable broadcast is retransmitted before giving up on it
+	DefaultMaxRetransmits = 8
+	// DefaultPeerTimeout is how long a peer can go unheard from before it is no longer expected to ack
+	DefaultPeerTimeout = 5 * time.Second
+	// DefaultGapTimeout is how long a receiver waits for a missing sequence before skipping past it
+	DefaultGapTimeout = 2 * time.Second
+)
+
+// ackWindow is the number of out-of-order sequences that can be selectively acked
+const ackWindow = 64
+
+type pending struct {
+	msg         *protocol03.GroupMessage
+	sentAt      time.Time
+	retransmits int
+	// player indexes expected to ack; removed from once acked:
+	waiting map[uint32]struct{}
+}
+
+type sender struct {
+	nextSequence uint32
+	pending      map[uint32]*pending
+}
+
+type receiver struct {
+	// epoch of the sender's session; a new epoch means the sender restarted its sequences:
+	epoch   uint32
+	started bool
+	// next in-order sequence expected:
+	next uint32
+	// out-of-order messages waiting on a gap to be filled:
+	buffered map[uint32]*protocol03.GroupMessage
+	gapSince time.Time
+}
+
+type receiverKey struct {
+	player  uint32
+	channel uint32
+}
+
+// Session implements sequence numbers, acks, selective retransmission and per-channel ordered delivery of
+// protocol 03 broadcasts on top of an unreliable transport. Broadcasts sent through a Session are acked by each
+// receiving player individually; each channel is delivered in order independently of other channels.
+type Session struct {
+	RetransmitTimeout time.Duration
+	MaxRetransmits    int
+	PeerTimeout       time.Duration
+	GapTimeout        time.Duration
+
+	lock sync.Mutex
+
+	// epoch identifies this session's sequences to receivers; it changes whenever the sequences restart:
+	epoch uint32
+
+	localIndex    uint32
+	hasLocalIndex bool
+
+	peers     map[uint32]time.Time
+	senders   map[uint32]*sender
+	receivers map[receiverKey]*receiver
+}
+
+func NewSession() *Session {
+	return &Session{
+		RetransmitTimeout: DefaultRetransmitTimeout,
+		MaxRetransmits:    DefaultMaxRetransmits,
+		PeerTimeout:       DefaultPeerTimeout,
+		GapTimeout:        DefaultGapTimeout,
+		epoch:             newEpoch(),
+		peers:             make(map[uint32]time.Time),
+		senders:           make(map[uint32]*sender),
+		receivers:         make(map[receiverKey]*receiver),
+	}
+}
+
+// SetLocalIndex informs the session of the player index assigned to us by the server
+func (s *Session) SetLocalIndex(index uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.localIndex = index
+	s.hasLocalIndex = true
+	delete(s.peers, index)
+}
+
+// newEpoch picks a random nonzero epoch; zero is left for peers that do not send one
+func newEpoch() uint32 {
+	for {
+		if epoch := rand.Uint32(); epoch != 0 {
+			return epoch
+		}
+	}
+}
+
+// Reset forgets all sequence, ack and peer state and starts a new epoch
+func (s *Session) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.epoch = newEpoch()
+	s.hasLocalIndex = false
+	s.peers = make(map[uint32]time.Time)
+	s.senders = make(map[uint32]*sender)
+	s.receivers = make(map[receiverKey]*receiver)
+}
+
+func (s *Session) activePeers(now time.Time) map[uint32]struct{} {
+	waiting := make(map[uint32]struct{}, len(s.peers))
+	for index, lastSeen := range s.peers {
+		if now.Sub(lastSeen) > s.PeerTimeout {
+			delete(s.peers, index)
+			continue
+		}
+		waiting[index] = struct{}{}
+	}
+	return waiting
+}
+
+// Send marks gm as reliable on the given channel and records it for retransmission until every active peer acks it
+func (s *Session) Send(gm *protocol03.GroupMessage, channel uint32, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sd, ok := s.senders[channel]
+	if !ok {
+		sd = &sender{pending: make(map[uint32]*pending)}
+		s.senders[channel] = sd
+	}
+
+	sequence := sd.nextSequence
+	sd.nextSequence++
+
+	gm.Reliable = &protocol03.Reliable{
+		Channel:  channel,
+		Sequence: sequence,
+		Epoch:    s.epoch,
+	}
+
+	waiting := s.activePeers(now)
+	if len(waiting) == 0 {
+		// nobody to wait on:
+		return
+	}
+
+	sd.pending[sequence] = &pending{
+		msg:     proto.Clone(gm).(*protocol03.GroupMessage),
+		sentAt:  now,
+		waiting: waiting,
+	}
+}
+
+// Retransmit returns copies of reliable broadcasts that have not been acked by every peer in time
+func (s *Session) Retransmit(now time.Time) (msgs []*protocol03.GroupMessage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	active := s.activePeers(now)
+
+	channels := make([]uint32, 0, len(s.senders))
+	for channel := range s.senders {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+
+	for _, channel := range channels {
+		sd := s.senders[channel]
+
+		sequences := make([]uint32, 0, len(sd.pending))
+		for sequence := range sd.pending {
+			sequences = append(sequences, sequence)
+		}
+		sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+		for _, sequence := range sequences {
+			p := sd.pending[sequence]
+
+			// stop waiting on peers that went away:
+			for index := range p.waiting {
+				if _, ok := active[index]; !ok {
+					delete(p.waiting, index)
+				}
+			}
+			if len(p.waiting) == 0 {
+				delete(sd.pending, sequence)
+				continue
+			}
+
+			if now.Sub(p.sentAt) < s.RetransmitTimeout {
+				continue
+			}
+			if p.retransmits >= s.MaxRetransmits {
+				delete(sd.pending, sequence)
+				continue
+			}
+
+			p.retransmits++
+			p.sentAt = now
+			msgs = append(msgs, proto.Clone(p.msg).(*protocol03.GroupMessage))
+		}
+	}
+
+	return
+}
+
+// Pending returns the number of reliable broadcasts waiting on acks
+func (s *Session) Pending() (n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, sd := range s.senders {
+		n += len(sd.pending)
+	}
+	return
+}
+
+// Receive processes a message received from the server. It returns the messages that are ready to be handled in
+// order, and an Ack to send back to the sender if gm was a reliable broadcast.
+func (s *Session) Receive(gm *protocol03.GroupMessage, now time.Time) (deliver []*protocol03.GroupMessage, ack *protocol03.Ack) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	from := gm.GetPlayerIndex()
+	isLocal := s.hasLocalIndex && from == s.localIndex
+	if !isLocal && gm.GetJoinGroup() == nil && gm.GetEcho() == nil {
+		s.peers[from] = now
+	}
+
+	if a := gm.GetAck(); a != nil {
+		s.handleAck(from, a)
+		return
+	}
+
+	rel := gm.GetReliable()
+	if rel == nil || isLocal {
+		deliver = []*protocol03.GroupMessage{gm}
+		return
+	}
+
+	key := receiverKey{player: from, channel: rel.GetChannel()}
+	rc, ok := s.receivers[key]
+	if !ok || rc.epoch != rel.GetEpoch() {
+		// first message from this player on this channel or the player restarted its session, e.g. after
+		// reconnecting at the same index:
+		rc = &receiver{epoch: rel.GetEpoch(), buffered: make(map[uint32]*protocol03.GroupMessage)}
+		s.receivers[key] = rc
+	}
+
+	sequence := rel.GetSequence()
+	if !rc.started {
+		// first message seen from this player on this channel establishes the starting sequence:
+		rc.started = true
+		rc.next = sequence
+	}
+
+	if int32(sequence-rc.next) < 0 {
+		// duplicate of an already delivered message; re-ack it:
+	} else if sequence == rc.next {
+		deliver = append(deliver, gm)
+		rc.next++
+	} else if sequence-rc.next < ackWindow {
+		if _, ok = rc.buffered[sequence]; !ok {
+			rc.buffered[sequence] = gm
+		}
+		if rc.gapSince.IsZero() {
+			rc.gapSince = now
+		}
+	}
+
+	// give up on a gap that has been open for too long:
+	if len(rc.buffered) > 0 && !rc.gapSince.IsZero() && now.Sub(rc.gapSince) >= s.GapTimeout {
+		lowest := rc.next
+		first := true
+		for sequence := range rc.buffered {
+			if first || int32(sequence-lowest) < 0 {
+				lowest = sequence
+				first = false
+			}
+		}
+		rc.next = lowest
+	}
+
+	// deliver any buffered messages that are now in order:
+	for {
+		m, ok := rc.buffered[rc.next]
+		if !ok {
+			break
+		}
+		delete(rc.buffered, rc.next)
+		deliver = append(deliver, m)
+		rc.next++
+	}
+	if len(rc.buffered) == 0 {
+		rc.gapSince = time.Time{}
+	} else if len(deliver) > 0 {
+		rc.gapSince = now
+	}
+
+	ack = &protocol03.Ack{
+		TargetPlayerIndex: from,
+		Channel:           rel.GetChannel(),
+		Sequence:          rc.next - 1,
+		Epoch:             rc.epoch,
+	}
+	for sequence := range rc.buffered {
+		// bit N acknowledges receipt of ack.Sequence+1+N:
+		bit := sequence - rc.next
+		if bit < ackWindow {
+			ack.Received |= 1 << bit
+		}
+	}
+
+	return
+}
+
+func (s *Session) handleAck(from uint32, a *protocol03.Ack) {
+	if !s.hasLocalIndex || a.GetTargetPlayerIndex() != s.localIndex {
+		return
+	}
+	if a.GetEpoch() != 0 && a.GetEpoch() != s.epoch {
+		// an ack for sequences of a previous session, still in flight when we restarted:
+		return
+	}
+
+	sd, ok := s.senders[a.GetChannel()]
+	if !ok {
+		return
+	}
+
+	for sequence, p := range sd.pending {
+		acked := int32(sequence-a.GetSequence()) <= 0
+		if !acked {
+			bit := sequence - a.GetSequence() - 1
+			acked = bit < ackWindow && a.GetReceived()&(1<<bit) != 0
+		}
+		if !acked {
+			continue
+		}
+
+		delete(p.waiting, from)
+		if len(p.waiting) == 0 {
+			delete(sd.pending, sequence)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package reliable
+
+import (
+	"o2/client/protocol03"
+	"testing"
+	"time"
+)
+
+// hello makes the session aware of a peer the way any broadcast from that peer would
+func hello(s *Session, from uint32, now time.Time) {
+	s.Receive(&protocol03.GroupMessage{
+		PlayerIndex:  from,
+		BroadcastAll: &protocol03.BroadcastAll{},
+	}, now)
+}
+
+func broadcast(data byte) *protocol03.GroupMessage {
+	return &protocol03.GroupMessage{
+		BroadcastAll: &protocol03.BroadcastAll{Data: []byte{data}},
+	}
+}
+
+// relay copies the message as the server would, stamping the sender's player index
+func relay(gm *protocol03.GroupMessage, from uint32) *protocol03.GroupMessage {
+	return &protocol03.GroupMessage{
+		PlayerIndex:  from,
+		BroadcastAll: gm.GetBroadcastAll(),
+		Reliable:     gm.GetReliable(),
+	}
+}
+
+func data(msgs []*protocol03.GroupMessage) (d []byte) {
+	for _, m := range msgs {
+		d = append(d, m.GetBroadcastAll().GetData()...)
+	}
+	return
+}
+
+func TestSession_InOrderDelivery(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx, rx := NewSession(), NewSession()
+	tx.SetLocalIndex(0)
+	rx.SetLocalIndex(1)
+	hello(tx, 1, now)
+
+	var sent []*protocol03.GroupMessage
+	for i := byte(0); i < 4; i++ {
+		gm := broadcast(i)
+		tx.Send(gm, 6, now)
+		sent = append(sent, relay(gm, 0))
+	}
+	if n := tx.Pending(); n != 4 {
+		t.Fatalf("Pending() = %d, want 4", n)
+	}
+
+	// deliver 0, drop 1, deliver 3 then 2:
+	d, ack := rx.Receive(sent[0], now)
+	if string(data(d)) != "\x00" || ack.GetSequence() != 0 || ack.GetReceived() != 0 {
+		t.Fatalf("unexpected delivery %v ack %v", d, ack)
+	}
+	d, ack = rx.Receive(sent[3], now)
+	if len(d) != 0 {
+		t.Fatalf("out of order message must be buffered; got %v", d)
+	}
+	if ack.GetSequence() != 0 || ack.GetReceived() != 1<<2 {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+	d, ack = rx.Receive(sent[2], now)
+	if len(d) != 0 || ack.GetReceived() != 1<<1|1<<2 {
+		t.Fatalf("unexpected delivery %v ack %v", d, ack)
+	}
+
+	// selective ack clears 0, 2 and 3 but not 1:
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 1, Ack: ack}, now)
+	if n := tx.Pending(); n != 1 {
+		t.Fatalf("Pending() = %d, want 1", n)
+	}
+
+	if r := tx.Retransmit(now); len(r) != 0 {
+		t.Fatalf("retransmit before timeout: %v", r)
+	}
+	now = now.Add(tx.RetransmitTimeout)
+	r := tx.Retransmit(now)
+	if string(data(r)) != "\x01" || r[0].GetReliable().GetSequence() != 1 {
+		t.Fatalf("expected retransmit of sequence 1; got %v", r)
+	}
+
+	d, ack = rx.Receive(relay(r[0], 0), now)
+	if string(data(d)) != "\x01\x02\x03" {
+		t.Fatalf("expected in order delivery of 1, 2, 3; got %v", data(d))
+	}
+	if ack.GetSequence() != 3 || ack.GetReceived() != 0 {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+
+	// duplicates are acked but not delivered again:
+	d, ack = rx.Receive(relay(r[0], 0), now)
+	if len(d) != 0 || ack.GetSequence() != 3 {
+		t.Fatalf("unexpected delivery %v ack %v", d, ack)
+	}
+
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 1, Ack: ack}, now)
+	if n := tx.Pending(); n != 0 {
+		t.Fatalf("Pending() = %d, want 0", n)
+	}
+}
+
+func TestSession_ChannelsAreIndependent(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx, rx := NewSession(), NewSession()
+	tx.SetLocalIndex(0)
+	rx.SetLocalIndex(1)
+	hello(tx, 1, now)
+
+	a0, a1, b0 := broadcast('a'), broadcast('A'), broadcast('b')
+	tx.Send(a0, 5, now)
+	tx.Send(a1, 5, now)
+	tx.Send(b0, 6, now)
+
+	rx.Receive(relay(a0, 0), now)
+	// a gap on channel 5 must not hold up channel 6:
+	d, _ := rx.Receive(relay(b0, 0), now)
+	if string(data(d)) != "b" {
+		t.Fatalf("expected delivery of b; got %q", data(d))
+	}
+	d, _ = rx.Receive(relay(a1, 0), now)
+	if string(data(d)) != "A" {
+		t.Fatalf("expected delivery of A; got %q", data(d))
+	}
+}
+
+func TestSession_AckFromEveryPeer(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx := NewSession()
+	tx.SetLocalIndex(0)
+	hello(tx, 1, now)
+	hello(tx, 2, now)
+
+	tx.Send(broadcast(0), 6, now)
+
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 1, Ack: &protocol03.Ack{TargetPlayerIndex: 0, Channel: 6, Sequence: 0}}, now)
+	if n := tx.Pending(); n != 1 {
+		t.Fatalf("Pending() = %d, want 1 until player 2 acks", n)
+	}
+	// acks meant for another player are ignored:
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 2, Ack: &protocol03.Ack{TargetPlayerIndex: 3, Channel: 6, Sequence: 0}}, now)
+	if n := tx.Pending(); n != 1 {
+		t.Fatalf("Pending() = %d, want 1", n)
+	}
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 2, Ack: &protocol03.Ack{TargetPlayerIndex: 0, Channel: 6, Sequence: 0}}, now)
+	if n := tx.Pending(); n != 0 {
+		t.Fatalf("Pending() = %d, want 0", n)
+	}
+}
+
+func TestSession_GiveUp(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx := NewSession()
+	tx.SetLocalIndex(0)
+	hello(tx, 1, now)
+
+	tx.Send(broadcast(0), 6, now)
+	for i := 0; i < tx.MaxRetransmits; i++ {
+		now = now.Add(tx.RetransmitTimeout)
+		hello(tx, 1, now)
+		if r := tx.Retransmit(now); len(r) != 1 {
+			t.Fatalf("retransmit %d: got %d messages, want 1", i, len(r))
+		}
+	}
+	now = now.Add(tx.RetransmitTimeout)
+	if r := tx.Retransmit(now); len(r) != 0 || tx.Pending() != 0 {
+		t.Fatalf("expected to give up after %d retransmits", tx.MaxRetransmits)
+	}
+
+	// a peer that goes silent is no longer waited on:
+	tx.Send(broadcast(1), 6, now)
+	now = now.Add(tx.PeerTimeout + time.Second)
+	if r := tx.Retransmit(now); len(r) != 0 || tx.Pending() != 0 {
+		t.Fatalf("expected silent peer to be dropped")
+	}
+}
+
+func TestSession_GapTimeout(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx, rx := NewSession(), NewSession()
+	tx.SetLocalIndex(0)
+	rx.SetLocalIndex(1)
+	hello(tx, 1, now)
+
+	m := [3]*protocol03.GroupMessage{broadcast(0), broadcast(1), broadcast(2)}
+	for i := range m {
+		tx.Send(m[i], 6, now)
+	}
+
+	rx.Receive(relay(m[0], 0), now)
+	if d, _ := rx.Receive(relay(m[2], 0), now); len(d) != 0 {
+		t.Fatalf("expected buffering; got %v", d)
+	}
+
+	// sequence 1 never arrives:
+	now = now.Add(rx.GapTimeout)
+	d, ack := rx.Receive(relay(m[2], 0), now)
+	if string(data(d)) != "\x02" || ack.GetSequence() != 2 {
+		t.Fatalf("expected skip past gap; got %v ack %v", data(d), ack)
+	}
+}
+
+func TestSession_PeerRestart(t *testing.T) {
+	now := time.Unix(1_600_000_000, 0)
+	tx, rx := NewSession(), NewSession()
+	tx.SetLocalIndex(0)
+	rx.SetLocalIndex(1)
+	hello(tx, 1, now)
+
+	var lastAck *protocol03.Ack
+	for i := 0; i < 10; i++ {
+		m := broadcast(byte(i))
+		tx.Send(m, 6, now)
+		_, lastAck = rx.Receive(relay(m, 0), now)
+	}
+	if lastAck.GetSequence() != 9 {
+		t.Fatalf("expected ack of sequence 9; got %v", lastAck)
+	}
+
+	// the peer restarts and comes back at the same index with its sequences starting over:
+	tx = NewSession()
+	tx.SetLocalIndex(0)
+	hello(tx, 1, now)
+	m := broadcast(0x10)
+	tx.Send(m, 6, now)
+
+	// an ack for the previous session that was still in flight must not ack the new one:
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 1, Ack: lastAck}, now)
+	if tx.Pending() != 1 {
+		t.Fatalf("expected a stale ack to be ignored; %d pending", tx.Pending())
+	}
+
+	d, ack := rx.Receive(relay(m, 0), now)
+	if string(data(d)) != "\x10" {
+		t.Fatalf("expected delivery after the peer restarted; got %v", data(d))
+	}
+	if ack.GetSequence() != 0 || ack.GetEpoch() != m.GetReliable().GetEpoch() {
+		t.Fatalf("expected ack of sequence 0 in the new epoch; got %v", ack)
+	}
+
+	tx.Receive(&protocol03.GroupMessage{PlayerIndex: 1, Ack: ack}, now)
+	if tx.Pending() != 0 {
+		t.Errorf("expected the new session's message to be acked; %d pending", tx.Pending())
+	}
+}
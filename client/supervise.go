@@ -0,0 +1,179 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"o2/util"
+	"time"
+)
+
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateRetrying
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateRetrying:
+		return "retrying"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int(s))
+	}
+}
+
+// State returns the current connection state and, when retrying, the delay until the next reconnect attempt
+func (c *Client) State() (state ConnectionState, retryIn time.Duration) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	return c.state, c.retryIn
+}
+
+func (c *Client) setState(state ConnectionState, retryIn time.Duration) {
+	c.stateLock.Lock()
+	c.state = state
+	c.retryIn = retryIn
+	onStateChanged := c.OnStateChanged
+	c.stateLock.Unlock()
+
+	if onStateChanged != nil {
+		onStateChanged(state, retryIn)
+	}
+}
+
+// Connect connects to the server at hostPort using the transport for the given scheme. Once connected, the
+// connection is supervised: if the transport fails it is re-dialed with exponential backoff until Disconnect is
+// called.
+func (c *Client) Connect(scheme string, hostPort string) (err error) {
+	if c.IsConnected() {
+		return fmt.Errorf("client: already connected")
+	}
+
+	// stop any reconnect attempts in progress:
+	c.stateLock.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.stateLock.Unlock()
+
+	c.setState(StateConnecting, 0)
+
+	var t Transport
+	t, err = dial(scheme, hostPort)
+	if err != nil {
+		c.setState(StateDisconnected, 0)
+		return
+	}
+
+	c.stateLock.Lock()
+	c.scheme = scheme
+	c.hostPort = hostPort
+	c.stop = make(chan struct{})
+	c.stateLock.Unlock()
+
+	c.useTransport(t)
+
+	return
+}
+
+// Disconnect disconnects from the server and stops any reconnect attempts
+func (c *Client) Disconnect() {
+	c.stateLock.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.stateLock.Unlock()
+
+	if t := c.currentTransport(); t != nil {
+		t.Disconnect()
+	}
+
+	c.setState(StateDisconnected, 0)
+}
+
+func (c *Client) useTransport(t Transport) {
+	c.transportLock.Lock()
+	c.transport = t
+	c.transportLock.Unlock()
+
+	c.setState(StateConnected, 0)
+
+	go c.readLoop(t)
+}
+
+// transportLost is called when transport t signals a disconnect
+func (c *Client) transportLost(t Transport) {
+	c.stateLock.Lock()
+	stop := c.stop
+	scheme, hostPort := c.scheme, c.hostPort
+	c.stateLock.Unlock()
+
+	if stop == nil {
+		// Disconnect was called:
+		return
+	}
+
+	log.Printf("client: connection to '%s://%s' lost; reconnecting\n", scheme, hostPort)
+	go c.reconnectLoop(stop, scheme, hostPort)
+}
+
+// must run in a goroutine
+func (c *Client) reconnectLoop(stop <-chan struct{}, scheme string, hostPort string) {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	backoff := c.MinBackoff
+	for {
+		c.setState(StateRetrying, backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		c.setState(StateConnecting, 0)
+		t, err := dial(scheme, hostPort)
+		if err == nil {
+			select {
+			case <-stop:
+				// Disconnect was called while dialing:
+				t.Disconnect()
+				return
+			default:
+			}
+
+			log.Printf("client: reconnected to '%s://%s'\n", scheme, hostPort)
+			c.useTransport(t)
+			return
+		}
+
+		log.Printf("client: reconnect to '%s://%s': %v\n", scheme, hostPort, err)
+
+		backoff *= 2
+		if backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"o2/streamclient"
+	"o2/udpclient"
+	"strings"
+)
+
+var ErrUnsupportedScheme = errors.New("client: unsupported scheme")
+
+// Transport carries protocol envelopes to and from a server. A nil packet read signals a disconnect.
+type Transport interface {
+	IsConnected() bool
+	Disconnect()
+
+	Write() chan<- []byte
+	Read() <-chan []byte
+}
+
+// Schemes supported in server URLs; udp is the default when no scheme is given
+const (
+	SchemeUDP       = "udp"
+	SchemeTCP       = "tcp"
+	SchemeWebSocket = "ws"
+	// SchemeLAN exchanges messages with peers on the local network by multicast instead of with a server
+	SchemeLAN = "lan"
+)
+
+// ParseServerURL parses a server address of the form `[scheme://]host[:port]` into a scheme and a host:port pair.
+// The scheme defaults to udp and the port defaults to defaultPort. The host of a lan:// URL is a multicast address
+// which defaults to DefaultLANGroupAddress.
+func ParseServerURL(serverURL string, defaultPort string) (scheme string, hostPort string, err error) {
+	scheme = SchemeUDP
+	hostPort = strings.TrimSpace(serverURL)
+	if i := strings.Index(hostPort, "://"); i >= 0 {
+		scheme = strings.ToLower(hostPort[:i])
+		hostPort = hostPort[i+3:]
+	}
+	// ignore any path:
+	hostPort = strings.TrimRight(hostPort, "/")
+
+	switch scheme {
+	case SchemeUDP, SchemeTCP, SchemeWebSocket:
+	case SchemeLAN:
+		if hostPort == "" {
+			hostPort = DefaultLANGroupAddress
+		}
+	default:
+		err = fmt.Errorf("%w '%s'", ErrUnsupportedScheme, scheme)
+		return
+	}
+
+	host, port, splitErr := net.SplitHostPort(hostPort)
+	if splitErr != nil {
+		var addrError *net.AddrError
+		if errors.As(splitErr, &addrError) && addrError.Err == "missing port in address" {
+			host = hostPort
+			port = defaultPort
+		} else {
+			err = splitErr
+			return
+		}
+	}
+	if host == "" {
+		err = fmt.Errorf("client: missing host in server url '%s'", serverURL)
+		return
+	}
+
+	hostPort = net.JoinHostPort(host, port)
+	return
+}
+
+// dial connects a new transport to the server at hostPort, resolving the host name anew
+func dial(scheme string, hostPort string) (t Transport, err error) {
+	switch scheme {
+	case SchemeUDP:
+		var addr *net.UDPAddr
+		addr, err = net.ResolveUDPAddr("udp", hostPort)
+		if err != nil {
+			return
+		}
+		u := udpclient.NewUDPClient("client")
+		if err = u.Connect(addr); err != nil {
+			return
+		}
+		t = u
+	case SchemeTCP, SchemeWebSocket:
+		sc := streamclient.NewStreamClient("client")
+		if err = sc.Connect(scheme, hostPort); err != nil {
+			return
+		}
+		t = sc
+	case SchemeLAN:
+		var l *LAN
+		if l, err = ListenLAN(hostPort); err != nil {
+			return
+		}
+		t = l
+	default:
+		err = fmt.Errorf("%w '%s'", ErrUnsupportedScheme, scheme)
+	}
+	return
+}
+
+func (c *Client) IsConnected() bool {
+	t := c.currentTransport()
+	if t == nil {
+		return false
+	}
+	return t.IsConnected()
+}
+
+func (c *Client) currentTransport() Transport {
+	c.transportLock.Lock()
+	defer c.transportLock.Unlock()
+	return c.transport
+}
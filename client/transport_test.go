@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseServerURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		scheme   string
+		hostPort string
+	}{
+		{"alttp.online", "udp", "alttp.online:4590"},
+		{"alttp.online:1234", "udp", "alttp.online:1234"},
+		{"udp://alttp.online", "udp", "alttp.online:4590"},
+		{"tcp://alttp.online:1234", "tcp", "alttp.online:1234"},
+		{"WS://alttp.online/", "ws", "alttp.online:4590"},
+		{" ws://[::1]:80 ", "ws", "[::1]:80"},
+		{"lan://", "lan", DefaultLANGroupAddress + ":4590"},
+		{"lan://239.1.2.3:5000", "lan", "239.1.2.3:5000"},
+	}
+	for _, tt := range tests {
+		scheme, hostPort, err := ParseServerURL(tt.url, "4590")
+		if err != nil {
+			t.Errorf("%q: %v", tt.url, err)
+			continue
+		}
+		if scheme != tt.scheme || hostPort != tt.hostPort {
+			t.Errorf("%q: got %s %s, want %s %s", tt.url, scheme, hostPort, tt.scheme, tt.hostPort)
+		}
+	}
+
+	if _, _, err := ParseServerURL("http://alttp.online", "4590"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Errorf("expected ErrUnsupportedScheme; got %v", err)
+	}
+	if _, _, err := ParseServerURL("tcp://", "4590"); err == nil {
+		t.Errorf("expected error for missing host")
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	acceptTimeout := func() net.Conn {
+		select {
+		case conn := <-accepted:
+			return conn
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for connection")
+		}
+		return nil
+	}
+
+	c := NewClient()
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = 4 * time.Millisecond
+
+	states := make(chan ConnectionState, 16)
+	c.OnStateChanged = func(state ConnectionState, retryIn time.Duration) { states <- state }
+
+	if err = c.Connect(SchemeTCP, ln.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	conn := acceptTimeout()
+	if state, _ := c.State(); state != StateConnected {
+		t.Fatalf("state = %v, want connected", state)
+	}
+
+	// drop the connection from the server side:
+	_ = conn.Close()
+	select {
+	case b := <-c.Read():
+		if b != nil {
+			t.Fatalf("expected nil packet to signal disconnect")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for disconnect")
+	}
+
+	conn = acceptTimeout()
+	defer conn.Close()
+
+	want := []ConnectionState{StateConnecting, StateConnected, StateRetrying, StateConnecting, StateConnected}
+	var seen []ConnectionState
+	for len(seen) < len(want) {
+		select {
+		case state := <-states:
+			seen = append(seen, state)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for reconnect; states %v", seen)
+		}
+	}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("states = %v, want %v", seen, want)
+	}
+
+	c.Disconnect()
+	if state, _ := c.State(); state != StateDisconnected {
+		t.Fatalf("state = %v, want disconnected", state)
+	}
+	select {
+	case <-accepted:
+		t.Fatal("must not reconnect after Disconnect")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"net"
+	"o2/server"
+	"o2/util"
+	"o2/util/env"
+	"time"
+)
+
+// build variables set via ldflags by goreleaser:
+var (
+	version string = "v0.0.0"
+	commit  string = "dirty"
+	date    string = "2021-05-03T00:17:00Z"
+	builtBy string = "go"
+)
+
+func init() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.LUTC)
+	log.Printf("o2-server %s %s built on %s by %s", version, commit, date, builtBy)
+}
+
+func main() {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	listenAddr := env.GetOrDefault("O2_SERVER_LISTEN_ADDR", ":4590")
+	// TCP and WebSocket clients connect on the same port number as UDP clients by default:
+	streamListenAddr := env.GetOrDefault("O2_SERVER_STREAM_LISTEN_ADDR", listenAddr)
+
+	s := server.NewServer()
+	if timeout, err := time.ParseDuration(env.GetOrDefault("O2_SERVER_CLIENT_TIMEOUT", server.DefaultClientTimeout.String())); err == nil {
+		s.ClientTimeout = timeout
+	} else {
+		log.Printf("o2-server: %v\n", err)
+	}
+
+	conn, err := net.ListenPacket("udp", listenAddr)
+	if err != nil {
+		log.Fatalf("o2-server: listen: %v\n", err)
+	}
+	defer conn.Close()
+
+	ln, err := net.Listen("tcp", streamListenAddr)
+	if err != nil {
+		log.Fatalf("o2-server: listen: %v\n", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				util.LogPanic(err)
+			}
+		}()
+
+		if err := s.ServeStream(ln); err != nil {
+			log.Printf("o2-server: serve stream: %v\n", err)
+		}
+	}()
+
+	if err = s.Serve(conn); err != nil {
+		log.Fatalf("o2-server: serve: %v\n", err)
+	}
+}
@@ -1,11 +1,14 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
-	"net"
+	"o2/client"
 	"o2/interfaces"
 	"o2/util/env"
+	"sync"
+	"time"
 )
 
 type ServerViewModel struct {
@@ -13,20 +16,34 @@ type ServerViewModel struct {
 
 	root *ViewModel
 
+	// lock guards the fields below against the client's reconnect supervisor and the view's JSON encoding, which
+	// both run on their own goroutines:
+	lock    sync.Mutex
 	isDirty bool
 
-	IsConnected bool   `json:"isConnected"`
-	HostName    string `json:"hostName"`
-	GroupName   string `json:"groupName"`
-	Team        uint8  `json:"team"`
-	PlayerName  string `json:"playerName"`
-}
-
-type ServerConfiguration struct {
+	IsConnected bool `json:"isConnected"`
+	// ConnectionState is one of "disconnected", "connecting", "connected" or "retrying":
+	ConnectionState string `json:"connectionState"`
+	// RetryAt is the time of the next reconnect attempt while retrying, in milliseconds since the Unix epoch:
+	RetryAt    int64  `json:"retryAt"`
 	HostName   string `json:"hostName"`
 	GroupName  string `json:"groupName"`
 	Team       uint8  `json:"team"`
 	PlayerName string `json:"playerName"`
+	// GroupSecret, when not empty, authenticates all messages sent to and received from the group:
+	GroupSecret string `json:"groupSecret"`
+	// DroppedPackets counts received packets that failed authentication:
+	DroppedPackets uint64 `json:"droppedPackets"`
+	// IsSpectating is true while observing the group without a ROM or SNES:
+	IsSpectating bool `json:"isSpectating"`
+}
+
+type ServerConfiguration struct {
+	HostName    string `json:"hostName"`
+	GroupName   string `json:"groupName"`
+	Team        uint8  `json:"team"`
+	PlayerName  string `json:"playerName"`
+	GroupSecret string `json:"groupSecret"`
 }
 
 func (v *ServerViewModel) LoadConfiguration(config *ServerConfiguration) {
@@ -36,15 +53,17 @@ func (v *ServerViewModel) LoadConfiguration(config *ServerConfiguration) {
 	}
 
 	args := &setFieldArgs{
-		HostName:   new(string),
-		GroupName:  new(string),
-		Team:       new(uint8),
-		PlayerName: new(string),
+		HostName:    new(string),
+		GroupName:   new(string),
+		Team:        new(uint8),
+		PlayerName:  new(string),
+		GroupSecret: new(string),
 	}
 	*args.HostName = config.HostName
 	*args.GroupName = config.GroupName
 	*args.Team = config.Team
 	*args.PlayerName = config.PlayerName
+	*args.GroupSecret = config.GroupSecret
 
 	cmd := setFieldCmd{v}
 	err := cmd.Execute(args)
@@ -60,46 +79,111 @@ func (v *ServerViewModel) SaveConfiguration(config *ServerConfiguration) {
 		return
 	}
 
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
 	config.HostName = v.HostName
 	config.GroupName = v.GroupName
 	config.PlayerName = v.PlayerName
 	config.Team = v.Team
+	config.GroupSecret = v.GroupSecret
 }
 
 func (v *ServerViewModel) Update() {
+	v.lock.Lock()
+	if droppedPackets := v.root.client.DroppedPackets(); droppedPackets != v.DroppedPackets {
+		v.DroppedPackets = droppedPackets
+		v.isDirty = true
+	}
+	if isSpectating := v.root.spectating; isSpectating != v.IsSpectating {
+		v.IsSpectating = isSpectating
+		v.isDirty = true
+	}
+	team, playerName := v.Team, v.PlayerName
+	v.lock.Unlock()
+
 	game := v.root.game
 	if game != nil {
-		game.Notify("team", v.Team)
-		game.Notify("playerName", v.PlayerName)
+		game.Notify("team", team)
+		game.Notify("playerName", playerName)
 	}
 }
 
 func NewServerViewModel(root *ViewModel) *ServerViewModel {
 	v := &ServerViewModel{
-		root:        root,
-		IsConnected: false,
-		HostName:    "alttp.online",
-		GroupName:   "group",
+		root:            root,
+		IsConnected:     false,
+		ConnectionState: client.StateDisconnected.String(),
+		HostName:        "alttp.online",
+		GroupName:       "group",
+	}
+
+	// reflect connection state changes from the client's reconnect supervisor:
+	if root.client != nil {
+		root.client.OnStateChanged = v.connectionStateChanged
 	}
 
 	v.commands = map[string]interfaces.Command{
-		"connect":    &ServerConnectCommand{v},
-		"disconnect": &ServerDisconnectCommand{v},
-		"setField":   &setFieldCmd{v},
+		"connect":        &ServerConnectCommand{v},
+		"disconnect":     &ServerDisconnectCommand{v},
+		"spectate":       &ServerSpectateCommand{v},
+		"stopSpectating": &ServerStopSpectatingCommand{v},
+		"setField":       &setFieldCmd{v},
 	}
 
 	return v
 }
 
+// connectionStateChanged is called on the client's reconnect supervisor goroutine
+func (v *ServerViewModel) connectionStateChanged(state client.ConnectionState, retryIn time.Duration) {
+	v.lock.Lock()
+	v.IsConnected = state == client.StateConnected
+	v.ConnectionState = state.String()
+	v.RetryAt = 0
+	if state == client.StateRetrying {
+		// send a deadline so the view can count down to it:
+		v.RetryAt = time.Now().Add(retryIn).UnixMilli()
+	}
+	v.isDirty = true
+	v.lock.Unlock()
+
+	v.root.NotifyViewOf("server", v)
+}
+
+// setConnected records the client's connection state after a connect or disconnect command
+func (v *ServerViewModel) setConnected(isConnected bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.IsConnected = isConnected
+	v.isDirty = true
+}
+
+// MarshalJSON encodes the view model while holding its lock
+func (v *ServerViewModel) MarshalJSON() ([]byte, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	// convert to a type without this method to use the default encoding:
+	type serverViewModel ServerViewModel
+	return json.Marshal((*serverViewModel)(v))
+}
+
 func (v *ServerViewModel) IsDirty() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
 	return v.isDirty
 }
 
 func (v *ServerViewModel) ClearDirty() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
 	v.isDirty = false
 }
 
 func (v *ServerViewModel) MarkDirty() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
 	v.isDirty = true
 }
 
@@ -128,41 +212,30 @@ func (ce *ServerConnectCommand) Execute(_ interfaces.CommandArgs) error {
 		vm.SaveConfiguration()
 	}()
 
-	if v.IsConnected {
+	v.lock.Lock()
+	isConnected, hostName, groupName := v.IsConnected, v.HostName, v.GroupName
+	v.lock.Unlock()
+	if isConnected {
 		return nil
 	}
 
-	host, port, err := net.SplitHostPort(v.HostName)
-	if err != nil {
-		if addrError, ok := err.(*net.AddrError); ok {
-			if addrError.Err == "missing port in address" {
-				host = v.HostName
-				port = defaultServerPort
-			}
-		} else {
-			return err
-		}
-	}
-
-	hostPort := net.JoinHostPort(host, port)
-	addr, err := net.ResolveUDPAddr("udp", hostPort)
+	scheme, hostPort, err := client.ParseServerURL(hostName, defaultServerPort)
 	if err != nil {
 		log.Printf("serverviewmodel: %v\n", err)
 		return err
 	}
 
-	err = vm.client.Connect(addr)
-	v.IsConnected = vm.client.IsConnected()
-	v.MarkDirty()
+	err = vm.client.Connect(scheme, hostPort)
+	v.setConnected(vm.client.IsConnected())
 	if err != nil {
 		log.Printf("serverviewmodel: %v\n", err)
 		return nil
 	}
 
-	log.Printf("client: set group '%s'\n", v.GroupName)
-	vm.client.SetGroup(v.GroupName)
+	log.Printf("client: set group '%s'\n", groupName)
+	vm.client.SetGroup(groupName)
 
-	vm.client.SetHostName(v.HostName)
+	vm.client.SetHostName(hostName)
 
 	return nil
 }
@@ -177,21 +250,41 @@ func (ce *ServerDisconnectCommand) Execute(_ interfaces.CommandArgs) error {
 	log.Println("serverviewmodel: disconnect()")
 
 	vm.client.Disconnect()
-	vm.serverViewModel.IsConnected = vm.client.IsConnected()
+	v.setConnected(vm.client.IsConnected())
 
-	vm.serverViewModel.MarkDirty()
 	vm.UpdateAndNotifyView()
 	vm.SaveConfiguration()
 
 	return nil
 }
 
+type ServerSpectateCommand struct{ v *ServerViewModel }
+
+func (ce *ServerSpectateCommand) CreateArgs() interfaces.CommandArgs { return nil }
+func (ce *ServerSpectateCommand) Execute(_ interfaces.CommandArgs) error {
+	log.Println("serverviewmodel: spectate()")
+
+	ce.v.root.tryCreateSpectator()
+	return nil
+}
+
+type ServerStopSpectatingCommand struct{ v *ServerViewModel }
+
+func (ce *ServerStopSpectatingCommand) CreateArgs() interfaces.CommandArgs { return nil }
+func (ce *ServerStopSpectatingCommand) Execute(_ interfaces.CommandArgs) error {
+	log.Println("serverviewmodel: stopSpectating()")
+
+	ce.v.root.stopSpectating()
+	return nil
+}
+
 type setFieldCmd struct{ v *ServerViewModel }
 type setFieldArgs struct {
-	HostName   *string `json:"hostName"`
-	GroupName  *string `json:"groupName"`
-	Team       *uint8  `json:"team"`
-	PlayerName *string `json:"playerName"`
+	HostName    *string `json:"hostName"`
+	GroupName   *string `json:"groupName"`
+	Team        *uint8  `json:"team"`
+	PlayerName  *string `json:"playerName"`
+	GroupSecret *string `json:"groupSecret"`
 }
 
 func (c *setFieldCmd) CreateArgs() interfaces.CommandArgs { return &setFieldArgs{} }
@@ -202,34 +295,52 @@ func (c *setFieldCmd) Execute(args interfaces.CommandArgs) error {
 		return fmt.Errorf("invalid args type for command")
 	}
 
-	vm := c.v.root
+	v := c.v
+	vm := v.root
 	game := vm.game
+	client := vm.client
 
 	if f.HostName != nil {
-		c.v.HostName = *f.HostName
-		c.v.MarkDirty()
+		v.lock.Lock()
+		v.HostName = *f.HostName
+		v.isDirty = true
+		v.lock.Unlock()
 	}
 	if f.GroupName != nil {
-		c.v.GroupName = *f.GroupName
-		client := vm.client
+		v.lock.Lock()
+		v.GroupName = *f.GroupName
+		v.isDirty = true
+		v.lock.Unlock()
 		if client != nil {
-			client.SetGroup(c.v.GroupName)
+			client.SetGroup(*f.GroupName)
 		}
-		c.v.MarkDirty()
 	}
 	if f.Team != nil {
-		c.v.Team = *f.Team
+		v.lock.Lock()
+		v.Team = *f.Team
+		v.isDirty = true
+		v.lock.Unlock()
 		if game != nil {
-			game.Notify("team", c.v.Team)
+			game.Notify("team", *f.Team)
 		}
-		c.v.MarkDirty()
 	}
 	if f.PlayerName != nil {
-		c.v.PlayerName = *f.PlayerName
+		v.lock.Lock()
+		v.PlayerName = *f.PlayerName
+		v.isDirty = true
+		v.lock.Unlock()
 		if game != nil {
-			game.Notify("playerName", c.v.PlayerName)
+			game.Notify("playerName", *f.PlayerName)
+		}
+	}
+	if f.GroupSecret != nil {
+		v.lock.Lock()
+		v.GroupSecret = *f.GroupSecret
+		v.isDirty = true
+		v.lock.Unlock()
+		if client != nil {
+			client.SetGroupSecret(*f.GroupSecret)
 		}
-		c.v.MarkDirty()
 	}
 
 	vm.UpdateAndNotifyView()
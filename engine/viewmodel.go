@@ -30,6 +30,8 @@ type ViewModel struct {
 
 	game   games.Game
 	client *client.Client
+	// spectating is true while game is a spectator created by tryCreateSpectator:
+	spectating bool
 
 	isLoadingConfig bool
 
@@ -313,13 +315,71 @@ func (vm *ViewModel) tryCreateGame() bool {
 
 	vm.rom = vm.nextRom
 	vm.factory = vm.nextFactory
+	vm.spectating = false
 
 	log.Println("viewmodel: tryCreateGame: create new game")
 	game := vm.factory.NewGame(vm.rom)
-	vm.game = game
 
 	// provide the game with its deps:
 	game.ProvideQueue(vm.dev)
+	vm.startGame(game)
+
+	return true
+}
+
+// tryCreateSpectator replaces any running game with a spectator game that only needs the server client to observe
+// the group's players
+func (vm *ViewModel) tryCreateSpectator() bool {
+	defer vm.UpdateAndNotifyView()
+
+	var factory games.SpectatorFactory
+	for _, name := range games.FactoryNames() {
+		f, _ := games.FactoryByName(name)
+		if sf, ok := f.(games.SpectatorFactory); ok {
+			factory = sf
+			break
+		}
+	}
+	if factory == nil {
+		vm.setStatus("No game providers support spectating")
+		return false
+	}
+
+	if vm.game != nil {
+		log.Println("viewmodel: tryCreateSpectator: stop game")
+		vm.game.Stop()
+	}
+
+	log.Println("viewmodel: tryCreateSpectator: create new spectator")
+	vm.spectating = true
+	vm.startGame(factory.NewSpectator())
+
+	return true
+}
+
+// stopSpectating stops the spectator game and recreates the game for the selected ROM, if any
+func (vm *ViewModel) stopSpectating() {
+	defer vm.UpdateAndNotifyView()
+
+	if !vm.spectating {
+		return
+	}
+	vm.spectating = false
+
+	if vm.nextRom != nil {
+		vm.tryCreateGame()
+		return
+	}
+
+	if vm.game != nil {
+		log.Println("viewmodel: stopSpectating: stop game")
+		vm.game.Stop()
+	}
+}
+
+func (vm *ViewModel) startGame(game games.Game) {
+	vm.game = game
+
 	game.ProvideClient(vm.client)
 	// intercept root.viewNotifier to let us cache viewModel updates from the game:
 	// game will notify us of its viewModel on Start()/Reset():
@@ -332,7 +392,7 @@ func (vm *ViewModel) tryCreateGame() bool {
 	// load configuration:
 	gameName := game.Name()
 	if gameConfig, ok := vm.config.Games[gameName]; ok {
-		log.Printf("viewmodel: tryCreateGame: %s: %s\n", gameName, gameConfig)
+		log.Printf("viewmodel: startGame: %s: %s\n", gameName, gameConfig)
 		game.LoadConfiguration(gameConfig)
 	}
 
@@ -344,17 +404,19 @@ func (vm *ViewModel) tryCreateGame() bool {
 		}()
 
 		// wait until the game is stopped:
-		<-vm.game.Stopped()
+		<-game.Stopped()
+		if vm.game != game {
+			// already replaced by a new game:
+			return
+		}
 		vm.game = nil
 		delete(vm.viewModels, "game")
 		vm.UpdateAndNotifyView()
 	}()
 
 	// start the game instance:
-	log.Println("viewmodel: tryCreateGame: start game")
+	log.Println("viewmodel: startGame: start game")
 	game.Start()
-
-	return true
 }
 
 func (vm *ViewModel) IsConnected() bool {
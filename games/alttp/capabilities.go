@@ -0,0 +1,134 @@
+package alttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"o2/client"
+	"o2/client/protocol03"
+	"strings"
+)
+
+// sync flags announced in Capabilities.SyncFlags:
+const (
+	syncFlagItems uint64 = 1 << iota
+	syncFlagDungeonItems
+	syncFlagProgress
+	syncFlagHearts
+	syncFlagSmallKeys
+	syncFlagUnderworld
+	syncFlagOverworld
+	syncFlagChests
+	syncFlagTunicColor
+)
+
+// romHashSize is how many bytes of the SHA-256 of the ROM contents are announced to identify the ROM
+const romHashSize = 16
+
+func hashROM(contents []byte) []byte {
+	sum := sha256.Sum256(contents)
+	return sum[:romHashSize]
+}
+
+func (g *Game) syncFlags() (flags uint64) {
+	for _, f := range []struct {
+		enabled bool
+		flag    uint64
+	}{
+		{g.SyncItems, syncFlagItems},
+		{g.SyncDungeonItems, syncFlagDungeonItems},
+		{g.SyncProgress, syncFlagProgress},
+		{g.SyncHearts, syncFlagHearts},
+		{g.SyncSmallKeys, syncFlagSmallKeys},
+		{g.SyncUnderworld, syncFlagUnderworld},
+		{g.SyncOverworld, syncFlagOverworld},
+		{g.SyncChests, syncFlagChests},
+		{g.SyncTunicColor, syncFlagTunicColor},
+	} {
+		if f.enabled {
+			flags |= f.flag
+		}
+	}
+	return
+}
+
+// capabilities describes the local client to peers so they can detect incompatible or mismatched configurations
+func (g *Game) capabilities() *protocol03.Capabilities {
+	return &protocol03.Capabilities{
+		ClientVersion:        client.Version,
+		GameName:             gameName,
+		SerializationVersion: SerializationVersion,
+		RomHash:              g.romHash,
+		SyncFlags:            g.syncFlags(),
+		ProtobufPayload:      true,
+	}
+}
+
+// checkCapabilities compares a peer's announced capabilities against our own. Peers playing a different game, a
+// different ROM or using a serialization version that cannot be decoded are incompatible and their data is not
+// merged; other differences only produce a warning.
+func (g *Game) checkCapabilities(caps *protocol03.Capabilities) (warning string, incompatible bool) {
+	var problems []string
+
+	if caps.GetGameName() != gameName {
+		problems = append(problems, fmt.Sprintf("playing %q", caps.GetGameName()))
+		incompatible = true
+	}
+	if caps.GetSerializationVersion() > 0xFF {
+		problems = append(problems, fmt.Sprintf("serialization version %#02x, expected %#02x", caps.GetSerializationVersion(), SerializationVersion))
+		incompatible = true
+	} else if problem, versionIncompatible := checkSerializationVersion(uint8(caps.GetSerializationVersion())); problem != "" {
+		problems = append(problems, problem)
+		incompatible = incompatible || versionIncompatible
+	}
+	// spectators have no ROM or sync options of their own to compare against:
+	if !g.spectator && !bytes.Equal(caps.GetRomHash(), g.romHash) {
+		problems = append(problems, "different ROM")
+		incompatible = true
+	}
+	if caps.GetClientVersion() != client.Version {
+		problems = append(problems, fmt.Sprintf("O2 version %s", caps.GetClientVersion()))
+	}
+	if !g.spectator && caps.GetSyncFlags() != g.syncFlags() {
+		problems = append(problems, "different sync options")
+	}
+
+	warning = strings.Join(problems, "; ")
+	return
+}
+
+// updatePlayerCapabilities records the capabilities a peer announced and flags the peer if it is incompatible
+func (g *Game) updatePlayerCapabilities(p *Player, caps *protocol03.Capabilities) {
+	warning, incompatible := g.checkCapabilities(caps)
+	p.Capabilities = caps
+
+	if warning != p.CompatibilityWarning {
+		if warning != "" {
+			log.Printf("alttp: player[%02x]: %s: %s\n", uint8(p.Index()), p.Name(), warning)
+		}
+		p.CompatibilityWarning = warning
+		g.shouldUpdatePlayersList = true
+	}
+
+	g.setPlayerIncompatible(p, incompatible)
+}
+
+// setPlayerIncompatible excludes or re-includes a player's data from syncing
+func (g *Game) setPlayerIncompatible(p *Player, incompatible bool) {
+	if p.incompatible == incompatible {
+		return
+	}
+
+	p.incompatible = incompatible
+	if incompatible {
+		// discard anything already received from the player:
+		if p.SRAM.data != nil {
+			*p.SRAM.data = [0x500]byte{}
+		}
+		p.WRAM = nil
+	}
+
+	g.activePlayersClean = false
+	g.shouldUpdatePlayersList = true
+}
@@ -0,0 +1,65 @@
+package alttp
+
+import (
+	"log"
+	"o2/client/capture"
+	"o2/util"
+	"path/filepath"
+	"time"
+)
+
+// startCapture starts capturing network packets to a new timestamped file in the captures configuration directory
+func (g *Game) startCapture() {
+	g.captureLock.Lock()
+	defer g.captureLock.Unlock()
+
+	if g.capture != nil {
+		return
+	}
+
+	dir, err := util.ConfigDir()
+	if err != nil {
+		log.Printf("alttp: capture: could not find configuration directory: %v\n", err)
+		return
+	}
+
+	w, path, err := capture.Create(filepath.Join(dir, "captures"), "alttp", time.Now())
+	if err != nil {
+		log.Printf("alttp: capture: %v\n", err)
+		return
+	}
+
+	log.Printf("alttp: capture: writing to '%s'\n", path)
+	g.capture = w
+}
+
+func (g *Game) stopCapture() {
+	g.captureLock.Lock()
+	defer g.captureLock.Unlock()
+
+	if g.capture == nil {
+		return
+	}
+
+	if err := g.capture.Close(); err != nil {
+		log.Printf("alttp: capture: close: %v\n", err)
+	}
+	g.capture = nil
+	log.Printf("alttp: capture: stopped\n")
+}
+
+// capturePacket records a packet if capturing is enabled
+func (g *Game) capturePacket(direction capture.Direction, b []byte) {
+	g.captureLock.Lock()
+	defer g.captureLock.Unlock()
+
+	if g.capture == nil {
+		return
+	}
+
+	if err := g.capture.Write(direction, time.Now(), b); err != nil {
+		log.Printf("alttp: capture: %v\n", err)
+		_ = g.capture.Close()
+		g.capture = nil
+	}
+}
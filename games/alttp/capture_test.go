@@ -0,0 +1,104 @@
+package alttp
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"o2/client/capture"
+	"o2/client/loopback"
+	"testing"
+)
+
+func TestGameSync_CaptureReplay(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// capture g2's session:
+	buf := &bytes.Buffer{}
+	w, err := capture.NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs[1].g.capture = w
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	runFrame()
+
+	// g1 picks up a small key:
+	gs[0].e.WRAM[0xF36F] = 1
+	runFrame()
+	runFrame()
+
+	if expected, actual := uint8(1), gs[1].e.WRAM[0xF36F]; expected != actual {
+		t.Fatalf("g2: expected wram[$%04x] == $%02x, got $%02x", 0xF36F, expected, actual)
+	}
+
+	gs[1].g.stopCapture()
+	records, err := capture.ReadAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inbound, outbound int
+	for _, rec := range records {
+		switch rec.Direction {
+		case capture.Inbound:
+			inbound++
+		case capture.Outbound:
+			outbound++
+		}
+	}
+	if inbound == 0 || outbound == 0 {
+		t.Fatalf("expected inbound and outbound packets to be captured, got %d and %d", inbound, outbound)
+	}
+
+	// replay g2's inbound packets into a fresh game:
+	replay := capture.NewReplayClient("test", records)
+	defer replay.Close()
+
+	r, err := createTestGameSync("VT test", "g2", replay, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.e.WRAM[0x10] = 0x07
+	r.e.WRAM[0x040C] = 0
+
+	// the original session handled all of these packets within a few frames:
+	for replay.Step() {
+		if len(replay.Read()) == cap(replay.Read()) {
+			gameHandleNet(r.g)
+		}
+	}
+	r.runFrame(t)
+	r.runFrame(t)
+
+	if expected, actual := gs[1].g.LocalPlayer().Index(), r.g.LocalPlayer().Index(); expected != actual {
+		t.Errorf("replay: expected player index %d, got %d", expected, actual)
+	}
+	if expected, actual := uint8(1), r.e.WRAM[0xF36F]; expected != actual {
+		t.Errorf("replay: expected wram[$%04x] == $%02x, got $%02x", 0xF36F, expected, actual)
+	}
+}
@@ -0,0 +1,104 @@
+package alttp
+
+import (
+	"fmt"
+	"log"
+	"o2/client/protocol03"
+	"strings"
+	"unicode"
+)
+
+// maxChatLength is the maximum number of characters of a chat message; longer messages are truncated
+const maxChatLength = 200
+
+// sanitizeChatText strips control characters and surrounding whitespace and truncates to maxChatLength characters
+func sanitizeChatText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+
+	if runes := []rune(text); len(runes) > maxChatLength {
+		text = string(runes[:maxChatLength])
+	}
+	return text
+}
+
+// locationName describes a Player.Location value
+func locationName(location uint32) string {
+	if location&(1<<16) != 0 {
+		if name, ok := underworldNames[uint16(location)]; ok {
+			return name
+		}
+		return fmt.Sprintf("room $%03x", uint16(location))
+	}
+
+	if name, ok := overworldNames[uint16(location)]; ok {
+		return name
+	}
+	return fmt.Sprintf("area $%02x", uint16(location))
+}
+
+// chatNotification formats a chat message from the player for the notification history
+func chatNotification(p *Player, chat *protocol03.Chat) string {
+	name := p.Name()
+	if name == "" {
+		name = fmt.Sprintf("player #%02x", p.Index())
+	}
+
+	text := sanitizeChatText(chat.GetText())
+	ping := chat.GetPing()
+	if ping == nil {
+		return fmt.Sprintf("%s: %s", name, text)
+	}
+
+	s := fmt.Sprintf(
+		"%s pinged %s ($%04x, $%04x)",
+		name,
+		locationName(ping.GetLocation()),
+		uint16(ping.GetX()),
+		uint16(ping.GetY()),
+	)
+	if text != "" {
+		s += ": " + text
+	}
+	return s
+}
+
+// SendChat sends a chat message and/or map ping to all other players in the group. The message is recorded in the
+// local notification history only once it has been queued for sending.
+func (g *Game) SendChat(text string, ping *protocol03.Ping) error {
+	text = sanitizeChatText(text)
+	if text == "" && ping == nil {
+		return fmt.Errorf("chat message is empty")
+	}
+
+	c := g.client
+	if c == nil || !c.IsConnected() {
+		return fmt.Errorf("not connected to a server")
+	}
+
+	chat := &protocol03.Chat{Text: text, Ping: ping}
+	p3msg := g.makeGroupMessage(c)
+	p3msg.Chat = chat
+	if err := g.writeGroupMessage(c, p3msg); err != nil {
+		return err
+	}
+
+	g.PushNotification(chatNotification(g.LocalPlayer(), chat))
+	return nil
+}
+
+// chatReceived records a chat message from a remote player in the notification history
+func (g *Game) chatReceived(p *Player, chat *protocol03.Chat) {
+	if sanitizeChatText(chat.GetText()) == "" && chat.GetPing() == nil {
+		return
+	}
+
+	notification := chatNotification(p, chat)
+	log.Printf("alttp: player[%02x]: chat: %s\n", uint8(p.Index()), notification)
+	g.PushNotification(notification)
+}
@@ -0,0 +1,137 @@
+package alttp
+
+import (
+	"fmt"
+	"log"
+	"o2/client/loopback"
+	"o2/client/protocol03"
+	"strings"
+	"testing"
+)
+
+func TestGame_SendChat_Loopback(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [3]gameSync
+	var viewModels [3]testViewModels
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		viewModels[i] = testViewModels{}
+		gs[i].g.ProvideViewModelContainer(viewModels[i])
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+	}
+	// names are only known once every player has joined:
+	for i := range gs {
+		gs[i].g.sendPlayerName()
+	}
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+	}
+
+	history := func(i int) []string {
+		h, _ := viewModels[i]["game/notification/history"].([]TimestampedNotification)
+		var messages []string
+		for _, n := range h {
+			if strings.Contains(n.Message, " joined") {
+				continue
+			}
+			messages = append(messages, n.Message)
+		}
+		return messages
+	}
+
+	// g1 sends a chat message:
+	cmd, err := gs[0].g.CommandFor("sendChat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := cmd.CreateArgs().(*sendChatArgs)
+	args.Text = "  hello\n"
+	if err = cmd.Execute(args); err != nil {
+		t.Fatal(err)
+	}
+
+	// g2 pings a location in the underworld:
+	cmd, err = gs[1].g.CommandFor("ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, x, y := uint32(1<<16|0x12), uint16(0x0234), uint16(0x0567)
+	if err = cmd.Execute(&pingArgs{Text: "here", Location: &location, X: &x, Y: &y}); err != nil {
+		t.Fatal(err)
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+	}
+
+	ping := fmt.Sprintf("g2 pinged %s ($0234, $0567): here", underworldNames[0x12])
+	expected := [3][]string{
+		{"g1: hello", ping},
+		{ping, "g1: hello"},
+		{"g1: hello", ping},
+	}
+	for i := range gs {
+		actual := history(i)
+		if fmt.Sprint(expected[i]) != fmt.Sprint(actual) {
+			t.Errorf("g%d: expected history %q, got %q", i+1, expected[i], actual)
+		}
+	}
+
+	// empty messages are not sent:
+	if err = gs[0].g.SendChat(" \t ", nil); err == nil {
+		t.Errorf("expected an error sending an empty chat message")
+	}
+
+	// nor are messages while disconnected, and they are not recorded locally as if they had been:
+	hub.Clients()[0].Disconnect()
+	if err = gs[0].g.SendChat("anyone there?", nil); err == nil {
+		t.Errorf("expected an error sending a chat message while disconnected")
+	}
+	if actual := history(0); fmt.Sprint(expected[0]) != fmt.Sprint(actual) {
+		t.Errorf("g1: expected history %q, got %q", expected[0], actual)
+	}
+}
+
+func TestSanitizeChatText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hello", "hello"},
+		{"  hi there \r\n", "hi there"},
+		{"a\x00b\x1bc", "abc"},
+		{strings.Repeat("é", maxChatLength+10), strings.Repeat("é", maxChatLength)},
+	}
+	for _, tt := range tests {
+		if got := sanitizeChatText(tt.text); got != tt.want {
+			t.Errorf("sanitizeChatText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestChatNotification(t *testing.T) {
+	p := &Player{IndexF: 3}
+	if expected, actual := "player #03: hi", chatNotification(p, &protocol03.Chat{Text: "hi"}); expected != actual {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+
+	p.NameF = "link"
+	chat := &protocol03.Chat{Ping: &protocol03.Ping{Location: 0xff, X: 1, Y: 2}}
+	if expected, actual := "link pinged area $ff ($0001, $0002)", chatNotification(p, chat); expected != actual {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
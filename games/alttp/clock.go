@@ -0,0 +1,199 @@
+package alttp
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// clockWindow is how many of the most recent echo samples the server clock is estimated from
+	clockWindow = 16
+	// clockMinDriftSamples is how many filtered samples are needed before drift is estimated
+	clockMinDriftSamples = 4
+	// clockMinDriftSpan is the shortest span of samples that drift is estimated over
+	clockMinDriftSpan = 5 * time.Second
+	// clockMaxDrift bounds the estimated drift, as NTP bounds clock frequency error to 500 PPM
+	clockMaxDrift = 500e-6
+	// clockSmoothing is the weight given to a new best sample when smoothing the offset
+	clockSmoothing = 0.25
+)
+
+type clockSample struct {
+	// local time at the midpoint of the round trip
+	local time.Time
+	// server clock minus local clock, assuming the round trip is symmetric
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// serverClock estimates the offset and drift of the server's clock relative to the local clock from echo round trips
+// in the manner of NTP. The server stamps each echo with its clock as it relays it, so each round trip gives an
+// offset accurate to within half its RTT. Queueing delays make round trips asymmetric, so only the sample with the
+// lowest RTT among recent samples is trusted and each trusted sample is smoothed into the offset estimate once.
+type serverClock struct {
+	lock sync.Mutex
+
+	samples [clockWindow]clockSample
+	count   int
+
+	hasOffset bool
+	// offset is valid at local time offsetAt and changes by drift per second after that:
+	offset   time.Duration
+	offsetAt time.Time
+	drift    float64
+	// errorBound is how far off the estimate may be; half the best RTT plus the dispersion of the samples:
+	errorBound time.Duration
+}
+
+// addSample adds an echo round trip sent and received at the given local times and stamped by the server in between
+func (c *serverClock) addSample(sent, serverTime, received time.Time) {
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		return
+	}
+
+	local := sent.Add(rtt / 2)
+	s := clockSample{
+		local:  local,
+		offset: serverTime.Sub(local),
+		rtt:    rtt,
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.samples[c.count%clockWindow] = s
+	c.count++
+
+	window := c.window()
+
+	// min-RTT filter:
+	best := window[0]
+	for _, w := range window[1:] {
+		if w.rtt < best.rtt {
+			best = w
+		}
+	}
+
+	if !c.hasOffset {
+		c.hasOffset = true
+		c.offset = best.offset
+		c.offsetAt = best.local
+	} else if best.local.After(c.offsetAt) {
+		// smooth each new best sample into the estimate only once:
+		predicted := c.offset + c.driftSince(best.local)
+		c.offset = predicted + time.Duration(float64(best.offset-predicted)*clockSmoothing)
+		c.offsetAt = best.local
+	}
+
+	// ignore samples delayed much longer than the best when estimating drift and dispersion:
+	filtered := make([]clockSample, 0, len(window))
+	for _, w := range window {
+		if w.rtt <= 2*best.rtt+time.Millisecond {
+			filtered = append(filtered, w)
+		}
+	}
+
+	c.drift = estimateDrift(filtered)
+
+	var dispersion time.Duration
+	for _, w := range filtered {
+		d := w.offset - (c.offset + c.driftSince(w.local))
+		if d < 0 {
+			d = -d
+		}
+		dispersion += d
+	}
+	dispersion /= time.Duration(len(filtered))
+
+	c.errorBound = best.rtt/2 + dispersion
+}
+
+// reset forgets all samples, e.g. when reconnecting to a server whose clock may differ
+func (c *serverClock) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.samples = [clockWindow]clockSample{}
+	c.count = 0
+	c.hasOffset = false
+	c.offset = 0
+	c.offsetAt = time.Time{}
+	c.drift = 0
+	c.errorBound = 0
+}
+
+// window returns the samples currently in the window; must be called under lock
+func (c *serverClock) window() []clockSample {
+	if c.count < clockWindow {
+		return c.samples[:c.count]
+	}
+	return c.samples[:]
+}
+
+// driftSince returns how much the offset has drifted between offsetAt and local; must be called under lock
+func (c *serverClock) driftSince(local time.Time) time.Duration {
+	return time.Duration(c.drift * float64(local.Sub(c.offsetAt)))
+}
+
+// estimateDrift fits a least-squares line to offset over local time and returns its slope
+func estimateDrift(samples []clockSample) float64 {
+	if len(samples) < clockMinDriftSamples {
+		return 0
+	}
+
+	first, last := samples[0].local, samples[0].local
+	for _, s := range samples[1:] {
+		if s.local.Before(first) {
+			first = s.local
+		}
+		if s.local.After(last) {
+			last = s.local
+		}
+	}
+	if last.Sub(first) < clockMinDriftSpan {
+		return 0
+	}
+
+	var sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x := s.local.Sub(first).Seconds()
+		y := s.offset.Seconds()
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(samples))
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+
+	drift := (n*sxy - sx*sy) / den
+	if drift > clockMaxDrift {
+		drift = clockMaxDrift
+	} else if drift < -clockMaxDrift {
+		drift = -clockMaxDrift
+	}
+	return drift
+}
+
+// serverTime converts a local time to server time; ok is false until the first sample is added
+func (c *serverClock) serverTime(local time.Time) (t time.Time, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.hasOffset {
+		return
+	}
+	return local.Add(c.offset).Add(c.driftSince(local)), true
+}
+
+// estimate returns the current offset, drift and error bound
+func (c *serverClock) estimate() (ok bool, offset time.Duration, drift float64, errorBound time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.hasOffset, c.offset, c.drift, c.errorBound
+}
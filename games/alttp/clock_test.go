@@ -0,0 +1,133 @@
+package alttp
+
+import (
+	"google.golang.org/protobuf/proto"
+	"math"
+	"o2/client"
+	"o2/client/protocol03"
+	"o2/snes"
+	"testing"
+	"time"
+)
+
+// simulateEcho adds an echo sample to c for a server clock that is offset and drifting from the local clock by the
+// given amounts and a round trip taking up and down one-way
+func simulateEcho(c *serverClock, sent time.Time, offset time.Duration, drift float64, epoch time.Time, up, down time.Duration) {
+	arrived := sent.Add(up)
+	serverTime := arrived.Add(offset).Add(time.Duration(drift * float64(arrived.Sub(epoch))))
+	c.addSample(sent, serverTime, arrived.Add(down))
+}
+
+func TestServerClock_NoSamples(t *testing.T) {
+	var c serverClock
+	if _, ok := c.serverTime(time.Now()); ok {
+		t.Errorf("expected no server time before the first sample")
+	}
+}
+
+func TestServerClock_AsymmetricLatency(t *testing.T) {
+	var c serverClock
+	epoch := time.Unix(1_600_000_000, 0)
+	offset := 2 * time.Second
+
+	for i := 0; i < 32; i++ {
+		sent := epoch.Add(time.Duration(i) * 500 * time.Millisecond)
+		up := 10 * time.Millisecond
+		if i%3 != 0 {
+			// most echoes are queued behind other traffic on the way up:
+			up += time.Duration(50+i*7) * time.Millisecond
+		}
+		simulateEcho(&c, sent, offset, 0, epoch, up, 10*time.Millisecond)
+	}
+
+	ok, estimated, _, errorBound := c.estimate()
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if d := estimated - offset; d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("expected offset %v, got %v", offset, estimated)
+	}
+	if errorBound < 10*time.Millisecond || errorBound > 50*time.Millisecond {
+		t.Errorf("expected error bound near half the min rtt; got %v", errorBound)
+	}
+
+	local := epoch.Add(time.Minute)
+	if st, _ := c.serverTime(local); st.Sub(local.Add(offset)).Abs() > time.Millisecond {
+		t.Errorf("expected server time %v, got %v", local.Add(offset), st)
+	}
+}
+
+func TestServerClock_Drift(t *testing.T) {
+	var c serverClock
+	epoch := time.Unix(1_600_000_000, 0)
+	offset := -300 * time.Millisecond
+	const drift = 100e-6
+
+	var sent time.Time
+	for i := 0; i < clockWindow; i++ {
+		sent = epoch.Add(time.Duration(i) * time.Second)
+		simulateEcho(&c, sent, offset, drift, epoch, 15*time.Millisecond, 15*time.Millisecond)
+	}
+
+	_, _, estimated, _ := c.estimate()
+	if math.Abs(estimated-drift) > 1e-6 {
+		t.Errorf("expected drift %v, got %v", drift, estimated)
+	}
+
+	// extrapolate ten seconds past the last sample:
+	local := sent.Add(10 * time.Second)
+	expected := local.Add(offset).Add(time.Duration(drift * float64(local.Sub(epoch))))
+	if st, _ := c.serverTime(local); st.Sub(expected).Abs() > time.Millisecond {
+		t.Errorf("expected server time %v, got %v; off by %v", expected, st, st.Sub(expected))
+	}
+
+	c.reset()
+	if _, ok := c.serverTime(local); ok {
+		t.Errorf("expected no server time after reset")
+	}
+}
+
+func TestGame_ServerNow_FloorWins(t *testing.T) {
+	g := newGame(&snes.ROM{})
+	epoch := time.Unix(1_600_000_000, 0)
+	offset := 3 * time.Second
+
+	now := epoch
+	g.localClock = func() time.Time { return now }
+
+	// every echo reply is queued on the way down, so the estimate lags the server by about half the asymmetry:
+	const up, down = 10 * time.Millisecond, 200 * time.Millisecond
+	for i := 0; i < 8; i++ {
+		simulateEcho(&g.clock, now, offset, 0, epoch, up, down)
+		now = now.Add(500 * time.Millisecond)
+	}
+
+	// then a message stamped by the server arrives quickly:
+	stamped := now.Add(offset)
+	now = now.Add(5 * time.Millisecond)
+	b, err := proto.MarshalOptions{}.MarshalAppend(client.MakePacket(0x03).Bytes(), &protocol03.GroupMessage{
+		PlayerIndex: 1,
+		ServerTime:  stamped.UnixNano(),
+		Ack:         &protocol03.Ack{TargetPlayerIndex: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.handleNetMessage(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// the floor is behind the server only by that message's 5ms trip down:
+	now = now.Add(100 * time.Millisecond)
+	floor := stamped.Add(100 * time.Millisecond)
+	estimated, ok := g.clock.serverTime(now)
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if !estimated.Before(floor) {
+		t.Fatalf("expected the estimate %v to lag the floor %v", estimated, floor)
+	}
+	if actual := g.ServerNow(); !actual.Equal(floor) {
+		t.Errorf("expected server now %v from the last server timestamp, got %v", floor, actual)
+	}
+}
@@ -0,0 +1,188 @@
+package alttp
+
+import (
+	"github.com/alttpo/snes/mapping/lorom"
+	"log"
+	"o2/client/loopback"
+	"o2/snes"
+	"o2/snes/emulated"
+	"testing"
+	"time"
+)
+
+// emulatedGameSync drives a Game against the emulated SNES driver instead of directly against an emulator.System
+type emulatedGameSync struct {
+	q *emulated.Queue
+	g *Game
+}
+
+// flush waits until the queue has executed every command enqueued so far
+func (gs *emulatedGameSync) flush(t testing.TB) {
+	done := make(chan struct{})
+	err := gs.q.Enqueue(snes.CommandWithCompletion{
+		Command:    &snes.NoOpCommand{},
+		Completion: func(snes.Command, error) { close(done) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for queue")
+	}
+}
+
+// enqueue enqueues the command sequence and waits for it to complete
+func (gs *emulatedGameSync) enqueue(t testing.TB, seq snes.CommandSequence) {
+	var errs []error
+	for i := range seq {
+		seq[i].Completion = func(cmd snes.Command, err error) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := seq.EnqueueTo(gs.q); err != nil {
+		t.Fatal(err)
+	}
+	gs.flush(t)
+	for _, err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// read reads the memory at the FX Pak Pro address through the queue
+func (gs *emulatedGameSync) read(t testing.TB, address uint32, size uint8) (data []byte) {
+	gs.enqueue(t, gs.q.MakeReadCommands([]snes.Read{
+		{
+			Address:    address,
+			Size:       size,
+			Completion: func(rsp snes.Response) { data = rsp.Data },
+		},
+	}, nil))
+	return
+}
+
+func (gs *emulatedGameSync) write(t testing.TB, address uint32, data ...byte) {
+	gs.enqueue(t, gs.q.MakeWriteCommands([]snes.Write{
+		{
+			Address: address,
+			Size:    uint8(len(data)),
+			Data:    data,
+		},
+	}, nil))
+}
+
+func (gs *emulatedGameSync) runFrame(t testing.TB) {
+	g := gs.g
+
+	gameHandleNet(g)
+
+	// do all WRAM + SRAM(shadow) reads through the queue:
+	q := make([]snes.Read, 0, 20)
+	for j := range g.priorityReads {
+		if g.priorityReads[j] == nil {
+			continue
+		}
+		q = append(q, g.priorityReads[j]...)
+		g.priorityReads[j] = nil
+	}
+
+	rsps := make([]snes.Response, 0, len(q))
+	for i := range q {
+		q[i].Completion = func(rsp snes.Response) { rsps = append(rsps, rsp) }
+	}
+	gs.enqueue(t, gs.q.MakeReadCommands(q, nil))
+	g.readMainComplete(rsps)
+
+	// wait for any update writes before running the frame:
+	gs.flush(t)
+	if !gs.q.StepFrame() {
+		t.Fatal("frame did not complete")
+	}
+}
+
+func createEmulatedGameSync(t testing.TB, playerName string, gs gameSync) (es emulatedGameSync) {
+	var err error
+	es.q, err = emulated.NewQueue(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { es.q.Close() })
+
+	// the test emulator's ROM is fully patched and set up; the emulated driver needs its main routing to return:
+	rom := make([]byte, len(gs.g.rom.Contents))
+	copy(rom, gs.e.ROM[:])
+	pakAddr, _ := lorom.BusAddressToPak(testROMBreakPoint)
+	rom[pakAddr] = 0x6B // RTL
+
+	path, seq := es.q.MakeUploadROMCommands("o2", "test.sfc", rom)
+	es.enqueue(t, seq)
+	es.enqueue(t, es.q.MakeBootROMCommands(path))
+
+	es.g = gs.g
+	es.g.local.NameF = playerName
+	es.g.ProvideQueue(es.q)
+	return
+}
+
+func TestEmulatedDriver_RunsPatchedROM(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	g1, err := createTestGameSync("VT test", "g1", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	gs, err := createTestGameSync("VT test", "g2", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2 := createEmulatedGameSync(t, "g2", gs)
+
+	// the patch's SRAM initialization ran at boot:
+	offs := preMainAddr & 0x7FFF
+	if expected, actual := g1.e.SRAM[offs], g2.read(t, 0xE0_0000+offs, 1)[0]; expected != actual || expected == 0 {
+		t.Fatalf("expected sram[$%04x] == $%02x after boot, got $%02x", offs, expected, actual)
+	}
+
+	// each frame runs the main loop:
+	for i := 0; i < 3; i++ {
+		g2.q.StepFrame()
+	}
+	if expected, actual := uint8(3), g2.read(t, 0xF5_001A, 1)[0]; expected != actual {
+		t.Fatalf("expected wram[$1A] == $%02x, got $%02x", expected, actual)
+	}
+
+	// both players are in a dungeon and g1 has the hookshot:
+	g1.e.WRAM[0x10] = 0x07
+	g1.e.WRAM[0x040C] = 0
+	g1.e.WRAM[0xF342] = 1
+	g2.write(t, 0xF5_0010, 0x07)
+	g2.write(t, 0xF5_040C, 0)
+
+	for i := 0; i < 20; i++ {
+		g1.runFrame(t)
+		hub.Flush()
+		g2.runFrame(t)
+		hub.Flush()
+	}
+
+	// the update routine generated by g2 was executed by the emulated ROM:
+	if expected, actual := uint8(1), g2.read(t, 0xF5_F342, 1)[0]; expected != actual {
+		t.Errorf("expected wram[$F342] == $%02x, got $%02x", expected, actual)
+	}
+	if g2.g.updateStage != 0 {
+		t.Errorf("expected update to be confirmed; updateStage = %d", g2.g.updateStage)
+	}
+	if frames := g2.q.Frames(); frames != 23 {
+		t.Errorf("expected 23 frames, got %d", frames)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"testing"
 )
 
-func setupTestLogger(t *testing.T) (logger *testLogger) {
+func setupTestLogger(t testing.TB) (logger *testLogger) {
 	logger = &testLogger{}
 	logger.b.Grow(20000)
 	originalLogger := log.Writer()
@@ -3,6 +3,8 @@ package alttp
 import (
 	"encoding/json"
 	"log"
+	"o2/client/capture"
+	"o2/client/reliable"
 	"o2/engine"
 	"o2/games"
 	"o2/interfaces"
@@ -21,6 +23,10 @@ const MaxPlayers = 256
 type Game struct {
 	// rom cannot be nil
 	rom *snes.ROM
+	// identifies the ROM to peers; see capabilities:
+	romHash []byte
+	// spectator games only observe the group's players; see NewSpectator:
+	spectator bool
 
 	// queue can be nil at any time
 	queue snes.Queue
@@ -52,6 +58,16 @@ type Game struct {
 	lastServerTime     time.Time // server's clock when the echo message arrived at server
 	lastServerSentTime time.Time // our local clock when we sent the echo message
 	lastServerRecvTime time.Time // our local clock when we received the echo reply
+	// estimates the server's clock from echo round trips:
+	clock serverClock
+	// localClock is the local clock the server's clock is estimated against; time.Now if nil:
+	localClock func() time.Time
+
+	netStats netStats
+
+	// network capture for offline replay; nil when not capturing:
+	captureLock sync.Mutex
+	capture     *capture.Writer
 
 	running bool
 	stopped chan struct{}
@@ -80,6 +96,25 @@ type Game struct {
 	locHashTTL int
 	locHash    uint64
 
+	doorHashTTL int
+	doorHash    uint64
+
+	// player index last assigned by the server; requested again when rejoining:
+	lastJoinedIndex int
+	// identifies the local player to its peers across reconnects:
+	playerID PlayerID
+	// state of players that lost their index to another player, by ID; see rejoinPlayer:
+	departedPlayers map[PlayerID]Player
+
+	// reliable delivery of WRAM/SRAM sync messages:
+	reliable    *reliable.Session
+	syncHashTTL [syncSectionCount]int
+	syncHash    [syncSectionCount]uint64
+
+	// delta-encoded SRAM sync broadcasts:
+	sramBaseline          [0x500]byte
+	sramKeyframeCountdown [syncSectionCount]int
+
 	// game-valid memory:
 	wram          [0x20000]byte
 	wramLastFrame [0x20000]byte
@@ -128,6 +163,8 @@ type Game struct {
 	SyncChests       bool   `json:"syncChests"`
 	lastSyncChests   bool
 	SyncTunicColor   bool `json:"syncTunicColor"`
+	ReliableDelivery bool `json:"reliableDelivery"`
+	CaptureNetwork   bool `json:"captureNetwork"`
 }
 
 func (f *Factory) NewGame(rom *snes.ROM) games.Game {
@@ -139,8 +176,28 @@ func NewGame(rom *snes.ROM) (g *Game) {
 		panic("alttp: rom cannot be nil")
 	}
 
+	g = newGame(rom)
+	g.fillRomFunctions()
+
+	return g
+}
+
+func (f *Factory) NewSpectator() games.Game {
+	return NewSpectator()
+}
+
+// NewSpectator creates a Game that joins a group only to observe its players. A spectator needs no ROM or SNES; it
+// never reads from or writes to a snes.Queue and never sends sync data of its own.
+func NewSpectator() (g *Game) {
+	g = newGame(&snes.ROM{})
+	g.spectator = true
+	return g
+}
+
+func newGame(rom *snes.ROM) (g *Game) {
 	g = &Game{
 		rom:                   rom,
+		romHash:               hashROM(rom.Contents),
 		running:               false,
 		stopped:               make(chan struct{}),
 		readComplete:          make(chan []snes.Response, 8),
@@ -151,6 +208,9 @@ func NewGame(rom *snes.ROM) (g *Game) {
 		activePlayers:         make([]*Player, 0, MaxPlayers),
 		remotePlayers:         make([]*Player, 0, MaxPlayers),
 		remoteSyncablePlayers: make([]games.SyncablePlayer, 0, MaxPlayers),
+		lastJoinedIndex:       -1,
+		playerID:              newPlayerID(),
+		reliable:              reliable.NewSession(),
 		// ViewModel:
 		IsCreated:        true,
 		GameName:         gameName,
@@ -166,7 +226,6 @@ func NewGame(rom *snes.ROM) (g *Game) {
 	}
 
 	g.initSerde()
-	g.fillRomFunctions()
 
 	return g
 }
@@ -180,6 +239,9 @@ func (g *Game) Title() string {
 }
 
 func (g *Game) Description() string {
+	if g.spectator {
+		return "Spectator"
+	}
 	return strings.TrimRight(string(g.rom.Header.Title[:]), " ")
 }
 
@@ -223,6 +285,10 @@ func (g *Game) FirstFrame() {
 }
 
 func (g *Game) ProvideQueue(queue snes.Queue) {
+	if g.spectator {
+		// spectators never touch the SNES:
+		return
+	}
 	g.queue = queue
 
 	g.FirstFrame()
@@ -270,6 +336,7 @@ func (g *Game) Reset() {
 	g.ClearNotificationHistory()
 
 	// clear out players array:
+	g.departedPlayers = nil
 	for i := range g.players {
 		g.players[i] = Player{IndexF: -1, PlayerColor: 0x12ef}
 		g.players[i].SRAM.data = new([0x500]byte)
@@ -277,7 +344,7 @@ func (g *Game) Reset() {
 	}
 
 	// create a temporary Player instance until we get our Index assigned from the server:
-	g.local = &Player{IndexF: -1, PlayerColor: 0x12ef}
+	g.local = &Player{IndexF: -1, ID: g.playerID, PlayerColor: 0x12ef}
 	local := g.local
 	local.WRAM = make(map[uint16]*SyncableWRAM)
 	local.SRAM.data = (*[0x500]byte)(unsafe.Pointer(&g.wram[0xF000]))
@@ -323,6 +390,7 @@ func (g *Game) SoftReset() {
 	}
 
 	// clear out players array:
+	g.departedPlayers = nil
 	for i := range g.players {
 		g.players[i] = Player{IndexF: -1, PlayerColor: 0x12ef}
 		g.players[i].SRAM.data = new([0x500]byte)
@@ -330,7 +398,7 @@ func (g *Game) SoftReset() {
 	}
 
 	// create a temporary Player instance until we get our Index assigned from the server:
-	g.local = &Player{IndexF: -1, PlayerColor: 0x12ef}
+	g.local = &Player{IndexF: -1, ID: g.playerID, PlayerColor: 0x12ef}
 	local := g.local
 	local.WRAM = make(map[uint16]*SyncableWRAM)
 	local.SRAM.data = (*[0x500]byte)(unsafe.Pointer(&g.wram[0xF000]))
@@ -348,6 +416,10 @@ func (g *Game) Start() {
 	}
 	g.running = true
 
+	if g.CaptureNetwork {
+		g.startCapture()
+	}
+
 	g.NotifyView()
 
 	go func() {
@@ -356,6 +428,8 @@ func (g *Game) Start() {
 				util.LogPanic(err)
 			}
 
+			g.stopCapture()
+
 			// notify that the game is stopped:
 			close(g.stopped)
 		}()
@@ -364,7 +438,9 @@ func (g *Game) Start() {
 		g.run()
 	}()
 
-	go g.sendReads()
+	if !g.spectator {
+		go g.sendReads()
+	}
 }
 
 func (g *Game) Stopped() <-chan struct{} {
@@ -387,7 +463,7 @@ func (g *Game) ActivePlayers() []*Player {
 	if !g.activePlayersClean {
 		g.activePlayers = g.activePlayers[:0]
 		g.remotePlayers = g.remotePlayers[:0]
-		g.remoteSyncablePlayers = g.remoteSyncablePlayers[:]
+		g.remoteSyncablePlayers = g.remoteSyncablePlayers[:0]
 
 		for i, p := range g.players {
 			if p.Index() < 0 {
@@ -396,10 +472,15 @@ func (g *Game) ActivePlayers() []*Player {
 			if p.TTL() <= 0 {
 				continue
 			}
+			// a spectator is not one of the players:
+			if g.spectator && g.local == &g.players[i] {
+				continue
+			}
 
 			g.activePlayers = append(g.activePlayers, &g.players[i])
 
-			if g.local != &g.players[i] {
+			// incompatible players are listed but their data is not merged:
+			if g.local != &g.players[i] && !p.incompatible {
 				g.remotePlayers = append(g.remotePlayers, &g.players[i])
 				g.remoteSyncablePlayers = append(g.remoteSyncablePlayers, &g.players[i])
 			}
@@ -425,8 +506,23 @@ func (g *Game) RemoteSyncablePlayers() []games.SyncablePlayer {
 	return g.remoteSyncablePlayers
 }
 
+// ServerNow estimates the server's clock from echo round trips. The last server timestamp received plus the local
+// time elapsed since is a lower bound on the server's clock, since the server stamped the message before it arrived,
+// so the estimate never falls behind it.
 func (g *Game) ServerNow() time.Time {
-	return g.lastServerTime.Add(time.Now().Sub(g.lastServerRecvTime))
+	now := g.localNow()
+	floor := g.lastServerTime.Add(now.Sub(g.lastServerRecvTime))
+	if t, ok := g.clock.serverTime(now); ok && t.After(floor) {
+		return t
+	}
+	return floor
+}
+
+func (g *Game) localNow() time.Time {
+	if g.localClock == nil {
+		return time.Now()
+	}
+	return g.localClock()
 }
 
 // ServerSNESTimestamp returns ServerNow() time in milliseconds quantized to idealized SNES framerate
@@ -10,6 +10,7 @@ import (
 	"log"
 	"o2/client"
 	"o2/client/protocol03"
+	"o2/games"
 	"o2/interfaces"
 	"o2/snes"
 	"reflect"
@@ -22,6 +23,13 @@ type testClient struct {
 	Wr chan []byte
 }
 
+func newTestClient() *testClient {
+	return &testClient{
+		Rd: make(chan []byte, 100),
+		Wr: make(chan []byte, 100),
+	}
+}
+
 func (t *testClient) Group() []byte {
 	return []byte("test")
 }
@@ -41,6 +49,9 @@ func (t *testClient) Read() <-chan []byte {
 type testServer struct {
 	Clients []*testClient
 	Now     time.Time
+
+	// sectors holds the sector each client last reported being in:
+	sectors map[int]uint64
 }
 
 func (s *testServer) AdvanceTime(duration time.Duration) {
@@ -75,7 +86,14 @@ func (s *testServer) HandleMessageFromClient(t testing.TB, i int, b []byte) (err
 	gm.PlayerIndex = uint32(i)
 	gm.ServerTime = s.Now.UnixNano()
 
-	if gm.GetJoinGroup() != nil || gm.GetEcho() != nil || gm.GetBroadcastSector() != nil {
+	if s.sectors == nil {
+		s.sectors = make(map[int]uint64)
+	}
+	if protocol03.ReportsSector(&gm) {
+		s.sectors[i] = gm.GetPlayerInSector()
+	}
+
+	if gm.GetJoinGroup() != nil || gm.GetEcho() != nil {
 		pkt := client.MakePacket(protocol)
 		var rspBytes []byte
 		rspBytes, err = proto.Marshal(&gm)
@@ -93,9 +111,6 @@ func (s *testServer) HandleMessageFromClient(t testing.TB, i int, b []byte) (err
 		} else if gm.GetEcho() != nil {
 			pname = "echo"
 			pvalue = gm.GetEcho()
-		} else if gm.GetBroadcastSector() != nil {
-			pname = "broadcastSector"
-			pvalue = gm.GetBroadcastSector()
 		}
 		if t != nil {
 			log.Printf("server: c[%d] %s\n", i, pname)
@@ -121,8 +136,27 @@ func (s *testServer) HandleMessageFromClient(t testing.TB, i int, b []byte) (err
 			log.Printf("server: c[%d] -> c[%d] broadcastAll\n", i, j)
 			s.Clients[j].Rd <- pkt.Bytes()
 		}
+	} else if bs := gm.GetBroadcastSector(); bs != nil {
+		pkt := client.MakePacket(protocol)
+		var rspBytes []byte
+		rspBytes, err = proto.Marshal(&gm)
+		if err != nil {
+			return
+		}
+
+		pkt.Write(rspBytes)
+		for j := range s.Clients {
+			if j == i {
+				continue
+			}
+			if s.sectors[j] != bs.GetTargetSector() {
+				continue
+			}
+
+			log.Printf("server: c[%d] -> c[%d] broadcastSector\n", i, j)
+			s.Clients[j].Rd <- pkt.Bytes()
+		}
 	}
-	// TODO: proper handling of GetBroadcastSector()
 
 	return
 }
@@ -257,7 +291,18 @@ func (t *testQueue) IsTerminalError(err error) bool {
 	return false
 }
 
-func createTestGameSync(romTitle string, playerName string, logger io.Writer) (gs gameSync, err error) {
+func createTestGameSync(romTitle string, playerName string, c games.Client, logger io.Writer) (gs gameSync, err error) {
+	return createTestGameSyncWithClock(romTitle, playerName, c, logger, nil)
+}
+
+// createTestGameSyncWithClock creates a game whose local clock is localClock from the start, before its first echo
+func createTestGameSyncWithClock(
+	romTitle string,
+	playerName string,
+	c games.Client,
+	logger io.Writer,
+	localClock func() time.Time,
+) (gs gameSync, err error) {
 	var rom *snes.ROM
 
 	// ROM title must start with "VT " to indicate randomizer
@@ -267,11 +312,9 @@ func createTestGameSync(romTitle string, playerName string, logger io.Writer) (g
 	}
 
 	gs.g = CreateTestGame(rom, gs.e)
+	gs.g.localClock = localClock
 	gs.g.local.NameF = playerName
-	gs.c = &testClient{
-		Rd: make(chan []byte, 100),
-		Wr: make(chan []byte, 100),
-	}
+	gs.c = c
 	gs.g.ProvideClient(gs.c)
 
 	// request our player index:
@@ -356,7 +399,7 @@ func (gs *gameSync) runFrame(t testing.TB) {
 type gameSync struct {
 	e *emulator.System
 	g *Game
-	c *testClient
+	c games.Client
 	n []string
 }
 
@@ -388,23 +431,19 @@ func (tc *gameSyncTestCase) runFrame(t testing.TB, f *gameSyncTestFrame, duratio
 		return false
 	}
 
-	// run a single frame for each client:
+	// run a single frame for each client; the clients' clocks run on server time, so advance it per client to keep
+	// their frames from happening at the same instant:
 	for i := range tc.gs {
 		tc.gs[i].runFrame(t)
 		tc.s.HandleAllClients(t)
+		tc.s.AdvanceTime(duration)
 	}
 
 	// post-frame test validation:
 	if f.postFrame != nil {
 		f.postFrame(t, tc.gs)
 	}
-	if t.Failed() {
-		return false
-	}
-
-	// advance server time:
-	tc.s.AdvanceTime(duration)
-	return true
+	return !t.Failed()
 }
 
 func newGameSyncTestCase(romTitle string, f []gameSyncTestFrame) (tc *gameSyncTestCase) {
@@ -438,25 +477,27 @@ func (tc *gameSyncTestCase) runGameSyncTest(t *testing.T) {
 
 	logger := log.Writer().(*testLogger)
 
-	// create two independent clients and their respective emulators:
-	tc.gs[0], err = createTestGameSync(tc.romTitle, "g1", logger)
-	if err != nil {
-		t.Error(err)
-		return
-	}
-
-	tc.gs[1], err = createTestGameSync(tc.romTitle, "g2", logger)
-	if err != nil {
-		t.Error(err)
-		return
-	}
-
 	// create a mock server to facilitate network comms between the clients:
+	c1, c2 := newTestClient(), newTestClient()
 	tc.s = &testServer{
-		Clients: []*testClient{tc.gs[0].c, tc.gs[1].c},
+		Clients: []*testClient{c1, c2},
 		Now:     time.Now(),
 	}
 
+	// create two independent clients and their respective emulators. Each client's clock runs on the mock server's
+	// simulated time, skewed as real clocks are, so that echoes estimate the mock server's clock the way they would a
+	// real server's:
+	for i, c := range tc.s.Clients {
+		skew := time.Duration(2*i-1) * time.Minute
+		tc.gs[i], err = createTestGameSyncWithClock(tc.romTitle, fmt.Sprintf("g%d", i+1), c, logger, func() time.Time {
+			return tc.s.Now.Add(skew)
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
 	// issue join group messages:
 	tc.s.HandleAllClients(t)
 	tc.s.AdvanceTime(duration)
@@ -0,0 +1,601 @@
+package alttp
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log"
+	"o2/client"
+	"o2/client/loopback"
+	"o2/client/protocol03"
+	"testing"
+	"time"
+)
+
+func TestGameSync_Loopback_ThreePlayers(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	now := time.Now()
+	hub := loopback.NewHub()
+	hub.SetNow(func() time.Time { return now })
+
+	var gs [3]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+		now = now.Add(time.Millisecond * 17)
+	}
+
+	// issue join group messages and handle them:
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		if expected, actual := i, gs[i].g.LocalPlayer().Index(); expected != actual {
+			t.Fatalf("g%d: expected player index %d, got %d", i+1, expected, actual)
+		}
+	}
+
+	for i := range gs {
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	runFrame()
+
+	// every player should know about the other two:
+	for i := range gs {
+		if expected, actual := 2, len(gs[i].g.RemotePlayers()); expected != actual {
+			t.Errorf("g%d: expected %d remote players, got %d", i+1, expected, actual)
+		}
+	}
+
+	// g1 picks up a small key:
+	gs[0].e.WRAM[0xF36F] = 1
+	runFrame()
+
+	for i := 1; i < len(gs); i++ {
+		if expected, actual := uint8(1), gs[i].e.WRAM[0xF36F]; expected != actual {
+			t.Errorf("g%d: expected wram[$%04x] == $%02x, got $%02x", i+1, 0xF36F, expected, actual)
+		}
+		if expected, actual := uint8(1), gs[i].e.WRAM[smallKeyFirst]; expected != actual {
+			t.Errorf("g%d: expected wram[$%04x] == $%02x, got $%02x", i+1, smallKeyFirst, expected, actual)
+		}
+	}
+
+	for i := range gs {
+		if gs[i].g.netStats.recvByType[MsgLocation].packets == 0 {
+			t.Errorf("g%d: expected received location messages to be counted", i+1)
+		}
+	}
+}
+
+func TestGameSync_Loopback_ReliableRetransmit(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs[i].g.ReliableDelivery = true
+	}
+	// retransmit on the very next frame:
+	gs[0].g.reliable.RetransmitTimeout = 0
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	// let both players discover each other:
+	runFrame()
+	runFrame()
+
+	// g1 picks up a small key but the packets to g2 are lost:
+	gs[0].e.WRAM[0xF36F] = 1
+	gs[0].runFrame(t)
+	hub.Flush()
+	for len(gs[1].c.Read()) > 0 {
+		<-gs[1].c.Read()
+	}
+	if gs[0].g.reliable.Pending() == 0 {
+		t.Fatal("g1: expected reliable messages pending acks")
+	}
+
+	// g1 retransmits, g2 acks, g1 receives the ack:
+	runFrame()
+	runFrame()
+
+	if expected, actual := uint8(1), gs[1].e.WRAM[0xF36F]; expected != actual {
+		t.Errorf("g2: expected wram[$%04x] == $%02x, got $%02x", 0xF36F, expected, actual)
+	}
+	if actual := gs[0].g.reliable.Pending(); actual != 0 {
+		t.Errorf("g1: expected no reliable messages pending acks, got %d", actual)
+	}
+}
+
+func TestGameSync_Loopback_ReliableChannelPerSection(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gs[i].g.ReliableDelivery = true
+		gs[i].g.SyncUnderworld = true
+		gs[i].g.SyncOverworld = true
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	// let both players discover each other:
+	for f := 0; f < 2; f++ {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+	for len(gs[1].c.Read()) > 0 {
+		<-gs[1].c.Read()
+	}
+
+	// g1 changes every sync section at once:
+	for _, offs := range []uint16{0x36F, 0x340, 0x000, 0x280} {
+		gs[0].e.WRAM[0xF000+uint32(offs)] = 1
+		gs[0].g.local.SRAM.data[offs] = 1
+	}
+
+	channels := make(map[uint32]int)
+	for f := 0; f < 4; f++ {
+		gs[0].runFrame(t)
+		hub.Flush()
+	}
+	for len(gs[1].c.Read()) > 0 {
+		b := <-gs[1].c.Read()
+		var version uint8
+		r, err := client.ParseHeader(b, &version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+		gm := &protocol03.GroupMessage{}
+		if err = proto.Unmarshal(b, gm); err != nil {
+			t.Fatal(err)
+		}
+		if rel := gm.GetReliable(); rel != nil {
+			channels[rel.GetChannel()]++
+		}
+	}
+
+	// each sync section must be ordered independently so that a lost message does not hold up the others:
+	for section := syncSection(0); section < syncSectionCount; section++ {
+		if actual := channels[reliableChannel(section)]; actual != 1 {
+			t.Errorf("expected 1 reliable message on section %d's channel, got %d", section, actual)
+		}
+	}
+	if len(channels) != int(syncSectionCount) {
+		t.Errorf("expected %d reliable channels, got %v", syncSectionCount, channels)
+	}
+}
+
+func TestGameSync_Loopback_SectorBroadcast(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+		gs[i].e.WRAM[0x1B] = 1
+	}
+	// g1 and g2 are in different dungeon supertiles:
+	gs[0].e.WRAM[0xA0] = 0x12
+	gs[1].e.WRAM[0xA0] = 0x34
+	runFrame()
+	runFrame()
+
+	g1 := gs[0].g.LocalPlayer()
+	remote := &gs[1].g.players[g1.Index()]
+	if expected, actual := uint64(g1.Location), remote.Sector; expected != actual {
+		t.Errorf("g2: expected g1 in sector $%06x, got $%06x", expected, actual)
+	}
+	if _, ok := remote.WRAM[0x0400]; ok {
+		t.Errorf("g2: expected no door state from g1 in another sector")
+	}
+	if _, ok := remote.WRAM[smallKeyFirst]; !ok {
+		t.Errorf("g2: expected small keys from g1 in another sector")
+	}
+
+	// g2 enters g1's supertile:
+	gs[1].e.WRAM[0xA0] = 0x12
+	runFrame()
+	runFrame()
+
+	if _, ok := remote.WRAM[0x0400]; !ok {
+		t.Errorf("g2: expected door state from g1 in the same sector")
+	}
+
+	// unchanged door state is not resent every frame:
+	delete(remote.WRAM, 0x0400)
+	for f := 0; f < 8; f++ {
+		runFrame()
+	}
+	if _, ok := remote.WRAM[0x0400]; ok {
+		t.Errorf("g2: expected unchanged door state from g1 to not be resent")
+	}
+
+	// changed door state is sent right away:
+	gs[0].e.WRAM[0x0400] = 0x80
+	runFrame()
+	runFrame()
+	if w, ok := remote.WRAM[0x0400]; !ok || w.Value != 0x80 {
+		t.Errorf("g2: expected changed door state $80 from g1, got %+v", w)
+	}
+}
+
+func TestGameSync_Loopback_IncompatibleROM(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// g2 is playing a different ROM:
+	gs[1].g.romHash = hashROM([]byte("different"))
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	// announce capabilities now that both players have joined:
+	for i := range gs {
+		gs[i].g.sendPlayerName()
+	}
+	hub.Flush()
+	runFrame()
+	runFrame()
+
+	for i := range gs {
+		g := gs[i].g
+		if expected, actual := 2, len(g.ActivePlayers()); expected != actual {
+			t.Fatalf("g%d: expected %d active players, got %d", i+1, expected, actual)
+		}
+		if expected, actual := 0, len(g.RemotePlayers()); expected != actual {
+			t.Errorf("g%d: expected %d remote players, got %d", i+1, expected, actual)
+		}
+
+		remote := &g.players[1-g.LocalPlayer().Index()]
+		if !remote.incompatible {
+			t.Errorf("g%d: expected remote player to be incompatible", i+1)
+		}
+		if expected, actual := "different ROM", remote.CompatibilityWarning; expected != actual {
+			t.Errorf("g%d: expected warning %q, got %q", i+1, expected, actual)
+		}
+	}
+
+	// g1 picks up a small key which must not be merged into g2:
+	gs[0].e.WRAM[0xF36F] = 1
+	runFrame()
+	runFrame()
+
+	if expected, actual := uint8(0), gs[1].e.WRAM[0xF36F]; expected != actual {
+		t.Errorf("g2: expected wram[$%04x] == $%02x, got $%02x", 0xF36F, expected, actual)
+	}
+}
+
+func TestGameSync_Loopback_SerializationVersionMismatch(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+	}
+
+	// an older client that does not announce capabilities sends a broadcast with a version that cannot be decoded:
+	m := gs[1].g.makeBroadcastMessage()
+	m.Bytes()[0] = 0x13
+	gs[1].g.send(m)
+	hub.Flush()
+	gameHandleNet(gs[0].g)
+
+	remote := &gs[0].g.players[1]
+	if !remote.incompatible {
+		t.Errorf("expected remote player to be incompatible")
+	}
+	if remote.CompatibilityWarning == "" {
+		t.Errorf("expected a compatibility warning")
+	}
+	if expected, actual := 0, len(gs[0].g.RemotePlayers()); expected != actual {
+		t.Errorf("expected %d remote players, got %d", expected, actual)
+	}
+}
+
+type testViewModels map[string]interface{}
+
+func (v testViewModels) NotifyView(view string, viewModel interface{})   { v[view] = viewModel }
+func (v testViewModels) SetViewModel(view string, viewModel interface{}) { v[view] = viewModel }
+func (v testViewModels) GetViewModel(view string) (interface{}, bool) {
+	viewModel, ok := v[view]
+	return viewModel, ok
+}
+
+func TestGameSync_Loopback_Spectator(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	gs, err := createTestGameSync("VT test", "g1", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp := NewSpectator()
+	sp.Reset()
+	sp.ProvideClient(hub.NewClient("test"))
+	viewModels := testViewModels{}
+	sp.ProvideViewModelContainer(viewModels)
+	sp.send(sp.makeJoinMessage())
+
+	hub.Flush()
+	gameHandleNet(gs.g)
+	gameHandleNet(sp)
+
+	// g1 is in a dungeon and has a bow:
+	gs.e.WRAM[0x10] = 0x07
+	gs.e.WRAM[0x040C] = 0
+	gs.e.WRAM[0xF340] = 1
+	for i := 0; i < 30; i++ {
+		gs.runFrame(t)
+		gs.g.sendPlayerName()
+		hub.Flush()
+		gameHandleNet(sp)
+		sp.spectatorTick()
+		hub.Flush()
+	}
+	// as on the spectator's slowbeat:
+	sp.shouldUpdatePlayersList = true
+	sp.spectatorTick()
+
+	if expected, actual := 1, len(sp.ActivePlayers()); expected != actual {
+		t.Fatalf("expected %d active players, got %d", expected, actual)
+	}
+	if expected, actual := 0, len(gs.g.RemotePlayers()); expected != actual {
+		t.Errorf("expected spectator to be invisible to g1; got %d remote players", actual)
+	}
+	for msgType, count := range sp.netStats.sentByType {
+		if count.packets != 0 {
+			t.Errorf("expected spectator to send no broadcasts; sent %d %s", count.packets, MessageType(msgType))
+		}
+	}
+
+	players, ok := viewModels["game/players"].([]*PlayerViewModel)
+	if !ok || len(players) != 1 {
+		t.Fatalf("expected 1 player in game/players view model, got %v", viewModels["game/players"])
+	}
+	if expected, actual := "g1", players[0].Name; expected != actual {
+		t.Errorf("expected player name %q, got %q", expected, actual)
+	}
+	if expected, actual := []string{"Bow"}, players[0].Items; fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Errorf("expected items %v, got %v", expected, actual)
+	}
+}
+
+func TestGameSync_Loopback_RejoinNewIndex(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	g1, err := createTestGameSync("VT test", "g1", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewModels := testViewModels{}
+	g1.g.ProvideViewModelContainer(viewModels)
+	g2, err := createTestGameSync("VT test", "g2", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startTime := time.Unix(1_600_000_000, 0)
+	g2.g.local.GameStartTime = startTime
+
+	hub.Flush()
+	gameHandleNet(g1.g)
+	gameHandleNet(g2.g)
+	g2.g.sendPlayerName()
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	if expected, actual := "g2", g1.g.players[1].Name(); expected != actual {
+		t.Fatalf("expected player[01] to be %q, got %q", expected, actual)
+	}
+
+	// g2 drops and g3 takes its index before g2 reconnects:
+	c2 := g2.c.(*loopback.Client)
+	c2.Disconnect()
+	<-c2.Read()
+	g3, err := createTestGameSync("VT test", "g3", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+	gameHandleNet(g3.g)
+	g3.g.sendPlayerName()
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	if expected, actual := "g3", g1.g.players[1].Name(); expected != actual {
+		t.Fatalf("expected player[01] to be %q, got %q", expected, actual)
+	}
+	if !g1.g.players[1].GameStartTime.IsZero() {
+		t.Errorf("expected g3 not to inherit g2's start time")
+	}
+
+	c2.Reconnect()
+	g2.g.send(g2.g.makeJoinMessage())
+	hub.Flush()
+	gameHandleNet(g2.g)
+	if expected, actual := 2, g2.g.LocalPlayer().Index(); expected != actual {
+		t.Fatalf("expected g2 to rejoin as player %d, got %d", expected, actual)
+	}
+	g2.g.sendPlayerName()
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	p := &g1.g.players[2]
+	if expected, actual := "g2", p.Name(); expected != actual {
+		t.Fatalf("expected player[02] to be %q, got %q", expected, actual)
+	}
+	if !p.GameStartTime.Equal(startTime) {
+		t.Errorf("expected g2's start time %v to carry over, got %v", startTime, p.GameStartTime)
+	}
+	if expected, actual := 2, len(g1.g.RemotePlayers()); expected != actual {
+		t.Errorf("expected %d remote players, got %d", expected, actual)
+	}
+
+	// we drop and rejoin; nobody else left:
+	g1.g.playersDisconnected()
+	g2.g.sendPlayerName()
+	g3.g.sendPlayerName()
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	var notifications []string
+	history, _ := viewModels["game/notification/history"].([]TimestampedNotification)
+	for _, n := range history {
+		notifications = append(notifications, n.Message)
+	}
+	if expected, actual := []string{"g1 joined", "g2 joined", "g3 joined"}, notifications; fmt.Sprint(expected) != fmt.Sprint(actual) {
+		t.Errorf("expected notifications %q, got %q", expected, actual)
+	}
+}
+
+func TestGameSync_Loopback_LateJoinerSnapshots(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	g1, err := createTestGameSync("VT test", "g1", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+	gameHandleNet(g1.g)
+
+	// g1 is in a dungeon and has the hookshot:
+	g1.e.WRAM[0x10] = 0x07
+	g1.e.WRAM[0x040C] = 0
+	g1.e.WRAM[0xF342] = 1
+	for i := 0; i < 20; i++ {
+		g1.runFrame(t)
+		hub.Flush()
+	}
+	// g1 pauses; nothing more is broadcast:
+
+	g2, err := createTestGameSync("VT test", "g2", hub.NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2.e.WRAM[0x10] = 0x07
+	g2.e.WRAM[0x040C] = 0
+	hub.Flush()
+	gameHandleNet(g2.g)
+
+	if expected, actual := 1, len(g2.g.RemotePlayers()); expected != actual {
+		t.Fatalf("expected %d remote players, got %d", expected, actual)
+	}
+	if expected, actual := uint8(1), g2.g.players[0].SRAM.data[0x342]; expected != actual {
+		t.Errorf("expected g1's sram[$342] == $%02x from its snapshot, got $%02x", expected, actual)
+	}
+
+	for i := 0; i < 5; i++ {
+		g2.runFrame(t)
+	}
+	if expected, actual := uint8(1), g2.e.WRAM[0xF342]; expected != actual {
+		t.Errorf("expected wram[$F342] == $%02x, got $%02x", expected, actual)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"o2/client"
+	"o2/client/capture"
 	"o2/client/protocol01"
 	"o2/client/protocol02"
 	"o2/client/protocol03"
@@ -25,33 +26,56 @@ type gameBroadcastMessage struct {
 	bytes.Buffer
 
 	g *Game
+	// payload carries the same messages as the binary frame as records for peers that accept protobuf payloads
+	payload *protocol03.AlttpPayload
+	// messages records the size of each message written, in both formats
+	messages []sentMessage
+	// reliable is the channel to deliver the message reliably on; 0 for unreliable delivery
+	reliable uint32
+	// toSector sends the message only to players in the local player's sector
+	toSector bool
+	// announceCapabilities attaches the local capabilities to the message
+	announceCapabilities bool
+	// snapshot is the key the server keeps the message under for players that join later; 0 if it is not a snapshot
+	snapshot uint32
 }
 
 func (m *gameBroadcastMessage) SendToClient(c games.Client) {
 	g := m.g
 
 	if protocol == 0x03 {
-		p3msg := &protocol03.GroupMessage{
-			Group:          string(c.Group()),
-			PlayerTime:     time.Now().UnixNano(),
-			ServerTime:     0,
-			PlayerIndex:    uint32(g.LocalPlayer().IndexF),
-			PlayerInSector: uint64(g.LocalPlayer().Location),
+		p3msg := g.makeGroupMessage(c)
+		data, isPayload := m.Bytes(), false
+		if g.peersAcceptProtobufPayload() {
+			if b, err := marshalPayload(m.payload); err == nil {
+				data, isPayload = b, true
+			} else {
+				log.Printf("alttp: send: protobuf payload: %v\n", err)
+			}
 		}
-		//case protocol02.Broadcast:
-		p3msg.BroadcastAll = &protocol03.BroadcastAll{Data: m.Bytes()}
-		//case protocol02.BroadcastToSector:
-		//	p3msg.BroadcastSector = &protocol03.BroadcastSector{TargetSector: uint64(g.LocalPlayer().Location), Data: m.Bytes()}
-
-		// construct packet:
-		pkt := client.MakePacket(0x03)
-		b, err := proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), p3msg)
-		if err != nil {
-			log.Printf("alttp: send: proto.Marshal: %v\n", err)
-			return
+		if m.toSector {
+			p3msg.BroadcastSector = &protocol03.BroadcastSector{TargetSector: p3msg.PlayerInSector, Data: data}
+		} else {
+			p3msg.BroadcastAll = &protocol03.BroadcastAll{Data: data, Snapshot: m.snapshot}
+		}
+		if m.announceCapabilities {
+			p3msg.Capabilities = g.capabilities()
 		}
 
-		c.Write() <- b
+		if m.reliable != 0 {
+			// assign a sequence number and track acks for retransmission:
+			g.reliable.Send(p3msg, m.reliable, time.Now())
+		}
+
+		g.writeGroupMessage(c, p3msg)
+		// count each message at its size in the format that was sent, the same as received messages:
+		for _, sm := range m.messages {
+			if isPayload {
+				g.netStats.messageSent(sm.msgType, sm.payload)
+			} else {
+				g.netStats.messageSent(sm.msgType, sm.binary)
+			}
+		}
 	} else {
 		buf := protocol02.MakePacket(
 			c.Group(),
@@ -59,7 +83,7 @@ func (m *gameBroadcastMessage) SendToClient(c games.Client) {
 			uint16(g.LocalPlayer().IndexF),
 		)
 		m.WriteTo(buf)
-		c.Write() <- buf.Bytes()
+		g.writePacket(c, buf.Bytes())
 	}
 }
 
@@ -73,24 +97,15 @@ func (m *gameJoinMessage) SendToClient(c games.Client) {
 	g := m.g
 
 	if protocol == 0x03 {
-		p3msg := &protocol03.GroupMessage{
-			Group:          string(c.Group()),
-			PlayerTime:     time.Now().UnixNano(),
-			ServerTime:     0,
-			PlayerIndex:    uint32(g.LocalPlayer().IndexF),
-			PlayerInSector: uint64(g.LocalPlayer().Location),
-		}
-		p3msg.JoinGroup = &protocol03.JoinGroup{}
-
-		// construct packet:
-		pkt := client.MakePacket(0x03)
-		b, err := proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), p3msg)
-		if err != nil {
-			log.Printf("alttp: send: proto.Marshal: %v\n", err)
-			return
+		p3msg := g.makeGroupMessage(c)
+		p3msg.JoinGroup = &protocol03.JoinGroup{Capabilities: g.capabilities()}
+		if g.lastJoinedIndex >= 0 {
+			// try to keep the same player index when rejoining after a reconnect:
+			requested := uint32(g.lastJoinedIndex)
+			p3msg.JoinGroup.RequestedPlayerIndex = &requested
 		}
 
-		c.Write() <- b
+		g.writeGroupMessage(c, p3msg)
 	} else {
 		buf := protocol02.MakePacket(
 			c.Group(),
@@ -102,10 +117,13 @@ func (m *gameJoinMessage) SendToClient(c games.Client) {
 			log.Printf("alttp: send: writeTo: %v\n", err)
 			return
 		}
-		c.Write() <- buf.Bytes()
+		g.writePacket(c, buf.Bytes())
 	}
 }
 
+// broadcastHeaderSize is the size of the header written by makeBroadcastMessage
+const broadcastHeaderSize = 3
+
 func (g *Game) makeBroadcastMessage() (m *gameBroadcastMessage) {
 	m = &gameBroadcastMessage{g: g}
 
@@ -117,6 +135,12 @@ func (g *Game) makeBroadcastMessage() (m *gameBroadcastMessage) {
 	// frame number to correlate separate packets together:
 	m.WriteByte(g.lastGameFrame)
 
+	m.payload = &protocol03.AlttpPayload{
+		SerializationVersion: SerializationVersion,
+		Team:                 uint32(g.LocalPlayer().Team),
+		Frame:                uint32(g.lastGameFrame),
+	}
+
 	return
 }
 
@@ -125,6 +149,14 @@ func (g *Game) makeJoinMessage() (m *gameJoinMessage) {
 	return
 }
 
+// makeSectorMessage makes a broadcast message for location-local data that is only of interest to players in the
+// same sector as the local player. Sector messages are always delivered unreliably.
+func (g *Game) makeSectorMessage() (m *gameBroadcastMessage) {
+	m = g.makeBroadcastMessage()
+	m.toSector = true
+	return
+}
+
 type gameEchoMessage struct {
 	bytes.Buffer
 
@@ -135,24 +167,80 @@ func (m *gameEchoMessage) SendToClient(c games.Client) {
 	g := m.g
 
 	if protocol == 0x03 {
-		p3msg := &protocol03.GroupMessage{
-			Group:          string(c.Group()),
-			PlayerTime:     time.Now().UnixNano(),
-			ServerTime:     0,
-			PlayerIndex:    uint32(g.LocalPlayer().IndexF),
-			PlayerInSector: uint64(g.LocalPlayer().Location),
-		}
+		p3msg := g.makeGroupMessage(c)
 		p3msg.Echo = &protocol03.Echo{Data: m.Bytes()}
 
-		// construct packet:
-		pkt := client.MakePacket(0x03)
-		b, err := proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), p3msg)
-		if err != nil {
-			log.Printf("alttp: send: proto.Marshal: %v\n", err)
-			return
-		}
+		g.writeGroupMessage(c, p3msg)
+	}
+}
+
+// gameAckMessage acknowledges receipt of reliable broadcasts from another player
+type gameAckMessage struct {
+	g   *Game
+	ack *protocol03.Ack
+}
+
+func (m *gameAckMessage) SendToClient(c games.Client) {
+	p3msg := m.g.makeGroupMessage(c)
+	p3msg.Ack = m.ack
 
-		c.Write() <- b
+	m.g.writeGroupMessage(c, p3msg)
+}
+
+// gameRetransmitMessage resends a reliable broadcast as it was originally sent
+type gameRetransmitMessage struct {
+	g     *Game
+	p3msg *protocol03.GroupMessage
+}
+
+func (m *gameRetransmitMessage) SendToClient(c games.Client) {
+	m.g.writeGroupMessage(c, m.p3msg)
+}
+
+// localSector is the sector of the local player for interest management
+func (g *Game) localSector() uint64 {
+	return uint64(g.LocalPlayer().Location)
+}
+
+func (g *Game) makeGroupMessage(c games.Client) *protocol03.GroupMessage {
+	return &protocol03.GroupMessage{
+		Group:          string(c.Group()),
+		PlayerTime:     time.Now().UnixNano(),
+		ServerTime:     0,
+		PlayerIndex:    uint32(g.LocalPlayer().IndexF),
+		PlayerInSector: g.localSector(),
+		PlayerId:       g.playerID[:],
+	}
+}
+
+// writeGroupMessage writes a protocol 03 packet; the error is also logged so callers may ignore it
+func (g *Game) writeGroupMessage(c games.Client, p3msg *protocol03.GroupMessage) (err error) {
+	// construct packet:
+	pkt := client.MakePacket(0x03)
+	b, err := proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), p3msg)
+	if err != nil {
+		log.Printf("alttp: send: proto.Marshal: %v\n", err)
+		return
+	}
+
+	g.writePacket(c, b)
+	g.netStats.packetSent(len(b))
+	return
+}
+
+// writePacket writes a packet to the client, capturing it if enabled
+func (g *Game) writePacket(c games.Client, b []byte) {
+	g.capturePacket(capture.Outbound, b)
+	c.Write() <- b
+}
+
+func (g *Game) sendRetransmits() {
+	if g.client == nil || !g.client.IsConnected() {
+		return
+	}
+
+	for _, p3msg := range g.reliable.Retransmit(time.Now()) {
+		g.send(&gameRetransmitMessage{g: g, p3msg: p3msg})
 	}
 }
 
@@ -168,41 +256,58 @@ func (g *Game) send(m gameMessage) {
 	m.SendToClient(c)
 }
 
+// handleNetMessage handles a packet received from the server. Malformed packets are counted and dropped rather than
+// returned as errors.
 func (g *Game) handleNetMessage(msg []byte) (err error) {
 	var protocol uint8
 
+	g.capturePacket(capture.Inbound, msg)
+
 	r, err := client.ParseHeader(msg, &protocol)
 	if err != nil {
-		panic(fmt.Errorf("error parsing message header: %w", err))
+		g.droppedPacket(err)
+		return nil
 	}
 
+	g.netStats.packetReceived(len(msg))
+
 	switch protocol {
 	// old unused server protocol:
 	case 1:
 		var header protocol01.Header
 		err = protocol01.Parse(r, &header)
 		if err != nil {
-			panic(fmt.Errorf("error parsing protocol 01 header: %w", err))
+			g.droppedPacket(fmt.Errorf("error parsing protocol 01 header: %w", err))
+			return nil
 		}
 		if header.ClientType != 1 {
 			return
 		}
-		return g.Deserialize(r, &g.players[header.Index])
+		if int(header.Index) >= MaxPlayers {
+			g.droppedPacket(playerIndexError(int(header.Index)))
+			return nil
+		}
+		p := &g.players[header.Index]
+		if err = g.Deserialize(r, p); err != nil {
+			g.badPacketReceived(p, err)
+		}
+		return nil
 
 	// current production server protocol:
 	case 2:
 		var header protocol02.Header
 		err = protocol02.Parse(r, &header)
 		if err != nil {
-			panic(fmt.Errorf("error parsing protocol 02 header: %w", err))
+			g.droppedPacket(err)
+			return nil
 		}
 
 		index := int(header.Index)
 
 		// pre-emptively avoid panics in accessing players array out of bounds:
 		if index >= MaxPlayers {
-			log.Printf("alttp: player index %v received in packet beyond max player count %v!\n", header.Index, MaxPlayers)
-			return
+			g.droppedPacket(playerIndexError(index))
+			return nil
 		}
 
 		// reset player Ttl:
@@ -235,12 +340,13 @@ func (g *Game) handleNetMessage(msg []byte) (err error) {
 			err = g.Deserialize(r, p)
 			break
 		default:
-			panic(fmt.Errorf("unknown message kind %02x", header.Kind))
+			g.droppedPacket(fmt.Errorf("unknown message kind %02x", uint8(header.Kind)))
+			return nil
 		}
 
 		if err != nil {
-			log.Printf("alttp: net: deserialize: %v\n", err)
-			return
+			g.badPacketReceived(p, err)
+			return nil
 		}
 
 		g.SetTTL(p, 255)
@@ -265,79 +371,197 @@ func (g *Game) handleNetMessage(msg []byte) (err error) {
 		var b []byte
 		b, err = io.ReadAll(r)
 		if err != nil {
-			log.Printf("alttp: net: p3: readall: %v\n", err)
-			return
+			g.droppedPacket(&DeserializeError{Err: err})
+			return nil
 		}
 		err = proto.Unmarshal(b, gm)
 		if err != nil {
-			log.Printf("alttp: net: p3: unmarshal: %v\n", err)
-			return
+			g.droppedPacket(fmt.Errorf("p3: unmarshal: %w", err))
+			return nil
 		}
 
 		// record server time:
 		newServerTime := time.Unix((gm.GetServerTime())/1e9, int64(gm.GetServerTime()%1e9))
 		g.lastServerTime = newServerTime
-		g.lastServerRecvTime = time.Now()
+		g.lastServerRecvTime = g.localNow()
 		//log.Printf("server now(): %v\n", newServerTime.Add(time.Now().Sub(g.lastServerRecvTime)))
 
-		index := int(gm.PlayerIndex)
-
-		// pre-emptively avoid panics in accessing players array out of bounds:
-		if index >= MaxPlayers {
-			log.Printf("alttp: player index %v received in packet beyond max player count %v!\n", gm.PlayerIndex, MaxPlayers)
-			return
+		if gm.GetReplay() {
+			// snapshots replayed by the server are old news to the reliable session:
+			if err = g.handleGroupMessage(gm); err != nil {
+				g.droppedPacket(err)
+			}
+			return nil
 		}
 
-		// reset player Ttl:
-		p := &g.players[index]
-		p.IndexF = index
+		// order reliable broadcasts and handle acks:
+		deliver, ack := g.reliable.Receive(gm, time.Now())
+		if ack != nil {
+			g.send(&gameAckMessage{g: g, ack: ack})
+		}
 
-		// handle which kind of message it is:
-		if gm.GetJoinGroup() != nil {
-			// track local player index:
-			if (g.local.Index() < 0) || (g.local.Index() != index) {
-				if p != g.local {
-					// copy local player data into players array at the appropriate index:
-					g.players[index] = *g.local
-					// clear out old Player:
-					g.local.IndexF = -1
-					g.local.Ttl = 0
-				}
-				// repoint local into the array:
-				g.local = p
-				g.activePlayersClean = false
-				p.IndexF = index
+		for _, gm = range deliver {
+			if err = g.handleGroupMessage(gm); err != nil {
+				// drop the message without dropping the rest of the messages delivered with it:
+				g.droppedPacket(err)
 			}
-		} else if ba := gm.GetBroadcastAll(); ba != nil {
-			err = g.Deserialize(bytes.NewReader(ba.Data), p)
-		} else if bs := gm.GetBroadcastSector(); bs != nil {
-			err = g.Deserialize(bytes.NewReader(bs.Data), p)
-		} else if ec := gm.GetEcho(); ec != nil {
-			// nothing to do
 		}
 
-		if err != nil {
-			log.Printf("alttp: net: deserialize: %v\n", err)
+		return nil
+	default:
+		return
+	}
+}
+
+// deserializeBroadcast deserializes broadcast data from a player unless the player is incompatible
+func (g *Game) deserializeBroadcast(data []byte, p *Player, checkFrame bool) (err error) {
+	if p.incompatible {
+		return
+	}
+	if len(data) > 0 && data[0] == payloadProtobuf {
+		var pl *protocol03.AlttpPayload
+		if pl, err = unmarshalPayload(data); err != nil {
 			return
 		}
+		return g.deserializePayload(pl, p, checkFrame)
+	}
+	if len(data) > 0 && data[0] != SerializationVersion {
+		// a peer that never announced its capabilities may still be running an older or incompatible version:
+		warning, incompatible := checkSerializationVersion(data[0])
+		if p.CompatibilityWarning == "" {
+			p.CompatibilityWarning = warning
+			log.Printf("alttp: player[%02x]: %s: %s\n", uint8(p.Index()), p.Name(), p.CompatibilityWarning)
+			g.shouldUpdatePlayersList = true
+		}
+		if incompatible {
+			g.setPlayerIncompatible(p, true)
+			return
+		}
+	}
 
-		g.SetTTL(p, 255)
+	return g.deserialize(bytes.NewReader(data), p, checkFrame)
+}
 
-		// wait until we see a name packet to announce:
-		if p.showJoinMessage && p.Name() != "" {
-			log.Printf("alttp: player[%02x]: %s joined\n", uint8(p.Index()), p.Name())
-			g.PushNotification(fmt.Sprintf("%s joined", p.Name()))
-			p.showJoinMessage = false
-			g.activePlayersClean = false
-			g.shouldUpdatePlayersList = true
+// handleGroupMessage handles a protocol 03 message from a player. Malformed broadcast data is counted against the player
+// and dropped; a *DeserializeError is returned only for a message that cannot be attributed to a player.
+func (g *Game) handleGroupMessage(gm *protocol03.GroupMessage) (err error) {
+	index := int(gm.PlayerIndex)
+
+	// pre-emptively avoid panics in accessing players array out of bounds:
+	if index >= MaxPlayers {
+		return playerIndexError(index)
+	}
+
+	if gm.GetJoinGroup() == nil {
+		g.rejoinPlayer(index, playerIDFromBytes(gm.GetPlayerId()))
+	}
+
+	// reset player Ttl:
+	p := &g.players[index]
+	p.IndexF = index
+	if protocol03.ReportsSector(gm) {
+		if p != g.local && p.Sector != gm.GetPlayerInSector() && gm.GetPlayerInSector() == g.localSector() {
+			// resend the door state sooner for the player that just entered our sector:
+			g.doorHashTTL = 0
 		}
+		p.Sector = gm.GetPlayerInSector()
+	}
 
-		if g.shouldUpdatePlayersList {
-			g.updatePlayersList()
+	if caps := gm.GetCapabilities(); caps != nil && p != g.local {
+		g.updatePlayerCapabilities(p, caps)
+	}
+
+	// handle which kind of message it is:
+	if gm.GetJoinGroup() != nil {
+		// track local player index:
+		if (g.local.Index() < 0) || (g.local.Index() != index) {
+			if p != g.local {
+				// copy local player data into players array at the appropriate index:
+				g.players[index] = *g.local
+				// clear out old Player, which may be at our previous index:
+				g.vacatePlayer(g.local)
+			}
+			// repoint local into the array:
+			g.local = p
+			g.activePlayersClean = false
+			p.IndexF = index
+		}
+		g.reliable.SetLocalIndex(uint32(index))
+		g.lastJoinedIndex = index
+	} else if ba := gm.GetBroadcastAll(); ba != nil {
+		// reliable broadcasts are delivered in order and replayed snapshots are old so their frame numbers may be
+		// stale by design:
+		err = g.deserializeBroadcast(ba.Data, p, gm.GetReliable() == nil && !gm.GetReplay())
+	} else if bs := gm.GetBroadcastSector(); bs != nil {
+		err = g.deserializeBroadcast(bs.Data, p, gm.GetReliable() == nil)
+	} else if chat := gm.GetChat(); chat != nil {
+		if p != g.local {
+			g.chatReceived(p, chat)
 		}
+	} else if ec := gm.GetEcho(); ec != nil {
+		if seq, ok := parseEchoData(ec.Data); ok {
+			now := g.localNow()
+			if sent, ok := g.netStats.echoReceived(seq, now); ok && gm.GetServerTime() != 0 {
+				g.clock.addSample(sent, time.Unix(0, gm.GetServerTime()), now)
+			}
+		}
+	}
 
+	if err != nil {
+		// drop the malformed message without dropping the rest of the messages delivered with it:
+		g.badPacketReceived(p, err)
+		err = nil
 		return
-	default:
+	}
+
+	g.SetTTL(p, 255)
+
+	// wait until we see a name packet to announce:
+	if p.showJoinMessage && p.Name() != "" {
+		log.Printf("alttp: player[%02x]: %s joined\n", uint8(p.Index()), p.Name())
+		g.PushNotification(fmt.Sprintf("%s joined", p.Name()))
+		p.showJoinMessage = false
+		g.activePlayersClean = false
+		g.shouldUpdatePlayersList = true
+	}
+
+	if g.shouldUpdatePlayersList {
+		g.updatePlayersList()
+	}
+
+	return
+}
+
+// droppedPacket counts and logs a malformed packet that could not be attributed to a player; the log is rate-limited
+// so a noisy peer cannot flood it
+func (g *Game) droppedPacket(err error) {
+	shouldLog, unlogged := g.netStats.badPacketReceived(g.localNow())
+	if !shouldLog {
 		return
 	}
+	log.Printf("alttp: net: dropped bad packet: %v%s\n", err, unloggedSuffix(unlogged))
+}
+
+// playerIndexError describes a packet from a player index beyond MaxPlayers
+func playerIndexError(index int) error {
+	return &DeserializeError{Err: fmt.Errorf("player index %d: %w", index, ErrPlayerIndex)}
+}
+
+// badPacketReceived counts and logs a malformed packet from a player; whatever followed the malformed message in the
+// packet is dropped. The log is rate-limited together with droppedPacket.
+func (g *Game) badPacketReceived(p *Player, err error) {
+	p.BadPackets++
+	shouldLog, unlogged := g.netStats.badPacketReceived(g.localNow())
+	if !shouldLog {
+		return
+	}
+	log.Printf("alttp: player[%02x]: %s: dropped bad packet: %v%s\n", uint8(p.Index()), p.Name(), err, unloggedSuffix(unlogged))
+}
+
+// unloggedSuffix mentions the bad packets that were dropped without being logged
+func unloggedSuffix(unlogged uint64) string {
+	if unlogged == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d more bad packets not logged)", unlogged)
 }
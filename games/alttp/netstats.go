@@ -0,0 +1,290 @@
+package alttp
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// echoWindow is how many of the most recent echoes are considered when estimating packet loss
+	echoWindow = 32
+	// echoTimeout is how long to wait for an echo reply before counting the echo as lost
+	echoTimeout = 2 * time.Second
+	// badPacketLogInterval is the least time between log lines about dropped bad packets
+	badPacketLogInterval = time.Second
+)
+
+type echoSample struct {
+	seq     uint32
+	sent    time.Time
+	replied bool
+}
+
+type trafficCount struct {
+	packets uint64
+	bytes   uint64
+}
+
+func (t *trafficCount) add(bytes int) {
+	t.packets++
+	t.bytes += uint64(bytes)
+}
+
+// netStats accumulates network quality statistics for the "network" view model
+type netStats struct {
+	lock sync.Mutex
+
+	// smoothed round-trip time and jitter measured from echo replies, as in RFC 6298 and RFC 3550:
+	hasRTT  bool
+	rtt     time.Duration
+	lastRTT time.Duration
+	jitter  time.Duration
+
+	echoSeq uint32
+	echoes  [echoWindow]echoSample
+
+	sent       trafficCount
+	received   trafficCount
+	badPackets uint64
+	sentByType [MsgMaxMessageType]trafficCount
+	recvByType [MsgMaxMessageType]trafficCount
+
+	// when a bad packet was last logged and how many were dropped since without being logged:
+	lastBadPacketLog   time.Time
+	unloggedBadPackets uint64
+
+	// counters as of the last rate calculation:
+	lastRateTime       time.Time
+	lastSent           trafficCount
+	lastReceived       trafficCount
+	lastSentByType     [MsgMaxMessageType]trafficCount
+	lastRecvByType     [MsgMaxMessageType]trafficCount
+	sentRate           trafficRate
+	receivedRate       trafficRate
+	sentRateByType     [MsgMaxMessageType]trafficRate
+	receivedRateByType [MsgMaxMessageType]trafficRate
+}
+
+type trafficRate struct {
+	packetsPerSecond float64
+	bytesPerSecond   float64
+}
+
+func newTrafficRate(now, last trafficCount, elapsed time.Duration) trafficRate {
+	seconds := elapsed.Seconds()
+	return trafficRate{
+		packetsPerSecond: float64(now.packets-last.packets) / seconds,
+		bytesPerSecond:   float64(now.bytes-last.bytes) / seconds,
+	}
+}
+
+// echoSent records a new echo and returns the sequence number to send with it
+func (s *netStats) echoSent(now time.Time) uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.echoSeq++
+	s.echoes[s.echoSeq%echoWindow] = echoSample{seq: s.echoSeq, sent: now}
+	return s.echoSeq
+}
+
+// echoReceived records the reply to echo seq and updates the smoothed round-trip time and jitter. It returns when
+// the echo was sent; ok is false if the reply is too old, duplicated, or not one of ours.
+func (s *netStats) echoReceived(seq uint32, now time.Time) (sent time.Time, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e := &s.echoes[seq%echoWindow]
+	if e.seq != seq || e.replied || seq == 0 {
+		// too old, duplicated, or not one of ours:
+		return
+	}
+	e.replied = true
+	sent, ok = e.sent, true
+
+	rtt := now.Sub(e.sent)
+	if !s.hasRTT {
+		s.hasRTT = true
+		s.rtt = rtt
+		s.lastRTT = rtt
+		s.jitter = 0
+		return
+	}
+
+	s.rtt += (rtt - s.rtt) / 8
+
+	d := rtt - s.lastRTT
+	if d < 0 {
+		d = -d
+	}
+	s.jitter += (d - s.jitter) / 16
+	s.lastRTT = rtt
+	return
+}
+
+// loss estimates the fraction of recent echoes that went unanswered
+func (s *netStats) loss(now time.Time) float64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	considered, lost := 0, 0
+	for i := range s.echoes {
+		e := &s.echoes[i]
+		if e.seq == 0 {
+			continue
+		}
+		if e.replied {
+			considered++
+		} else if now.Sub(e.sent) >= echoTimeout {
+			considered++
+			lost++
+		}
+	}
+
+	if considered == 0 {
+		return 0
+	}
+	return float64(lost) / float64(considered)
+}
+
+// packetSent records an outgoing packet of n bytes
+func (s *netStats) packetSent(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sent.add(n)
+}
+
+// messageSent records an outgoing message of the given type that occupied n bytes of a packet
+func (s *netStats) messageSent(msgType MessageType, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.sentByType[msgType].add(n)
+}
+
+// packetReceived records an incoming packet of n bytes
+func (s *netStats) packetReceived(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.received.add(n)
+}
+
+// badPacketReceived records an incoming packet that was malformed and dropped. It reports whether the packet should be
+// logged, at most once per badPacketLogInterval, and how many bad packets were dropped without being logged before it.
+func (s *netStats) badPacketReceived(now time.Time) (shouldLog bool, unlogged uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.badPackets++
+	if !s.lastBadPacketLog.IsZero() && now.Sub(s.lastBadPacketLog) < badPacketLogInterval {
+		s.unloggedBadPackets++
+		return
+	}
+
+	shouldLog, unlogged = true, s.unloggedBadPackets
+	s.lastBadPacketLog = now
+	s.unloggedBadPackets = 0
+	return
+}
+
+// messageReceived records an incoming message of the given type that occupied n bytes of a packet
+func (s *netStats) messageReceived(msgType MessageType, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.recvByType[msgType].add(n)
+}
+
+// updateRates calculates the traffic rates since the last call
+func (s *netStats) updateRates(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.lastRateTime.IsZero() {
+		s.lastRateTime = now
+		return
+	}
+
+	elapsed := now.Sub(s.lastRateTime)
+	if elapsed <= 0 {
+		return
+	}
+
+	s.sentRate = newTrafficRate(s.sent, s.lastSent, elapsed)
+	s.receivedRate = newTrafficRate(s.received, s.lastReceived, elapsed)
+	for i := range s.sentByType {
+		s.sentRateByType[i] = newTrafficRate(s.sentByType[i], s.lastSentByType[i], elapsed)
+		s.receivedRateByType[i] = newTrafficRate(s.recvByType[i], s.lastRecvByType[i], elapsed)
+	}
+
+	s.lastRateTime = now
+	s.lastSent = s.sent
+	s.lastReceived = s.received
+	s.lastSentByType = s.sentByType
+	s.lastRecvByType = s.recvByType
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += n
+	return
+}
+
+func makeEchoData(seq uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], seq)
+	return b[:]
+}
+
+func parseEchoData(b []byte) (seq uint32, ok bool) {
+	if len(b) < 4 {
+		return
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+// snapshot builds the "network" view model from the statistics gathered so far
+func (s *netStats) snapshot(now time.Time) *NetworkViewModel {
+	loss := s.loss(now)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	vm := &NetworkViewModel{
+		HasRTT:               s.hasRTT,
+		RTTMs:                durationMs(s.rtt),
+		JitterMs:             durationMs(s.jitter),
+		PacketLoss:           loss,
+		SentPacketsPerSecond: s.sentRate.packetsPerSecond,
+		SentBytesPerSecond:   s.sentRate.bytesPerSecond,
+		RecvPacketsPerSecond: s.receivedRate.packetsPerSecond,
+		RecvBytesPerSecond:   s.receivedRate.bytesPerSecond,
+		BadPackets:           s.badPackets,
+		MessageTypes:         make([]*MessageTypeStatsViewModel, 0, MsgMaxMessageType),
+		Players:              make([]*PlayerNetworkViewModel, 0, MaxPlayers),
+	}
+	for t := MessageType(1); t < MsgMaxMessageType; t++ {
+		vm.MessageTypes = append(vm.MessageTypes, &MessageTypeStatsViewModel{
+			Name:                 t.String(),
+			SentPacketsPerSecond: s.sentRateByType[t].packetsPerSecond,
+			SentBytesPerSecond:   s.sentRateByType[t].bytesPerSecond,
+			RecvPacketsPerSecond: s.receivedRateByType[t].packetsPerSecond,
+			RecvBytesPerSecond:   s.receivedRateByType[t].bytesPerSecond,
+		})
+	}
+	return vm
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
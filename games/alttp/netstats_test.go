@@ -0,0 +1,150 @@
+package alttp
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"o2/client/loopback"
+	"testing"
+	"time"
+)
+
+func TestNetStats_RTT(t *testing.T) {
+	var s netStats
+	now := time.Now()
+
+	for i, rtt := range []time.Duration{100, 120, 80} {
+		sent := now.Add(time.Duration(i) * time.Second)
+		seq := s.echoSent(sent)
+		s.echoReceived(seq, sent.Add(rtt*time.Millisecond))
+	}
+
+	// srtt: 100 -> 102.5 -> 99.6875
+	if expected, actual := 99.6875, durationMs(s.rtt); expected != actual {
+		t.Errorf("expected rtt %vms, got %vms", expected, actual)
+	}
+	// jitter: 0 -> 1.25 -> 3.671875
+	if expected, actual := 3.671875, durationMs(s.jitter); expected != actual {
+		t.Errorf("expected jitter %vms, got %vms", expected, actual)
+	}
+
+	// duplicate and unknown replies are ignored:
+	s.echoReceived(3, now.Add(time.Hour))
+	s.echoReceived(99, now.Add(time.Hour))
+	if expected, actual := 99.6875, durationMs(s.rtt); expected != actual {
+		t.Errorf("expected rtt %vms, got %vms", expected, actual)
+	}
+}
+
+func TestNetStats_Loss(t *testing.T) {
+	var s netStats
+	now := time.Now()
+
+	if expected, actual := 0.0, s.loss(now); expected != actual {
+		t.Errorf("expected loss %v, got %v", expected, actual)
+	}
+
+	for i := 0; i < 4; i++ {
+		seq := s.echoSent(now)
+		if i != 0 {
+			s.echoReceived(seq, now.Add(50*time.Millisecond))
+		}
+	}
+	// an echo still awaiting its reply is not counted as lost:
+	s.echoSent(now.Add(time.Second))
+
+	if expected, actual := 0.25, s.loss(now.Add(echoTimeout)); expected != actual {
+		t.Errorf("expected loss %v, got %v", expected, actual)
+	}
+}
+
+func TestNetStats_Rates(t *testing.T) {
+	var s netStats
+	now := time.Now()
+
+	s.updateRates(now)
+	s.packetReceived(100)
+	s.packetReceived(50)
+	s.messageReceived(MsgSRAM, 40)
+	s.packetSent(30)
+	s.messageSent(MsgLocation, 30)
+	s.updateRates(now.Add(500 * time.Millisecond))
+
+	vm := s.snapshot(now)
+	if expected, actual := 4.0, vm.RecvPacketsPerSecond; expected != actual {
+		t.Errorf("expected %v received packets/s, got %v", expected, actual)
+	}
+	if expected, actual := 300.0, vm.RecvBytesPerSecond; expected != actual {
+		t.Errorf("expected %v received bytes/s, got %v", expected, actual)
+	}
+	if expected, actual := 60.0, vm.SentBytesPerSecond; expected != actual {
+		t.Errorf("expected %v sent bytes/s, got %v", expected, actual)
+	}
+
+	for _, mt := range vm.MessageTypes {
+		var expectedRecv, expectedSent float64
+		switch mt.Name {
+		case "sram":
+			expectedRecv = 80
+		case "location":
+			expectedSent = 60
+		}
+		if expectedRecv != mt.RecvBytesPerSecond {
+			t.Errorf("%s: expected %v received bytes/s, got %v", mt.Name, expectedRecv, mt.RecvBytesPerSecond)
+		}
+		if expectedSent != mt.SentBytesPerSecond {
+			t.Errorf("%s: expected %v sent bytes/s, got %v", mt.Name, expectedSent, mt.SentBytesPerSecond)
+		}
+	}
+}
+
+func TestNetStats_SentPerMessage(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	g1, g2 := gs[0].g, gs[1].g
+	// ignore anything sent while setting up:
+	g1.netStats, g2.netStats = netStats{}, netStats{}
+
+	m := testMessage(g1)
+	m.SendToClient(gs[0].c)
+
+	// sent messages must be booked per message type the same way the receiver books them:
+	if err := g2.deserialize(bytes.NewReader(m.Bytes()), &g2.players[1], false); err != nil {
+		t.Fatal(err)
+	}
+	for msgType := MessageType(1); msgType < MsgMaxMessageType; msgType++ {
+		sent, received := g1.netStats.sentByType[msgType], g2.netStats.recvByType[msgType]
+		if sent != received {
+			t.Errorf("%s: expected sent %+v to match received %+v", msgType, sent, received)
+		}
+	}
+	if sent := g1.netStats.sentByType[MsgSRAM]; sent.packets != 1 {
+		t.Errorf("expected 1 sram message sent, got %d", sent.packets)
+	}
+
+	// and the same for the protobuf payload records:
+	var payloadBytes, recvBytes uint64
+	for _, sm := range m.messages {
+		payloadBytes += uint64(sm.payload)
+	}
+	g2.netStats = netStats{}
+	if err := g2.deserializePayload(m.payload, &g2.players[1], false); err != nil {
+		t.Fatal(err)
+	}
+	for _, received := range g2.netStats.recvByType {
+		recvBytes += received.bytes
+	}
+	if payloadBytes != recvBytes {
+		t.Errorf("expected %d payload bytes sent to match %d received", payloadBytes, recvBytes)
+	}
+}
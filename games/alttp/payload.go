@@ -0,0 +1,259 @@
+package alttp
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"o2/client/protocol03"
+	"time"
+)
+
+// payloadProtobuf prefixes a broadcast payload encoded as a protocol03.AlttpPayload. The binary format starts with
+// its SerializationVersion byte instead, which never reaches this value.
+const payloadProtobuf = 0xFF
+
+// Every gameBroadcastMessage is built in both formats from the same game state: the binary frame for peers that do
+// not accept protobuf payloads and an AlttpPayload with one record per message for peers that do. The write methods
+// below append a message to both.
+
+// sentMessage is the size of a message written to a gameBroadcastMessage in each format, for the network statistics
+type sentMessage struct {
+	msgType MessageType
+	binary  int
+	payload int
+}
+
+// addRecord appends the record for the message of msgType that was serialized to the binary frame from start on
+func (m *gameBroadcastMessage) addRecord(msgType MessageType, start int, record *protocol03.AlttpRecord) {
+	m.payload.Records = append(m.payload.Records, record)
+	m.messages = append(m.messages, sentMessage{msgType: msgType, binary: m.Len() - start, payload: proto.Size(record)})
+}
+
+// writeLocation appends the player's location
+func (m *gameBroadcastMessage) writeLocation(p *Player) {
+	start := m.Len()
+	if err := m.g.SerializeLocation(p, m); err != nil {
+		panic(err)
+	}
+	m.addRecord(MsgLocation, start, &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_Location{Location: &protocol03.AlttpLocation{
+		Module:          uint32(p.Module),
+		SubModule:       uint32(p.SubModule),
+		SubSubModule:    uint32(p.SubSubModule),
+		Location:        p.Location,
+		X:               uint32(p.X),
+		Y:               uint32(p.Y),
+		Dungeon:         uint32(p.Dungeon),
+		DungeonEntrance: uint32(p.DungeonEntrance),
+		LastOverworldX:  uint32(p.LastOverworldX),
+		LastOverworldY:  uint32(p.LastOverworldY),
+		XOffs:           int32(p.XOffs),
+		YOffs:           int32(p.YOffs),
+		PlayerColor:     uint32(p.PlayerColor),
+	}}})
+}
+
+// writeWRAM appends count timestamped WRAM words of the player starting at start
+func (m *gameBroadcastMessage) writeWRAM(p *Player, start uint16, count uint8) {
+	msgStart := m.Len()
+	if err := m.g.SerializeWRAM(p, m, start, count); err != nil {
+		panic(err)
+	}
+
+	wram := &protocol03.AlttpWRAM{Start: uint32(start), Words: make([]*protocol03.AlttpWRAMWord, 0, count)}
+	for offs := start; offs < start+uint16(count); offs++ {
+		word := &protocol03.AlttpWRAMWord{}
+		if wv, ok := p.WRAM[offs]; ok {
+			word.Timestamp, word.Value = wv.Timestamp, uint32(wv.Value)
+		}
+		wram.Words = append(wram.Words, word)
+	}
+	m.addRecord(MsgWRAM, msgStart, &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_Wram{Wram: wram}})
+}
+
+// writeSRAM appends the player's SRAM[start:endExclusive]
+func (m *gameBroadcastMessage) writeSRAM(p *Player, start, endExclusive uint16) {
+	msgStart := m.Len()
+	if err := m.g.SerializeSRAM(p, m, start, endExclusive); err != nil {
+		panic(err)
+	}
+	m.addRecord(MsgSRAM, msgStart, &protocol03.AlttpRecord{
+		Record: &protocol03.AlttpRecord_Sram{Sram: sramRecord(p, start, endExclusive)},
+	})
+}
+
+// writeSRAMDelta appends the runs of bytes in the player's SRAM[start:endExclusive] that differ from baseline and then
+// updates baseline to match; nothing is appended if no bytes differ
+func (m *gameBroadcastMessage) writeSRAMDelta(p *Player, baseline *[0x500]byte, start, endExclusive uint16) {
+	// find the runs before SerializeSRAMDelta updates the baseline:
+	runs := sramDeltaRuns(p.SRAM.data, baseline, start, endExclusive)
+	if len(runs) == 0 {
+		return
+	}
+
+	delta := &protocol03.AlttpSRAMDelta{Runs: make([]*protocol03.AlttpSRAM, 0, len(runs))}
+	for _, run := range runs {
+		delta.Runs = append(delta.Runs, sramRecord(p, run.offset, run.offset+uint16(run.length)))
+	}
+
+	msgStart := m.Len()
+	if err := m.g.SerializeSRAMDelta(p, m, baseline, start, endExclusive); err != nil {
+		panic(err)
+	}
+	m.addRecord(MsgSRAMDelta, msgStart, &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_SramDelta{SramDelta: delta}})
+}
+
+// writePlayerName appends the player's name and game times
+func (m *gameBroadcastMessage) writePlayerName(p *Player) {
+	start := m.Len()
+	if err := m.g.SerializePlayerName(p, m); err != nil {
+		panic(err)
+	}
+
+	playerName := &protocol03.AlttpPlayerName{Name: p.Name()}
+	if !p.GameStartTime.IsZero() {
+		playerName.GameStartTime = p.GameStartTime.UnixNano()
+	}
+	if !p.GameFinishTime.IsZero() {
+		playerName.GameFinishTime = p.GameFinishTime.UnixNano()
+	}
+	m.addRecord(MsgPlayerName, start, &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_PlayerName{PlayerName: playerName}})
+}
+
+// sramRecord copies the player's SRAM[start:endExclusive] since the message may outlive the current frame
+func sramRecord(p *Player, start, endExclusive uint16) *protocol03.AlttpSRAM {
+	return &protocol03.AlttpSRAM{
+		Start: uint32(start),
+		Data:  append([]byte(nil), p.SRAM.data[start:endExclusive]...),
+	}
+}
+
+// marshalPayload encodes a protobuf payload prefixed with payloadProtobuf
+func marshalPayload(pl *protocol03.AlttpPayload) ([]byte, error) {
+	return proto.MarshalOptions{}.MarshalAppend([]byte{payloadProtobuf}, pl)
+}
+
+// unmarshalPayload decodes a protobuf payload prefixed with payloadProtobuf
+func unmarshalPayload(b []byte) (pl *protocol03.AlttpPayload, err error) {
+	if len(b) == 0 || b[0] != payloadProtobuf {
+		return nil, &DeserializeError{Offset: 0, Err: fmt.Errorf("missing protobuf payload prefix")}
+	}
+
+	pl = &protocol03.AlttpPayload{}
+	if err = proto.Unmarshal(b[1:], pl); err != nil {
+		return nil, &DeserializeError{Offset: 1, Err: err}
+	}
+	return
+}
+
+// deserializePayload applies the records of a protobuf payload to the player. Unlike the binary format, the payload is
+// not gated on its SerializationVersion: fields and records this client does not know about are skipped, so newer
+// peers may extend the schema without breaking older ones.
+func (g *Game) deserializePayload(pl *protocol03.AlttpPayload, p *Player, discardStale bool) (err error) {
+	if g.receiveFrame(p, uint8(pl.GetTeam()), uint8(pl.GetFrame()), discardStale) {
+		return
+	}
+
+	for i, record := range pl.GetRecords() {
+		var msgType MessageType
+		switch rec := record.GetRecord().(type) {
+		case *protocol03.AlttpRecord_Location:
+			msgType = MsgLocation
+			g.deserializeLocationRecord(p, rec.Location)
+		case *protocol03.AlttpRecord_Wram:
+			msgType = MsgWRAM
+			err = g.deserializeWRAMRecord(p, rec.Wram)
+		case *protocol03.AlttpRecord_Sram:
+			msgType = MsgSRAM
+			err = g.deserializeSRAMRecord(p, rec.Sram)
+		case *protocol03.AlttpRecord_SramDelta:
+			msgType = MsgSRAMDelta
+			for _, run := range rec.SramDelta.GetRuns() {
+				if err = g.deserializeSRAMRecord(p, run); err != nil {
+					break
+				}
+			}
+		case *protocol03.AlttpRecord_PlayerName:
+			msgType = MsgPlayerName
+			g.deserializePlayerNameRecord(p, rec.PlayerName)
+		default:
+			// a record from a newer schema that this client does not know about:
+			continue
+		}
+		if err != nil {
+			return &DeserializeError{Type: msgType, Offset: i, Err: err}
+		}
+
+		g.netStats.messageReceived(msgType, proto.Size(record))
+	}
+
+	return
+}
+
+func (g *Game) deserializeLocationRecord(p *Player, loc *protocol03.AlttpLocation) {
+	g.setLocation(p, &locationFields{
+		Module:          Module(loc.GetModule()),
+		SubModule:       uint8(loc.GetSubModule()),
+		SubSubModule:    uint8(loc.GetSubSubModule()),
+		LocationLo:      uint8(loc.GetLocation()),
+		LocationHi:      uint16(loc.GetLocation() >> 8),
+		X:               uint16(loc.GetX()),
+		Y:               uint16(loc.GetY()),
+		Dungeon:         uint16(loc.GetDungeon()),
+		DungeonEntrance: uint16(loc.GetDungeonEntrance()),
+		LastOverworldX:  uint16(loc.GetLastOverworldX()),
+		LastOverworldY:  uint16(loc.GetLastOverworldY()),
+		XOffs:           int16(loc.GetXOffs()),
+		YOffs:           int16(loc.GetYOffs()),
+		PlayerColor:     uint16(loc.GetPlayerColor()),
+	})
+}
+
+func (g *Game) deserializeWRAMRecord(p *Player, wram *protocol03.AlttpWRAM) error {
+	words := wram.GetWords()
+	if uint64(wram.GetStart())+uint64(len(words)) > 0x10000 {
+		return fmt.Errorf("$%04x+%d: %w", wram.GetStart(), len(words), ErrOutOfBounds)
+	}
+
+	for i, word := range words {
+		g.setWRAM(p, uint16(wram.GetStart())+uint16(i), word.GetTimestamp(), uint16(word.GetValue()))
+	}
+	return nil
+}
+
+func (g *Game) deserializeSRAMRecord(p *Player, sram *protocol03.AlttpSRAM) error {
+	start, data := uint64(sram.GetStart()), sram.GetData()
+	end := start + uint64(len(data))
+	if end > uint64(len(p.SRAM.data)) {
+		return fmt.Errorf("$%03x+%d: %w", start, len(data), ErrOutOfBounds)
+	}
+
+	copy(p.SRAM.data[start:end], data)
+	for j := start; j < end; j++ {
+		p.SRAM.fresh[j] = true
+	}
+	return nil
+}
+
+func (g *Game) deserializePlayerNameRecord(p *Player, playerName *protocol03.AlttpPlayerName) {
+	var startTime, finishTime time.Time
+	if t := playerName.GetGameStartTime(); t != 0 {
+		startTime = time.Unix(t/1e9, t%1e9)
+	}
+	if t := playerName.GetGameFinishTime(); t != 0 {
+		finishTime = time.Unix(t/1e9, t%1e9)
+	}
+	g.setPlayerName(p, playerName.GetName(), startTime, finishTime)
+}
+
+// peersAcceptProtobufPayload reports whether every remote player announced that it accepts protobuf payloads
+func (g *Game) peersAcceptProtobufPayload() bool {
+	remotePlayers := g.RemotePlayers()
+	if len(remotePlayers) == 0 {
+		return false
+	}
+	for _, p := range remotePlayers {
+		if !p.Capabilities.GetProtobufPayload() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package alttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"o2/client/loopback"
+	"o2/client/protocol03"
+	"testing"
+	"time"
+)
+
+func TestPayload_RoundTrip(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+
+	g.local.XOffs, g.local.YOffs = -12, 34
+	g.local.Location = 1<<16 | 0x0012
+	g.local.WRAM[smallKeyFirst] = &SyncableWRAM{Timestamp: 0x1234, Value: 3}
+	g.local.GameStartTime = time.Unix(1600000000, 123)
+
+	m := testMessage(g)
+	m.writePlayerName(g.local)
+
+	b, err := marshalPayload(m.payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[0] != payloadProtobuf {
+		t.Errorf("expected payload prefix $%02x, got $%02x", payloadProtobuf, b[0])
+	}
+	pl, err := unmarshalPayload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the protobuf payload must decode to the same player state as the binary frame:
+	var fromBinary, fromPayload Player
+	for _, p := range []*Player{&fromBinary, &fromPayload} {
+		p.SRAM.data = new([0x500]byte)
+		p.SRAM.fresh = new([0x500]bool)
+	}
+	if err = g.deserialize(bytes.NewReader(m.Bytes()), &fromBinary, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.deserializePayload(pl, &fromPayload, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if fromPayload.Location != g.local.Location || fromPayload.XOffs != -12 || fromPayload.YOffs != 34 {
+		t.Errorf("expected location %06x at %d,%d, got %06x at %d,%d", g.local.Location, -12, 34,
+			fromPayload.Location, fromPayload.XOffs, fromPayload.YOffs)
+	}
+	if fromBinary.Location != fromPayload.Location || fromBinary.DungeonRoom != fromPayload.DungeonRoom ||
+		fromBinary.X != fromPayload.X || fromBinary.Y != fromPayload.Y || fromBinary.PlayerColor != fromPayload.PlayerColor {
+		t.Errorf("expected the same location from both formats")
+	}
+	if *fromBinary.SRAM.data != *fromPayload.SRAM.data || *fromBinary.SRAM.fresh != *fromPayload.SRAM.fresh {
+		t.Errorf("expected the same sram from both formats")
+	}
+	if w := fromPayload.WRAM[smallKeyFirst]; w == nil || w.Timestamp != 0x1234 || w.Value != 3 {
+		t.Errorf("expected wram[$%04x] == 3 @ $1234, got %+v", smallKeyFirst, w)
+	}
+	if fromPayload.Name() != "g1" || !fromPayload.GameStartTime.Equal(g.local.GameStartTime) || !fromPayload.GameFinishTime.IsZero() {
+		t.Errorf("expected name g1 started at %v, got %q started at %v finished at %v", g.local.GameStartTime,
+			fromPayload.Name(), fromPayload.GameStartTime, fromPayload.GameFinishTime)
+	}
+	if fromBinary.Name() != fromPayload.Name() || !fromBinary.GameStartTime.Equal(fromPayload.GameStartTime) {
+		t.Errorf("expected the same player name from both formats")
+	}
+}
+
+func TestPayload_SkipsUnknown(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+
+	// a newer peer with a different version, an unknown record and an unknown field in a known record:
+	sram, err := proto.Marshal(&protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_Sram{
+		Sram: &protocol03.AlttpSRAM{Start: 0x340, Data: []byte{0x02}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sram = protowire.AppendTag(sram, 99, protowire.VarintType)
+	sram = protowire.AppendVarint(sram, 1)
+	record := &protocol03.AlttpRecord{}
+	if err = proto.Unmarshal(sram, record); err != nil {
+		t.Fatal(err)
+	}
+
+	pl := &protocol03.AlttpPayload{
+		SerializationVersion: SerializationVersion + 1,
+		Records:              []*protocol03.AlttpRecord{{}, record},
+	}
+	b, err := marshalPayload(pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &g.players[1]
+	if err = g.deserializeBroadcast(b, p, false); err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := uint8(0x02), p.SRAM.data[0x340]; expected != actual {
+		t.Errorf("expected sram[$340] == $%02x, got $%02x", expected, actual)
+	}
+	if p.incompatible || p.CompatibilityWarning != "" {
+		t.Errorf("expected a newer protobuf payload to be compatible")
+	}
+}
+
+func TestPayload_Errors(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+
+	if _, err = unmarshalPayload([]byte{payloadProtobuf, 0xFF}); err == nil {
+		t.Error("expected an error for a malformed protobuf payload")
+	}
+
+	tests := []struct {
+		name     string
+		record   *protocol03.AlttpRecord
+		wantType MessageType
+	}{
+		{"sram", &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_Sram{
+			Sram: &protocol03.AlttpSRAM{Start: 0x4FF, Data: []byte{1, 2}},
+		}}, MsgSRAM},
+		{"sram delta", &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_SramDelta{
+			SramDelta: &protocol03.AlttpSRAMDelta{Runs: []*protocol03.AlttpSRAM{{Start: 0x340, Data: []byte{1}}, {Start: 0x500, Data: []byte{1}}}},
+		}}, MsgSRAMDelta},
+		{"wram", &protocol03.AlttpRecord{Record: &protocol03.AlttpRecord_Wram{
+			Wram: &protocol03.AlttpWRAM{Start: 0xFFFF, Words: []*protocol03.AlttpWRAMWord{{}, {}}},
+		}}, MsgWRAM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.deserializePayload(&protocol03.AlttpPayload{
+				Records: []*protocol03.AlttpRecord{{}, tt.record},
+			}, &g.players[1], false)
+
+			var derr *DeserializeError
+			if !errors.As(err, &derr) || derr.Type != tt.wantType || derr.Offset != 1 || !errors.Is(err, ErrOutOfBounds) {
+				t.Errorf("expected an out of bounds %s DeserializeError for record 1, got %v", tt.wantType, err)
+			}
+		})
+	}
+}
+
+func TestGameSync_Loopback_ProtobufPayload(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	// announce capabilities now that both players have joined:
+	for i := range gs {
+		gs[i].g.sendPlayerName()
+	}
+	hub.Flush()
+	runFrame()
+
+	for i := range gs {
+		if !gs[i].g.peersAcceptProtobufPayload() {
+			t.Fatalf("g%d: expected peers to accept protobuf payloads", i+1)
+		}
+	}
+
+	// g1 picks up the bow:
+	gs[0].e.WRAM[0xF340] = 0x01
+	for f := 0; f < 32; f++ {
+		runFrame()
+	}
+
+	remote := &gs[1].g.players[gs[0].g.local.Index()]
+	if expected, actual := uint8(0x01), remote.SRAM.data[0x340]; expected != actual {
+		t.Errorf("g2: expected g1 sram[$340] == $%02x, got $%02x", expected, actual)
+	}
+	if expected, actual := "g1", remote.Name(); expected != actual {
+		t.Errorf("g2: expected g1 name %q, got %q", expected, actual)
+	}
+
+	// a peer that does not announce support gets the binary format:
+	remote.Capabilities.ProtobufPayload = false
+	if gs[1].g.peersAcceptProtobufPayload() {
+		t.Error("g2: expected peers to not accept protobuf payloads")
+	}
+}
@@ -1,9 +1,11 @@
 package alttp
 
 import (
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"o2/client/protocol03"
 	"o2/games"
 	"time"
 )
@@ -95,17 +97,50 @@ func (r WRAMReadable) BusAddress(offs uint32) uint32 {
 	return 0x7E0000 + offs
 }
 
+// ReadU8 returns 0 for WRAM not yet received from the player, e.g. location-local WRAM sent only to other players in
+// the same sector
 func (r WRAMReadable) ReadU8(offs uint32) uint8 {
-	return uint8(r[uint16(offs)].Value)
+	if w, ok := r[uint16(offs)]; ok {
+		return uint8(w.Value)
+	}
+	return 0
 }
 
 func (r WRAMReadable) ReadU16(offs uint32) uint16 {
-	return r[uint16(offs)].Value
+	if w, ok := r[uint16(offs)]; ok {
+		return w.Value
+	}
+	return 0
+}
+
+// PlayerID is a random identifier a player generates for itself once so that it can be recognized after a reconnect
+// assigns it a different index; the zero PlayerID is unknown
+type PlayerID [16]byte
+
+func newPlayerID() (id PlayerID) {
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Errorf("alttp: generate player id: %w", err))
+	}
+	return
+}
+
+// playerIDFromBytes converts a PlayerID received over the network; it returns the zero PlayerID if b is malformed
+func playerIDFromBytes(b []byte) (id PlayerID) {
+	if len(b) == len(id) {
+		copy(id[:], b)
+	}
+	return
+}
+
+func (id PlayerID) IsZero() bool {
+	return id == PlayerID{}
 }
 
 type Player struct {
 	IndexF int
 	Ttl    int
+	// ID identifies the player across reconnects; see PlayerID
+	ID PlayerID
 
 	Team  uint8
 	NameF string
@@ -120,6 +155,9 @@ type Player struct {
 	OverworldArea uint16
 	DungeonRoom   uint16
 	Location      uint32
+	// Sector is the sector the player last reported being in; sector-targeted broadcasts are only received from
+	// players in the same sector as the local player
+	Sector uint64
 
 	X uint16
 	Y uint16
@@ -139,6 +177,18 @@ type Player struct {
 	WRAM WRAMReadable
 
 	showJoinMessage bool
+	// rejoining players were expired because we disconnected; their return is not announced
+	rejoining bool
+
+	// Capabilities last announced by the player; nil if the player never announced any
+	Capabilities *protocol03.Capabilities
+	// CompatibilityWarning describes how the player's capabilities differ from ours
+	CompatibilityWarning string
+	// incompatible players' data is not merged
+	incompatible bool
+
+	// BadPackets counts malformed packets received from the player that were dropped
+	BadPackets uint64
 
 	GameStartTime  time.Time
 	GameFinishTime time.Time
@@ -191,18 +241,27 @@ func (g *Game) DecTTL(p *Player, amount int) {
 
 func (g *Game) PlayerJoined(p *Player) {
 	// Activating new player:
-	p.showJoinMessage = true
+	p.showJoinMessage = !p.rejoining
+	p.rejoining = false
 	g.activePlayersClean = false
 	g.shouldUpdatePlayersList = true
+	// send full SRAM keyframes next so the new player need not wait for the next periodic keyframe:
+	g.sramKeyframeCountdown = [syncSectionCount]int{}
 }
 
 func (g *Game) PlayerLeft(p *Player) {
 	// Player left the game:
 	p.Ttl = 0
 	p.showJoinMessage = false
+	p.Capabilities = nil
+	p.CompatibilityWarning = ""
+	p.incompatible = false
+	p.BadPackets = 0
 
 	log.Printf("alttp: player[%02x]: %s left\n", uint8(p.IndexF), p.NameF)
-	g.PushNotification(fmt.Sprintf("%s left", p.NameF))
+	if !p.rejoining {
+		g.PushNotification(fmt.Sprintf("%s left", p.NameF))
+	}
 
 	// refresh the ActivePlayers():
 	g.activePlayersClean = false
@@ -211,6 +270,96 @@ func (g *Game) PlayerLeft(p *Player) {
 	g.shouldUpdatePlayersList = true
 }
 
+// playersDisconnected expires all players without announcing that they left because we are the one that disconnected
+func (g *Game) playersDisconnected() {
+	for i := range g.players {
+		p := &g.players[i]
+		if p.Ttl > 0 {
+			p.rejoining = true
+		}
+		// reset Ttl for all players to make them inactive:
+		g.DecTTL(p, 255)
+		p.IndexF = -1
+	}
+}
+
+// rejoinPlayer recognizes a player with the given ID arriving at a new index, e.g. after either of us reconnected, and
+// moves its state from where it was last seen so that timers, small key timestamps and names carry over. A different
+// player that previously had the index departs with its state kept aside in case it rejoins at another index.
+func (g *Game) rejoinPlayer(index int, id PlayerID) {
+	p := &g.players[index]
+	if id.IsZero() || p.ID == id || p == g.local {
+		return
+	}
+
+	if !p.ID.IsZero() {
+		g.departPlayer(p)
+	}
+
+	if o := g.playerByID(id); o != nil && o != g.local {
+		log.Printf("alttp: player[%02x]: %s rejoined as player[%02x]\n", uint8(o.IndexF), o.NameF, uint8(index))
+		*p = *o
+		g.vacatePlayer(o)
+	} else if d, ok := g.departedPlayers[id]; ok {
+		log.Printf("alttp: player[%02x]: %s rejoined\n", uint8(index), d.NameF)
+		delete(g.departedPlayers, id)
+		*p = d
+	}
+
+	p.IndexF = index
+	p.ID = id
+	g.activePlayersClean = false
+	g.shouldUpdatePlayersList = true
+}
+
+// playerByID finds the player slot with the given ID
+func (g *Game) playerByID(id PlayerID) *Player {
+	for i := range g.players {
+		if g.players[i].ID == id {
+			return &g.players[i]
+		}
+	}
+	return nil
+}
+
+// departPlayer quietly sets aside the state of a player whose index was given to another player, which only happens
+// once the player has disconnected from the server
+func (g *Game) departPlayer(p *Player) {
+	log.Printf("alttp: player[%02x]: %s departed\n", uint8(p.IndexF), p.NameF)
+
+	if len(g.departedPlayers) >= MaxPlayers {
+		// forget players that never came back:
+		g.departedPlayers = nil
+	}
+	if g.departedPlayers == nil {
+		g.departedPlayers = make(map[PlayerID]Player)
+	}
+
+	d := *p
+	// do not announce the player's return unless it had already left:
+	d.rejoining = d.rejoining || d.Ttl > 0
+	d.Ttl = 0
+	g.departedPlayers[p.ID] = d
+
+	g.vacatePlayer(p)
+}
+
+// vacatePlayer quietly resets a player slot that no longer belongs to anyone
+func (g *Game) vacatePlayer(p *Player) {
+	// new SRAM buffers since the old ones may still be referenced, e.g. by the local player:
+	*p = Player{IndexF: -1, PlayerColor: 0x12ef}
+	p.SRAM.data = new([0x500]byte)
+	p.SRAM.fresh = new([0x500]bool)
+
+	g.activePlayersClean = false
+	g.shouldUpdatePlayersList = true
+}
+
+// InSector reports whether the player last reported being in the given sector
+func (p *Player) InSector(sector uint64) bool {
+	return p.Sector == sector
+}
+
 func (p *Player) IsInDungeon() bool {
 	if p.IsDungeon() {
 		return true
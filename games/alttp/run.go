@@ -118,15 +118,17 @@ sendloop:
 
 // run in a separate goroutine
 func (g *Game) run() {
-	q := make([]snes.Read, 0, 12)
+	if !g.spectator {
+		q := make([]snes.Read, 0, 12)
 
-	// kick off initial WRAM read request:
-	g.priorityReadsMu.Lock()
-	q = g.queueReads(q)
-	// must always read module number LAST to validate the prior reads:
-	q = g.enqueueMainRead(q)
-	g.priorityReads[2] = q
-	g.priorityReadsMu.Unlock()
+		// kick off initial WRAM read request:
+		g.priorityReadsMu.Lock()
+		q = g.queueReads(q)
+		// must always read module number LAST to validate the prior reads:
+		q = g.enqueueMainRead(q)
+		g.priorityReads[2] = q
+		g.priorityReadsMu.Unlock()
+	}
 
 	fastbeat := time.NewTicker(120 * time.Millisecond)
 	slowbeat := time.NewTicker(500 * time.Millisecond)
@@ -154,12 +156,9 @@ func (g *Game) run() {
 		case msg := <-g.client.Read():
 			if msg == nil {
 				// disconnected?
-				for i := range g.players {
-					p := &g.players[i]
-					// reset Ttl for all players to make them inactive:
-					g.DecTTL(p, 255)
-					p.IndexF = -1
-				}
+				g.reliable.Reset()
+				g.clock.reset()
+				g.playersDisconnected()
 				if g.shouldUpdatePlayersList {
 					g.updatePlayersList()
 				}
@@ -207,6 +206,10 @@ func (g *Game) run() {
 				}
 			}
 
+			if g.spectator {
+				g.spectatorTick()
+			}
+
 			// update run timer:
 			g.updateRunTimer()
 			break
@@ -222,40 +225,52 @@ func (g *Game) run() {
 
 			g.sendEcho()
 
-			g.sendPlayerName()
+			if !g.spectator {
+				g.sendPlayerName()
+			} else {
+				// items are not tracked for changes so periodically refresh the players list instead:
+				g.shouldUpdatePlayersList = true
+			}
+
+			g.updateNetworkViewModel()
 
 			break
 		}
 	}
 }
 
+// spectatorFrames approximates how many game frames elapse per fastbeat tick
+const spectatorFrames = 7
+
+// spectatorTick does the per-frame work of a spectator which has no SNES reads to drive its main loop
+func (g *Game) spectatorTick() {
+	g.stateLock.Lock()
+	defer g.stateLock.Unlock()
+
+	// tick down TTLs of remote players:
+	for _, p := range g.ActivePlayers() {
+		g.DecTTL(p, spectatorFrames)
+	}
+
+	if g.shouldUpdatePlayersList {
+		g.updatePlayersList()
+	}
+}
+
 func (g *Game) sendEcho() {
 	// send an echo to the server to measure roundtrip time:
-	g.lastServerSentTime = time.Now()
-	g.send(&gameEchoMessage{g: g})
+	g.lastServerSentTime = g.localNow()
+	m := &gameEchoMessage{g: g}
+	m.Write(makeEchoData(g.netStats.echoSent(g.lastServerSentTime)))
+	g.send(m)
 }
 
 func (g *Game) sendPlayerName() {
 	// broadcast player name:
 	m := g.makeBroadcastMessage()
-	m.WriteByte(0x0C)
-	var name [20]byte
-	p := g.LocalPlayer()
-	n := copy(name[:], p.Name())
-	for ; n < 20; n++ {
-		name[n] = ' '
-	}
-	m.Write(name[:])
-
-	_ = binary.Write(m, binary.LittleEndian, p.GameStartTime.IsZero())
-	if !p.GameStartTime.IsZero() {
-		_ = binary.Write(m, binary.LittleEndian, p.GameStartTime.UnixNano())
-	}
-
-	_ = binary.Write(m, binary.LittleEndian, p.GameFinishTime.IsZero())
-	if !p.GameFinishTime.IsZero() {
-		_ = binary.Write(m, binary.LittleEndian, p.GameFinishTime.UnixNano())
-	}
+	// periodically announce our capabilities to peers:
+	m.announceCapabilities = true
+	m.writePlayerName(g.LocalPlayer())
 
 	g.send(m)
 }
@@ -307,7 +322,7 @@ func (g *Game) enqueueSRAMRead(q []snes.Read) []snes.Read {
 }
 
 func (g *Game) enqueueWRAMReads(q []snes.Read) []snes.Read {
-	// FX Pak Pro allows batches of 8 VGET requests to be submitted at a time:
+	// the queue merges adjacent reads and batches them to fit its driver's limits:
 
 	// $F5-F6:xxxx is WRAM, aka $7E-7F:xxxx
 	q = g.readEnqueue(q, 0xF50100, 0x36, nil) // [$0100..$0135]
@@ -0,0 +1,37 @@
+package alttp
+
+import (
+	"o2/snes"
+	"o2/snes/fxpakpro"
+	"testing"
+)
+
+func TestGame_PlanReads_MainReadLast(t *testing.T) {
+	for _, frameTime := range []uint8{0, 7} {
+		g := &Game{monotonicFrameTime: frameTime}
+		q := g.queueReads(make([]snes.Read, 0, 20))
+		q = g.enqueueMainRead(q)
+		main := q[len(q)-1]
+		mainStart, mainEnd := main.Address, main.Address+uint32(main.Size)
+
+		batches := snes.PlanReads(q, fxpakpro.ReadLimits)
+
+		// every planned read that carries bytes of the main read must come after all the others:
+		seenMain := false
+		for _, batch := range batches {
+			for _, rd := range batch {
+				start, end := rd.Address, rd.Address+uint32(rd.Size)
+				if start < mainEnd && mainStart < end {
+					seenMain = true
+					continue
+				}
+				if seenMain {
+					t.Errorf("frame %d: read %06x+%02x planned after the main read", frameTime, rd.Address, rd.Size)
+				}
+			}
+		}
+		if !seenMain {
+			t.Errorf("frame %d: main read was not planned", frameTime)
+		}
+	}
+}
@@ -1,9 +1,40 @@
 package alttp
 
 import (
+	"encoding/binary"
 	"hash/fnv"
 )
 
+type syncSection int
+
+const (
+	syncSmallKeys syncSection = iota
+	syncItems
+	syncUnderworld
+	syncOverworld
+
+	syncSectionCount
+)
+
+// reliableRefreshFrames is how often an unchanged sync section is resent when reliable delivery is enabled
+const reliableRefreshFrames = 240
+
+// sramKeyframeInterval is how many unreliable broadcasts of an SRAM sync section occur per full keyframe; the
+// broadcasts in between carry only the bytes changed since the previous broadcast
+const sramKeyframeInterval = 8
+
+// snapshotKey is the key the server keeps the latest full broadcast of a sync section under to replay to players that
+// join later
+func snapshotKey(section syncSection) uint32 {
+	return uint32(section) + 1
+}
+
+// reliableChannel is the channel a sync section is delivered reliably on. Channels are delivered in order, so each
+// section gets its own channel to keep a lost message of one section from holding up the others.
+func reliableChannel(section syncSection) uint32 {
+	return uint32(section) + 1
+}
+
 func (g *Game) sendPackets() {
 	// don't send out any network updates until we're connected:
 	if g.local.Index() < 0 {
@@ -17,9 +48,7 @@ func (g *Game) sendPackets() {
 		m := g.makeBroadcastMessage()
 
 		locStart := m.Len()
-		if err := g.SerializeLocation(local, m); err != nil {
-			panic(err)
-		}
+		m.writeLocation(local)
 
 		// hash the location packet:
 		locHash := hash64(m.Bytes()[locStart:])
@@ -37,79 +66,138 @@ func (g *Game) sendPackets() {
 	{
 		m := g.makeBroadcastMessage()
 		// small keys:
-		if err := g.SerializeWRAM(local, m, smallKeyFirst, 0x10); err != nil {
-			panic(err)
+		m.writeWRAM(local, smallKeyFirst, 0x10)
+		m.snapshot = snapshotKey(syncSmallKeys)
+		g.sendSync(m, syncSmallKeys, true)
+	}
+
+	{
+		// current dungeon supertile door state is only of interest to players in the same supertile:
+		m := g.makeSectorMessage()
+
+		doorStart := m.Len()
+		m.writeWRAM(local, 0x0400, 1)
+
+		// hash the door state along with the sector so that entering a new supertile sends it too:
+		h := fnv.New64a()
+		_ = binary.Write(h, binary.LittleEndian, g.localSector())
+		_, _ = h.Write(m.Bytes()[doorStart:])
+		doorHash := h.Sum64()
+		if g.doorHashTTL > 0 {
+			g.doorHashTTL--
 		}
-		// current dungeon supertile door state:
-		if err := g.SerializeWRAM(local, m, 0x0400, 1); err != nil {
-			panic(err)
+		if doorHash != g.doorHash || g.doorHashTTL <= 0 {
+			// only send if different or Ttl of last packet expired:
+			g.send(m)
+			g.doorHashTTL = 60
+			g.doorHash = doorHash
 		}
-		g.send(m)
 	}
 
-	if g.monotonicFrameTime&15 == 0 {
+	if due := g.monotonicFrameTime&15 == 0; due || g.ReliableDelivery {
 		// Broadcast items and progress SRAM:
 		m := g.makeBroadcastMessage()
 		if m != nil {
+			keyframe := g.sramKeyframe(m, syncItems)
 			if g.isVTRandomizer() {
 				// VT randomizer:
 
 				// vanilla:
-				if err := g.SerializeSRAM(local, m, 0x340, 0x390+16+1); err != nil {
-					panic(err)
-				}
-				if err := g.SerializeSRAM(local, m, 0x3C5, 0x3C9+1); err != nil {
-					panic(err)
-				}
+				g.serializeSRAMSync(m, keyframe, 0x340, 0x390+16+1)
+				g.serializeSRAMSync(m, keyframe, 0x3C5, 0x3C9+1)
 				// randomizer specifics:
-				if err := g.SerializeSRAM(local, m, 0x3FF, 0x429+1); err != nil {
-					panic(err)
-				}
-				if err := g.SerializeSRAM(local, m, 0x46e, 0x476+1+1); err != nil {
-					panic(err)
-				}
+				g.serializeSRAMSync(m, keyframe, 0x3FF, 0x429+1)
+				g.serializeSRAMSync(m, keyframe, 0x46e, 0x476+1+1)
 
 				// Door randomizer = VT + these:
-				if err := g.SerializeSRAM(local, m, 0x4C0, 0x4CC+1); err != nil {
-					panic(err)
-				}
+				g.serializeSRAMSync(m, keyframe, 0x4C0, 0x4CC+1)
 
 				//serialize(r, 0x4E0, 0x4ED); // chest-keys
 			} else {
 				// assume vanilla game:
 
 				// items earned
-				if err := g.SerializeSRAM(local, m, 0x340, 0x37B+1); err != nil {
-					panic(err)
-				}
+				g.serializeSRAMSync(m, keyframe, 0x340, 0x37B+1)
 				// progress made
-				if err := g.SerializeSRAM(local, m, 0x3C5, 0x3C9+1); err != nil {
-					panic(err)
-				}
+				g.serializeSRAMSync(m, keyframe, 0x3C5, 0x3C9+1)
 			}
 
-			g.send(m)
+			g.sendSync(m, syncItems, due)
 		}
 	}
 
-	if g.SyncUnderworld && g.monotonicFrameTime&31 == 0 {
+	if due := g.monotonicFrameTime&31 == 0; g.SyncUnderworld && (due || g.ReliableDelivery) {
 		// dungeon rooms
 		m := g.makeBroadcastMessage()
-		err := g.SerializeSRAM(g.local, m, 0x000, 0x250)
-		if err != nil {
-			panic(err)
-		}
-		g.send(m)
+		g.serializeSRAMSync(m, g.sramKeyframe(m, syncUnderworld), 0x000, 0x250)
+		g.sendSync(m, syncUnderworld, due)
 	}
 
-	if g.SyncOverworld && g.monotonicFrameTime&31 == 16 {
+	if due := g.monotonicFrameTime&31 == 16; g.SyncOverworld && (due || g.ReliableDelivery) {
 		// overworld events; heart containers, overlays
 		m := g.makeBroadcastMessage()
-		err := g.SerializeSRAM(g.local, m, 0x280, 0x340)
-		if err != nil {
-			panic(err)
+		g.serializeSRAMSync(m, g.sramKeyframe(m, syncOverworld), 0x280, 0x340)
+		g.sendSync(m, syncOverworld, due)
+	}
+
+	// retransmit reliable messages that have not been acked in time:
+	g.sendRetransmits()
+}
+
+// sendSync sends a WRAM/SRAM sync message. Without reliable delivery the message is sent unreliably whenever it is
+// due and not empty. With reliable delivery the message is sent reliably on the section's channel only when its
+// contents change or when its slower refresh interval expires.
+func (g *Game) sendSync(m *gameBroadcastMessage, section syncSection, due bool) {
+	if !g.ReliableDelivery {
+		if due && m.Len() > broadcastHeaderSize {
+			g.send(m)
 		}
-		g.send(m)
+		return
+	}
+
+	// skip the header (version, team, frame) so the frame number does not affect the hash:
+	h := hash64(m.Bytes()[broadcastHeaderSize:])
+	if g.syncHashTTL[section] > 0 {
+		g.syncHashTTL[section]--
+	}
+	if h == g.syncHash[section] && g.syncHashTTL[section] > 0 {
+		return
+	}
+
+	m.reliable = reliableChannel(section)
+	g.send(m)
+	g.syncHashTTL[section] = reliableRefreshFrames
+	g.syncHash[section] = h
+}
+
+// sramKeyframe reports whether the SRAM ranges of section should be serialized in full rather than as a delta; a
+// keyframe marks m as the latest snapshot of section
+func (g *Game) sramKeyframe(m *gameBroadcastMessage, section syncSection) bool {
+	if g.ReliableDelivery {
+		// reliable sync messages are compared by hash and resent in full when changed:
+		m.snapshot = snapshotKey(section)
+		return true
+	}
+
+	if g.sramKeyframeCountdown[section] > 0 {
+		g.sramKeyframeCountdown[section]--
+		return false
+	}
+	g.sramKeyframeCountdown[section] = sramKeyframeInterval - 1
+	m.snapshot = snapshotKey(section)
+	return true
+}
+
+// serializeSRAMSync serializes the local player's SRAM[start:endExclusive] either in full as a keyframe or as the
+// runs of bytes changed since the range was last serialized
+func (g *Game) serializeSRAMSync(m *gameBroadcastMessage, keyframe bool, start, endExclusive uint16) {
+	local := g.local
+
+	if keyframe {
+		m.writeSRAM(local, start, endExclusive)
+		copy(g.sramBaseline[start:endExclusive], local.SRAM.data[start:endExclusive])
+	} else {
+		m.writeSRAMDelta(local, &g.sramBaseline, start, endExclusive)
 	}
 }
 
@@ -10,8 +10,41 @@ import (
 	"time"
 )
 
-// NOTE: increment this when the serialization code changes in an incompatible way
-const SerializationVersion = 0x14
+// NOTE: increment this when the serialization code changes in an incompatible way and describe the previous version
+// in serializationFormats if it can still be decoded
+const SerializationVersion = 0x15
+
+// serializationFormat describes how frames of a SerializationVersion are decoded into the current Player model
+type serializationFormat struct {
+	// maxMessageType bounds the message types the version can carry
+	maxMessageType MessageType
+	// unavailable names the data that peers using the version never send
+	unavailable []string
+}
+
+// serializationFormats lists every SerializationVersion that can be decoded
+var serializationFormats = map[uint8]serializationFormat{
+	SerializationVersion: {maxMessageType: MsgMaxMessageType},
+	// 0x14 is the baseline format; it is the current format without SRAM delta encoding and capability announcements:
+	0x14: {maxMessageType: MsgSRAMDelta, unavailable: []string{"ROM hash", "sync options", "client version"}},
+}
+
+// checkSerializationVersion reports how a peer's SerializationVersion differs from ours. Versions that cannot be
+// decoded are incompatible; older versions that can be decoded only produce a warning naming what they lack.
+func checkSerializationVersion(version uint8) (warning string, incompatible bool) {
+	if version == SerializationVersion {
+		return
+	}
+
+	format, ok := serializationFormats[version]
+	if !ok {
+		return fmt.Sprintf("serialization version %#02x, expected %#02x", version, SerializationVersion), true
+	}
+	if len(format.unavailable) == 0 {
+		return fmt.Sprintf("serialization version %#02x", version), false
+	}
+	return fmt.Sprintf("serialization version %#02x without %s", version, strings.Join(format.unavailable, ", ")), false
+}
 
 type MessageType uint8
 
@@ -29,10 +62,35 @@ const (
 	MsgTorches
 	MsgPvP
 	MsgPlayerName
+	MsgSRAMDelta
 
 	MsgMaxMessageType
 )
 
+var messageTypeNames = [MsgMaxMessageType]string{
+	"",
+	"location",
+	"sfx",
+	"sprites1",
+	"sprites2",
+	"wram",
+	"sram",
+	"tilemaps",
+	"objects",
+	"ancillae",
+	"torches",
+	"pvp",
+	"playerName",
+	"sramDelta",
+}
+
+func (t MessageType) String() string {
+	if t < MsgMaxMessageType && messageTypeNames[t] != "" {
+		return messageTypeNames[t]
+	}
+	return fmt.Sprintf("MessageType(%d)", uint8(t))
+}
+
 type DeserializeFunc func(p *Player, r io.Reader) error
 
 func (g *Game) initSerde() {
@@ -50,6 +108,7 @@ func (g *Game) initSerde() {
 		g.DeserializeTorches,
 		g.DeserializePvP,
 		g.DeserializePlayerName,
+		g.DeserializeSRAMDelta,
 	}
 }
 
@@ -78,88 +137,178 @@ func writeU24(w io.Writer, value uint32) (err error) {
 	return
 }
 
+var (
+	// ErrSerializationVersion is returned when a frame was serialized by an incompatible version
+	ErrSerializationVersion = errors.New("serialization version mismatch")
+	// ErrUnknownMessageType is returned for a message type beyond MsgMaxMessageType
+	ErrUnknownMessageType = errors.New("unknown message type")
+	// ErrUnsupportedMessage is returned for a message type that is never sent and cannot be skipped over
+	ErrUnsupportedMessage = errors.New("unsupported message type")
+	// ErrOutOfBounds is returned when a message refers to memory outside the range it may write to
+	ErrOutOfBounds = errors.New("out of bounds")
+	// ErrPlayerIndex is returned for a packet from a player index beyond MaxPlayers
+	ErrPlayerIndex = errors.New("beyond max player count")
+)
+
+// DeserializeError reports a malformed frame from a player. Type is the message that failed to deserialize, or 0
+// if the frame header did, and Offset is the byte offset of the message in the frame, or the index of the record in
+// a protobuf payload. Nothing after the offending message is deserialized since there is no way to skip over it.
+type DeserializeError struct {
+	Type   MessageType
+	Offset int
+	Err    error
+}
+
+func (e *DeserializeError) Error() string {
+	if e.Type == 0 && e.Err != ErrUnknownMessageType {
+		return fmt.Sprintf("alttp: error deserializing frame header at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("alttp: error deserializing %s message at offset %d: %v", e.Type, e.Offset, e.Err)
+}
+
+func (e *DeserializeError) Unwrap() error {
+	return e.Err
+}
+
 func (g *Game) Deserialize(r io.Reader, p *Player) (err error) {
+	return g.deserialize(r, p, true)
+}
+
+// deserialize optionally discards data from frames older than the last frame seen from the player
+func (g *Game) deserialize(r io.Reader, p *Player, discardStale bool) (err error) {
 	var (
 		serializationVersion uint8
+		team                 uint8
 		frame                uint8
 	)
 
-	if err = binary.Read(r, binary.LittleEndian, &serializationVersion); err != nil {
-		panic(err)
-	}
+	// count the bytes read to report offsets of malformed messages and for network statistics:
+	cr := &countingReader{r: r}
+	r = cr
 
-	if serializationVersion != SerializationVersion {
-		panic(fmt.Errorf("serializationVersion mismatch"))
+	headerError := func(err error) error {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return &DeserializeError{Offset: cr.n, Err: err}
 	}
 
-	lastTeam := p.Team
-	if err = binary.Read(r, binary.LittleEndian, &p.Team); err != nil {
-		panic(err)
+	if err = binary.Read(r, binary.LittleEndian, &serializationVersion); err != nil {
+		return headerError(err)
 	}
-	if p.Team != lastTeam {
-		g.shouldUpdatePlayersList = true
+
+	format, ok := serializationFormats[serializationVersion]
+	if !ok {
+		return &DeserializeError{Offset: 0, Err: ErrSerializationVersion}
 	}
 
+	if err = binary.Read(r, binary.LittleEndian, &team); err != nil {
+		return headerError(err)
+	}
 	if err = binary.Read(r, binary.LittleEndian, &frame); err != nil {
-		panic(err)
+		return headerError(err)
 	}
 
-	// discard stale frame data:
-	nextFrame := int(frame)
-	lastFrame := int(p.Frame)
-	if lastFrame-nextFrame >= 128 {
-		lastFrame -= 256
-	}
-	if nextFrame < lastFrame {
-		log.Printf("alttp: discard stale frame data (%d < %d)\n", nextFrame, lastFrame)
+	if g.receiveFrame(p, team, frame, discardStale) {
 		return
 	}
-	p.Frame = frame
 
-	for err != io.EOF {
+	for {
+		start := cr.n
+
 		// read message type or expect an EOF:
 		var msgType MessageType
 		if err = binary.Read(r, binary.LittleEndian, &msgType); err != nil {
-			//log.Println(err)
-			break
+			if err == io.EOF {
+				err = nil
+			}
+			return
 		}
 
 		// check bounds for message type:
-		if msgType == 0 || msgType >= MsgMaxMessageType {
-			err = fmt.Errorf("alttp: msgType %#02x out of bounds", msgType)
+		if msgType == 0 || msgType >= format.maxMessageType {
 			// no good recourse to be able to skip over the message
-			break
+			return &DeserializeError{Type: msgType, Offset: start, Err: ErrUnknownMessageType}
 		}
 
 		// call deserializer for the message type:
-		//log.Printf("deserializing message type %02x\n", msgType)
 		if err = g.deserTable[msgType](p, r); err != nil {
-			//log.Println(err)
-			break
+			if errors.Is(err, io.EOF) {
+				// the message was cut short:
+				err = io.ErrUnexpectedEOF
+			}
+			return &DeserializeError{Type: msgType, Offset: start, Err: err}
 		}
-	}
 
-	if errors.Is(err, io.EOF) {
-		err = nil
+		g.netStats.messageReceived(msgType, cr.n-start)
 	}
-	return
 }
 
-func (g *Game) DeserializeLocation(p *Player, r io.Reader) (err error) {
-	if err = binary.Read(r, binary.LittleEndian, &p.Module); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
+// receiveFrame records the team and frame number from a frame header and reports whether the frame is older than the
+// last frame seen from the player and should be discarded
+func (g *Game) receiveFrame(p *Player, team, frame uint8, discardStale bool) (stale bool) {
+	if p.Team != team {
+		p.Team = team
+		g.shouldUpdatePlayersList = true
+	}
+
+	// discard stale frame data:
+	nextFrame := int(frame)
+	lastFrame := int(p.Frame)
+	if lastFrame-nextFrame >= 128 {
+		lastFrame -= 256
 	}
-	if err = binary.Read(r, binary.LittleEndian, &p.SubModule); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
+	if nextFrame < lastFrame {
+		if discardStale {
+			log.Printf("alttp: discard stale frame data (%d < %d)\n", nextFrame, lastFrame)
+			return true
+		}
+	} else {
+		p.Frame = frame
 	}
-	if err = binary.Read(r, binary.LittleEndian, &p.SubSubModule); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
+	return false
+}
+
+// locationFields is the binary layout of a MsgLocation message
+type locationFields struct {
+	Module          Module
+	SubModule       uint8
+	SubSubModule    uint8
+	LocationLo      uint8
+	LocationHi      uint16
+	X               uint16
+	Y               uint16
+	Dungeon         uint16
+	DungeonEntrance uint16
+	LastOverworldX  uint16
+	LastOverworldY  uint16
+	XOffs           int16
+	YOffs           int16
+	PlayerColor     uint16
+	InSM            uint8
+}
+
+func (g *Game) DeserializeLocation(p *Player, r io.Reader) (err error) {
+	var loc locationFields
+	if err = binary.Read(r, binary.LittleEndian, &loc); err != nil {
+		return
 	}
 
+	g.setLocation(p, &loc)
+
+	//log.Printf("[%02x]: %04x, %04x\n", uint8(p.Index), p.X, p.Y)
+
+	return
+}
+
+// setLocation updates the player's location and refreshes the players list if it changed
+func (g *Game) setLocation(p *Player, loc *locationFields) {
+	p.Module = loc.Module
+	p.SubModule = loc.SubModule
+	p.SubSubModule = loc.SubSubModule
+
 	lastLocation := p.Location
-	if p.Location, err = readU24(r); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.Location = uint32(loc.LocationLo) | uint32(loc.LocationHi)<<8
 
 	// decode location and assign DungeonRoom or OverworldArea:
 	if p.Location&(1<<16) != 0 {
@@ -172,60 +321,33 @@ func (g *Game) DeserializeLocation(p *Player, r io.Reader) (err error) {
 		g.shouldUpdatePlayersList = true
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, &p.X); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
-	if err = binary.Read(r, binary.LittleEndian, &p.Y); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.X = loc.X
+	p.Y = loc.Y
 
 	lastDungeon := p.Dungeon
-	if err = binary.Read(r, binary.LittleEndian, &p.Dungeon); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.Dungeon = loc.Dungeon
 	if p.Dungeon != lastDungeon {
 		g.shouldUpdatePlayersList = true
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, &p.DungeonEntrance); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.DungeonEntrance = loc.DungeonEntrance
 
-	if err = binary.Read(r, binary.LittleEndian, &p.LastOverworldX); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
-	if err = binary.Read(r, binary.LittleEndian, &p.LastOverworldY); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.LastOverworldX = loc.LastOverworldX
+	p.LastOverworldY = loc.LastOverworldY
 
-	if err = binary.Read(r, binary.LittleEndian, &p.XOffs); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
-	if err = binary.Read(r, binary.LittleEndian, &p.YOffs); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.XOffs = loc.XOffs
+	p.YOffs = loc.YOffs
 
 	lastColor := p.PlayerColor
-	if err = binary.Read(r, binary.LittleEndian, &p.PlayerColor); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
+	p.PlayerColor = loc.PlayerColor
 	if p.PlayerColor != lastColor {
 		g.shouldUpdatePlayersList = true
 	}
-
-	var inSM uint8
-	if err = binary.Read(r, binary.LittleEndian, &inSM); err != nil {
-		panic(fmt.Errorf("error deserializing location: %w", err))
-	}
-
-	//log.Printf("[%02x]: %04x, %04x\n", uint8(p.Index), p.X, p.Y)
-
-	return
 }
 
 func (g *Game) DeserializeSfx(p *Player, r io.Reader) (err error) {
 	var dummy [2]byte
-	_, err = r.Read(dummy[:])
+	_, err = io.ReadFull(r, dummy[:])
 	return
 }
 
@@ -234,38 +356,38 @@ func (g *Game) DeserializeSprites1(p *Player, r io.Reader) (err error) {
 		length uint8
 	)
 	if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
-		panic(fmt.Errorf("error deserializing sprites: %w", err))
+		return
 	}
 
 	for i := uint8(0); i < length; i++ {
 		var spr [6]byte
-		if _, err = r.Read(spr[:]); err != nil {
-			panic(fmt.Errorf("error deserializing sprite %d: %w", i, err))
+		if _, err = io.ReadFull(r, spr[:]); err != nil {
+			return
 		}
 		if spr[0]&0x80 != 0 {
 			// sprite graphics data 4bpp:
 			var gfx [32]byte
-			if _, err = r.Read(gfx[:]); err != nil {
-				panic(fmt.Errorf("error deserializing sprite %d gfx 0: %w", i, err))
+			if _, err = io.ReadFull(r, gfx[:]); err != nil {
+				return
 			}
 			size := (spr[5] >> 1) & 1
 			if size != 0 {
-				if _, err = r.Read(gfx[:]); err != nil {
-					panic(fmt.Errorf("error deserializing sprite %d gfx 1: %w", i, err))
+				if _, err = io.ReadFull(r, gfx[:]); err != nil {
+					return
 				}
-				if _, err = r.Read(gfx[:]); err != nil {
-					panic(fmt.Errorf("error deserializing sprite %d gfx 2: %w", i, err))
+				if _, err = io.ReadFull(r, gfx[:]); err != nil {
+					return
 				}
-				if _, err = r.Read(gfx[:]); err != nil {
-					panic(fmt.Errorf("error deserializing sprite %d gfx 3: %w", i, err))
+				if _, err = io.ReadFull(r, gfx[:]); err != nil {
+					return
 				}
 			}
 		}
 		if spr[5]&0x80 != 0 {
 			// palette data:
 			var pal [32]byte
-			if _, err = r.Read(pal[:]); err != nil {
-				panic(fmt.Errorf("error deserializing sprite %d palette: %w", i, err))
+			if _, err = io.ReadFull(r, pal[:]); err != nil {
+				return
 			}
 		}
 	}
@@ -275,8 +397,8 @@ func (g *Game) DeserializeSprites1(p *Player, r io.Reader) (err error) {
 
 func (g *Game) DeserializeSprites2(p *Player, r io.Reader) (err error) {
 	var dummy [1]byte
-	if _, err = r.Read(dummy[:]); err != nil {
-		panic(fmt.Errorf("error deserializing sprite2: %w", err))
+	if _, err = io.ReadFull(r, dummy[:]); err != nil {
+		return
 	}
 	// TODO: pass in start flag
 	return g.DeserializeSprites1(p, r)
@@ -287,56 +409,60 @@ func (g *Game) DeserializeWRAM(p *Player, r io.Reader) (err error) {
 	var offsStart uint16
 
 	if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
-		panic(fmt.Errorf("error deserializing wram: %w", err))
+		return
 	}
 	if err = binary.Read(r, binary.LittleEndian, &offsStart); err != nil {
-		panic(fmt.Errorf("error deserializing wram: %w", err))
-	}
-
-	if count > 0 && p.WRAM == nil {
-		p.WRAM = make(map[uint16]*SyncableWRAM)
+		return
 	}
 
 	for i := uint8(0); i < count; i++ {
 		var timestamp uint32
 		var value uint16
 		if err = binary.Read(r, binary.LittleEndian, &timestamp); err != nil {
-			panic(fmt.Errorf("error deserializing wram: %w", err))
+			return
 		}
 		if err = binary.Read(r, binary.LittleEndian, &value); err != nil {
-			panic(fmt.Errorf("error deserializing wram: %w", err))
-		}
-
-		offs := offsStart + uint16(i)
-		w, ok := p.WRAM[offs]
-		if !ok {
-			w = &SyncableWRAM{
-				g:         g,
-				Offset:    uint32(offs),
-				Name:      fmt.Sprintf("wram[$%04x]", offs),
-				Size:      2,
-				Timestamp: timestamp,
-				Value:     value,
-				Fresh:     new(bool),
-			}
-			*w.Fresh = true
-			p.WRAM[offs] = w
-		} else {
-			w.PreviousValue = w.Value
-			w.PreviousTimestamp = w.Timestamp
-			w.Timestamp = timestamp
-			w.Value = value
+			return
 		}
+
+		g.setWRAM(p, offsStart+uint16(i), timestamp, value)
 	}
 
 	return
 }
 
+// setWRAM records a timestamped WRAM word received from the player
+func (g *Game) setWRAM(p *Player, offs uint16, timestamp uint32, value uint16) {
+	if p.WRAM == nil {
+		p.WRAM = make(map[uint16]*SyncableWRAM)
+	}
+
+	w, ok := p.WRAM[offs]
+	if !ok {
+		w = &SyncableWRAM{
+			g:         g,
+			Offset:    uint32(offs),
+			Name:      fmt.Sprintf("wram[$%04x]", offs),
+			Size:      2,
+			Timestamp: timestamp,
+			Value:     value,
+			Fresh:     new(bool),
+		}
+		*w.Fresh = true
+		p.WRAM[offs] = w
+	} else {
+		w.PreviousValue = w.Value
+		w.PreviousTimestamp = w.Timestamp
+		w.Timestamp = timestamp
+		w.Value = value
+	}
+}
+
 func (g *Game) DeserializeSRAM(p *Player, r io.Reader) (err error) {
 	// something about SM:
 	var dummy [2]byte
-	if _, err = r.Read(dummy[:]); err != nil {
-		panic(fmt.Errorf("error deserializing sram: %w", err))
+	if _, err = io.ReadFull(r, dummy[:]); err != nil {
+		return
 	}
 
 	var (
@@ -344,21 +470,128 @@ func (g *Game) DeserializeSRAM(p *Player, r io.Reader) (err error) {
 		count uint16
 	)
 	if err = binary.Read(r, binary.LittleEndian, &start); err != nil {
-		panic(fmt.Errorf("error deserializing sram: %w", err))
+		return
 	}
 	if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
-		panic(fmt.Errorf("error deserializing sram: %w", err))
+		return
 	}
 
-	if _, err = r.Read(p.SRAM.data[start : start+count]); err != nil {
-		panic(fmt.Errorf("error deserializing sram: %w", err))
+	end := uint32(start) + uint32(count)
+	if end > uint32(len(p.SRAM.data)) {
+		return fmt.Errorf("$%03x+%d: %w", start, count, ErrOutOfBounds)
 	}
-	for j := start; j < start+count; j++ {
+
+	if _, err = io.ReadFull(r, p.SRAM.data[start:end]); err != nil {
+		return
+	}
+	for j := uint32(start); j < end; j++ {
 		p.SRAM.fresh[j] = true
 	}
 	return
 }
 
+// sramDeltaMaxRun is the longest run of bytes a single SRAM delta run can carry
+const sramDeltaMaxRun = 0xFF
+
+// sramDeltaMergeGap is the longest gap of unchanged bytes between two changed runs that is no more expensive to send
+// inside a single run than to split into two runs, since each run costs a 3-byte header
+const sramDeltaMergeGap = 3
+
+type sramRun struct {
+	offset uint16
+	length uint8
+}
+
+// sramDeltaRuns finds the runs of bytes in data[start:endExclusive] that differ from baseline
+func sramDeltaRuns(data, baseline *[0x500]byte, start, endExclusive uint16) (runs []sramRun) {
+	i := start
+	for i < endExclusive {
+		if data[i] == baseline[i] {
+			i++
+			continue
+		}
+
+		// extend the run while bytes differ or the gap of unchanged bytes is small enough to merge:
+		runStart := i
+		runEnd := i + 1
+		for j := runEnd; j < endExclusive && j-runStart < sramDeltaMaxRun; j++ {
+			if data[j] != baseline[j] {
+				runEnd = j + 1
+			} else if j-runEnd >= sramDeltaMergeGap {
+				break
+			}
+		}
+
+		runs = append(runs, sramRun{offset: runStart, length: uint8(runEnd - runStart)})
+		i = runEnd
+	}
+	return
+}
+
+// SerializeSRAMDelta serializes only the runs of bytes in SRAM[start:endExclusive] that differ from baseline and
+// then updates baseline to match. Nothing is written if no bytes differ.
+func (g *Game) SerializeSRAMDelta(p *Player, w io.Writer, baseline *[0x500]byte, start, endExclusive uint16) (err error) {
+	runs := sramDeltaRuns(p.SRAM.data, baseline, start, endExclusive)
+	if len(runs) == 0 {
+		return
+	}
+
+	if err = binary.Write(w, binary.LittleEndian, uint8(MsgSRAMDelta)); err != nil {
+		panic(fmt.Errorf("error serializing sram delta: %w", err))
+	}
+
+	count := uint16(len(runs))
+	if err = binary.Write(w, binary.LittleEndian, &count); err != nil {
+		panic(fmt.Errorf("error serializing sram delta: %w", err))
+	}
+
+	for _, run := range runs {
+		if err = binary.Write(w, binary.LittleEndian, &run.offset); err != nil {
+			panic(fmt.Errorf("error serializing sram delta: %w", err))
+		}
+		if err = binary.Write(w, binary.LittleEndian, &run.length); err != nil {
+			panic(fmt.Errorf("error serializing sram delta: %w", err))
+		}
+
+		end := run.offset + uint16(run.length)
+		if _, err = w.Write(p.SRAM.data[run.offset:end]); err != nil {
+			panic(fmt.Errorf("error serializing sram delta: %w", err))
+		}
+		copy(baseline[run.offset:end], p.SRAM.data[run.offset:end])
+	}
+	return
+}
+
+func (g *Game) DeserializeSRAMDelta(p *Player, r io.Reader) (err error) {
+	var count uint16
+	if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return
+	}
+
+	for i := uint16(0); i < count; i++ {
+		var run sramRun
+		if err = binary.Read(r, binary.LittleEndian, &run.offset); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.LittleEndian, &run.length); err != nil {
+			return
+		}
+
+		end := uint32(run.offset) + uint32(run.length)
+		if end > uint32(len(p.SRAM.data)) {
+			return fmt.Errorf("run $%03x+%d: %w", run.offset, run.length, ErrOutOfBounds)
+		}
+
+		if _, err = io.ReadFull(r, p.SRAM.data[run.offset:end]); err != nil {
+			return
+		}
+		for j := uint32(run.offset); j < end; j++ {
+			p.SRAM.fresh[j] = true
+		}
+	}
+	return
+}
+
 func (g *Game) DeserializeTilemaps(p *Player, r io.Reader) (err error) {
 	var (
 		timestamp uint32
@@ -367,18 +600,18 @@ func (g *Game) DeserializeTilemaps(p *Player, r io.Reader) (err error) {
 		length    uint8
 	)
 	if err = binary.Read(r, binary.LittleEndian, &timestamp); err != nil {
-		panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+		return
 	}
 	if location, err = readU24(r); err != nil {
-		panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+		return
 	}
 	_ = location
 
 	if err = binary.Read(r, binary.LittleEndian, &start); err != nil {
-		panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+		return
 	}
 	if err = binary.Read(r, binary.LittleEndian, &length); err != nil {
-		panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+		return
 	}
 
 	for i := uint8(0); i < length; i++ {
@@ -387,23 +620,23 @@ func (g *Game) DeserializeTilemaps(p *Player, r io.Reader) (err error) {
 			count uint8
 		)
 		if err = binary.Read(r, binary.LittleEndian, &offs); err != nil {
-			panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+			return
 		}
 		if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
-			panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+			return
 		}
 
 		same := (offs & 0x8000) != 0
 		if same {
 			var tile [3]byte
-			if _, err = r.Read(tile[:]); err != nil {
-				panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+			if _, err = io.ReadFull(r, tile[:]); err != nil {
+				return
 			}
 		} else {
 			for j := uint8(0); j < count; j++ {
 				var tile [3]byte
-				if _, err = r.Read(tile[:]); err != nil {
-					panic(fmt.Errorf("error deserializing tilemaps: %w", err))
+				if _, err = io.ReadFull(r, tile[:]); err != nil {
+					return
 				}
 			}
 		}
@@ -413,30 +646,30 @@ func (g *Game) DeserializeTilemaps(p *Player, r io.Reader) (err error) {
 }
 
 func (g *Game) DeserializeObjects(p *Player, r io.Reader) (err error) {
-	panic(fmt.Errorf("not implemented"))
+	return ErrUnsupportedMessage
 }
 
 func (g *Game) DeserializeAncillae(p *Player, r io.Reader) (err error) {
 	var count uint8
 	if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
-		panic(fmt.Errorf("error deserializing ancillae: %w", err))
+		return
 	}
 
 	for i := uint8(0); i < count; i++ {
 		var index uint8
 		if err = binary.Read(r, binary.LittleEndian, &index); err != nil {
-			panic(fmt.Errorf("error deserializing ancillae: %w", err))
+			return
 		}
 		index = index & 0x7F
 
 		var facts [0x20]byte
 		if index < 5 {
-			if _, err = r.Read(facts[:0x20]); err != nil {
-				panic(fmt.Errorf("error deserializing ancillae: %w", err))
+			if _, err = io.ReadFull(r, facts[:0x20]); err != nil {
+				return
 			}
 		} else {
-			if _, err = r.Read(facts[:0x16]); err != nil {
-				panic(fmt.Errorf("error deserializing ancillae: %w", err))
+			if _, err = io.ReadFull(r, facts[:0x16]); err != nil {
+				return
 			}
 		}
 	}
@@ -449,32 +682,25 @@ func (g *Game) DeserializeTorches(p *Player, r io.Reader) (err error) {
 		count uint8
 	)
 	if err = binary.Read(r, binary.LittleEndian, &count); err != nil {
-		panic(fmt.Errorf("error deserializing torches: %w", err))
+		return
 	}
 	for i := uint8(0); i < count; i++ {
 		var torch [2]byte
-		if _, err = r.Read(torch[:]); err != nil {
-			panic(fmt.Errorf("error deserializing torches: %w", err))
+		if _, err = io.ReadFull(r, torch[:]); err != nil {
+			return
 		}
 	}
 	return
 }
 
 func (g *Game) DeserializePvP(p *Player, r io.Reader) (err error) {
-	panic(fmt.Errorf("not implemented"))
+	return ErrUnsupportedMessage
 }
 
 func (g *Game) DeserializePlayerName(p *Player, r io.Reader) (err error) {
 	var name [20]byte
-	if _, err = r.Read(name[:]); err != nil {
-		panic(fmt.Errorf("error deserializing name: %w", err))
-	}
-	lastName := p.NameF
-	p.NameF = strings.Trim(string(name[:]), " \t\n\r\000")
-	if lastName != p.NameF {
-		p.showJoinMessage = true
-		// refresh the players list
-		g.shouldUpdatePlayersList = true
+	if _, err = io.ReadFull(r, name[:]); err != nil {
+		return
 	}
 
 	var isZero bool
@@ -486,11 +712,6 @@ func (g *Game) DeserializePlayerName(p *Player, r io.Reader) (err error) {
 		_ = binary.Read(r, binary.LittleEndian, &startNano)
 		startTime = time.Unix(startNano/1e9, startNano%1e9)
 	}
-	if startTime != p.GameStartTime {
-		p.GameStartTime = startTime
-		// refresh the players list
-		g.shouldUpdatePlayersList = true
-	}
 
 	_ = binary.Read(r, binary.LittleEndian, &isZero)
 	var finishTime time.Time
@@ -499,12 +720,59 @@ func (g *Game) DeserializePlayerName(p *Player, r io.Reader) (err error) {
 		_ = binary.Read(r, binary.LittleEndian, &finishNano)
 		finishTime = time.Unix(finishNano/1e9, finishNano%1e9)
 	}
+
+	g.setPlayerName(p, string(name[:]), startTime, finishTime)
+	return
+}
+
+// setPlayerName updates the player's name and game times and refreshes the players list if they changed
+func (g *Game) setPlayerName(p *Player, name string, startTime, finishTime time.Time) {
+	lastName := p.NameF
+	p.NameF = strings.Trim(name, " \t\n\r\000")
+	if lastName != p.NameF {
+		p.showJoinMessage = true
+		// refresh the players list
+		g.shouldUpdatePlayersList = true
+	}
+
+	if startTime != p.GameStartTime {
+		p.GameStartTime = startTime
+		// refresh the players list
+		g.shouldUpdatePlayersList = true
+	}
+
 	if finishTime != p.GameFinishTime {
 		p.GameFinishTime = finishTime
 		// refresh the players list
 		g.shouldUpdatePlayersList = true
 	}
+}
+
+func (g *Game) SerializePlayerName(p *Player, w io.Writer) (err error) {
+	if err = binary.Write(w, binary.LittleEndian, uint8(MsgPlayerName)); err != nil {
+		panic(fmt.Errorf("error serializing player name: %w", err))
+	}
+
+	var name [20]byte
+	n := copy(name[:], p.Name())
+	for ; n < 20; n++ {
+		name[n] = ' '
+	}
+	if _, err = w.Write(name[:]); err != nil {
+		panic(fmt.Errorf("error serializing player name: %w", err))
+	}
 
+	for _, t := range []time.Time{p.GameStartTime, p.GameFinishTime} {
+		if err = binary.Write(w, binary.LittleEndian, t.IsZero()); err != nil {
+			panic(fmt.Errorf("error serializing player name: %w", err))
+		}
+		if t.IsZero() {
+			continue
+		}
+		if err = binary.Write(w, binary.LittleEndian, t.UnixNano()); err != nil {
+			panic(fmt.Errorf("error serializing player name: %w", err))
+		}
+	}
 	return
 }
 
@@ -0,0 +1,143 @@
+package alttp
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"o2/snes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// dumpPlayer describes the decoded state of a remote player for comparison against golden files
+func dumpPlayer(p *Player) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "team: %d\n", p.Team)
+	fmt.Fprintf(b, "frame: $%02x\n", p.Frame)
+	fmt.Fprintf(b, "module: $%02x $%02x $%02x\n", uint8(p.Module), p.SubModule, p.SubSubModule)
+	fmt.Fprintf(b, "location: $%06x dungeonRoom: $%04x overworldArea: $%04x\n", p.Location, p.DungeonRoom, p.OverworldArea)
+	fmt.Fprintf(b, "position: $%04x,$%04x offset: %d,%d\n", p.X, p.Y, p.XOffs, p.YOffs)
+	fmt.Fprintf(b, "dungeon: $%04x entrance: $%04x\n", p.Dungeon, p.DungeonEntrance)
+	fmt.Fprintf(b, "lastOverworld: $%04x,$%04x\n", p.LastOverworldX, p.LastOverworldY)
+	fmt.Fprintf(b, "playerColor: $%04x\n", p.PlayerColor)
+	fmt.Fprintf(b, "name: %q\n", p.Name())
+	fmt.Fprintf(b, "gameStartTime: %d\n", timeUnixNano(p.GameStartTime))
+	fmt.Fprintf(b, "gameFinishTime: %d\n", timeUnixNano(p.GameFinishTime))
+
+	offsets := make([]int, 0, len(p.WRAM))
+	for offs := range p.WRAM {
+		offsets = append(offsets, int(offs))
+	}
+	sort.Ints(offsets)
+	for _, offs := range offsets {
+		if w := p.WRAM[uint16(offs)]; w.Value != 0 || w.Timestamp != 0 {
+			fmt.Fprintf(b, "wram[$%04x]: $%04x @ ts=%08x\n", offs, w.Value, w.Timestamp)
+		}
+	}
+
+	for offs, v := range p.SRAM.data {
+		if v != 0 {
+			fmt.Fprintf(b, "sram[$%03x]: $%02x\n", offs, v)
+		}
+	}
+	return b.String()
+}
+
+func timeUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// TestDeserialize_Golden decodes a sequence of payloads for each supported SerializationVersion and compares the
+// decoded player against a golden file. Run with -update to rewrite the golden files.
+//
+// The 0x14 payloads were written by the baseline 0x14 encoder and the 0x15 payloads by this package's current encoder;
+// see testdata/payloads/README.md for how they were produced.
+func TestDeserialize_Golden(t *testing.T) {
+	for version := range serializationFormats {
+		dir := filepath.Join("testdata", "payloads", fmt.Sprintf("v%02x", version))
+
+		t.Run(fmt.Sprintf("v%02x", version), func(t *testing.T) {
+			files, err := filepath.Glob(filepath.Join(dir, "*.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) == 0 {
+				t.Fatalf("no payloads in %s", dir)
+			}
+			sort.Strings(files)
+
+			g := newGame(&snes.ROM{})
+			p := &Player{IndexF: 1}
+			p.SRAM.data = new([0x500]byte)
+			p.SRAM.fresh = new([0x500]bool)
+
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected, actual := version, data[0]; expected != actual {
+					t.Fatalf("%s: expected serialization version $%02x, got $%02x", file, expected, actual)
+				}
+				if err = g.deserializeBroadcast(data, p, false); err != nil {
+					t.Fatalf("%s: %v", file, err)
+				}
+			}
+			if p.incompatible {
+				t.Errorf("expected player to remain compatible, warning %q", p.CompatibilityWarning)
+			}
+
+			actual := dumpPlayer(p)
+			golden := dir + ".golden"
+			if *updateGolden {
+				if err = os.WriteFile(golden, []byte(actual), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(expected) != actual {
+				t.Errorf("decoded player does not match %s:\n--- expected\n%s--- actual\n%s", golden, expected, actual)
+			}
+		})
+	}
+}
+
+func TestDeserialize_OlderVersion(t *testing.T) {
+	g := newGame(&snes.ROM{})
+	p := &Player{IndexF: 1}
+	p.SRAM.data = new([0x500]byte)
+	p.SRAM.fresh = new([0x500]bool)
+
+	data, err := os.ReadFile(filepath.Join("testdata", "payloads", "v14", "frame-00.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.deserializeBroadcast(data, p, false); err != nil {
+		t.Fatal(err)
+	}
+	if p.incompatible {
+		t.Error("expected player on an older supported version to remain compatible")
+	}
+	if expected, actual := "serialization version 0x14 without ROM hash, sync options, client version", p.CompatibilityWarning; expected != actual {
+		t.Errorf("expected warning %q, got %q", expected, actual)
+	}
+
+	// SRAM deltas did not exist in 0x14:
+	err = g.deserialize(bytes.NewReader([]byte{0x14, 0, 0, byte(MsgSRAMDelta), 0, 0}), p, false)
+	if !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("expected ErrUnknownMessageType, got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package alttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log"
+	"o2/client"
+	"o2/client/loopback"
+	"o2/client/protocol02"
+	"o2/client/protocol03"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSRAMDeltaRuns(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed []uint16
+		start   uint16
+		end     uint16
+		want    []sramRun
+	}{
+		{"none", nil, 0x000, 0x250, nil},
+		{"single", []uint16{0x010}, 0x000, 0x250, []sramRun{{0x010, 1}}},
+		{"merged over small gap", []uint16{0x010, 0x014}, 0x000, 0x250, []sramRun{{0x010, 5}}},
+		{"split over large gap", []uint16{0x010, 0x015}, 0x000, 0x250, []sramRun{{0x010, 1}, {0x015, 1}}},
+		{"outside range", []uint16{0x010, 0x300}, 0x280, 0x340, []sramRun{{0x300, 1}}},
+		{"at range end", []uint16{0x24F}, 0x000, 0x250, []sramRun{{0x24F, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data, baseline [0x500]byte
+			for _, offs := range tt.changed {
+				data[offs] = 0xFF
+			}
+			if got := sramDeltaRuns(&data, &baseline, tt.start, tt.end); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sramDeltaRuns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long run", func(t *testing.T) {
+		var data, baseline [0x500]byte
+		for i := 0; i < 0x250; i++ {
+			data[i] = 0xFF
+		}
+		want := []sramRun{{0x000, 0xFF}, {0x0FF, 0xFF}, {0x1FE, 0x52}}
+		if got := sramDeltaRuns(&data, &baseline, 0x000, 0x250); !reflect.DeepEqual(got, want) {
+			t.Errorf("sramDeltaRuns() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestGame_SerializeSRAMDelta(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+	local, remote := g.local, &g.players[1]
+
+	var baseline [0x500]byte
+	local.SRAM.data[0x340] = 0x01
+	local.SRAM.data[0x343] = 0x0A
+	local.SRAM.data[0x400] = 0x02
+
+	w := &bytes.Buffer{}
+	if err = g.SerializeSRAMDelta(local, w, &baseline, 0x340, 0x37C); err != nil {
+		t.Fatal(err)
+	}
+	// the change at $400 lies outside the range:
+	if expected, actual := uint8(0), baseline[0x400]; expected != actual {
+		t.Errorf("expected baseline[$400] == $%02x, got $%02x", expected, actual)
+	}
+
+	var msgType MessageType
+	if err = binary.Read(w, binary.LittleEndian, &msgType); err != nil {
+		t.Fatal(err)
+	}
+	if msgType != MsgSRAMDelta {
+		t.Fatalf("expected message type %d, got %d", MsgSRAMDelta, msgType)
+	}
+	if err = g.DeserializeSRAMDelta(remote, w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("expected delta to be fully consumed, %d bytes remain", w.Len())
+	}
+
+	for _, offs := range []uint16{0x340, 0x341, 0x342, 0x343} {
+		if expected, actual := local.SRAM.data[offs], remote.SRAM.data[offs]; expected != actual {
+			t.Errorf("expected sram[$%03x] == $%02x, got $%02x", offs, expected, actual)
+		}
+		if !remote.SRAM.fresh[offs] {
+			t.Errorf("expected sram[$%03x] to be fresh", offs)
+		}
+	}
+	if remote.SRAM.fresh[0x344] {
+		t.Errorf("expected sram[$344] to not be fresh")
+	}
+
+	// nothing changed since the baseline was updated:
+	w.Reset()
+	if err = g.SerializeSRAMDelta(local, w, &baseline, 0x340, 0x37C); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("expected no delta to be written, got %d bytes", w.Len())
+	}
+}
+
+func TestGame_DeserializeSRAMDelta_OutOfBounds(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 run of 16 bytes at $4F8:
+	r := bytes.NewReader([]byte{0x01, 0x00, 0xF8, 0x04, 0x10})
+	if err = gs.g.DeserializeSRAMDelta(&gs.g.players[1], r); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("expected ErrOutOfBounds for an out of bounds run, got %v", err)
+	}
+}
+
+// testMessage builds a message containing one of each kind of message the local player broadcasts every frame
+func testMessage(g *Game) *gameBroadcastMessage {
+	local := g.local
+	local.SRAM.data[0x340] = 0x01
+
+	m := g.makeBroadcastMessage()
+	m.writeLocation(local)
+	m.writeSRAM(local, 0x340, 0x350)
+	var baseline [0x500]byte
+	m.writeSRAMDelta(local, &baseline, 0x340, 0x350)
+	m.writeWRAM(local, smallKeyFirst, 2)
+	return m
+}
+
+// testFrame serializes a frame containing one of each kind of message the local player broadcasts
+func testFrame(t testing.TB, g *Game) []byte {
+	return testMessage(g).Bytes()
+}
+
+func TestGame_Deserialize_Malformed(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+	frame := testFrame(t, g)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantType   MessageType
+		wantOffset int
+		wantErr    error
+	}{
+		{"empty", []byte{}, 0, 0, io.ErrUnexpectedEOF},
+		{"version mismatch", []byte{0x13, 0, 0}, 0, 0, ErrSerializationVersion},
+		{"truncated header", []byte{SerializationVersion, 0}, 0, 2, io.ErrUnexpectedEOF},
+		{"unknown message type", []byte{SerializationVersion, 0, 0, byte(MsgMaxMessageType)}, MsgMaxMessageType, 3, ErrUnknownMessageType},
+		{"zero message type", []byte{SerializationVersion, 0, 0, 0}, 0, 3, ErrUnknownMessageType},
+		{"unsupported message type", []byte{SerializationVersion, 0, 0, byte(MsgObjects)}, MsgObjects, 3, ErrUnsupportedMessage},
+		{"truncated location", frame[:6], MsgLocation, 3, io.ErrUnexpectedEOF},
+		{"truncated message type only", []byte{SerializationVersion, 0, 0, byte(MsgWRAM)}, MsgWRAM, 3, io.ErrUnexpectedEOF},
+		{"truncated frame", frame[:len(frame)-1], MsgWRAM, -1, io.ErrUnexpectedEOF},
+		{"sram out of bounds", []byte{SerializationVersion, 0, 0, byte(MsgSRAM), 0, 0, 0xFF, 0x04, 0x02, 0x00, 0, 0}, MsgSRAM, 3, ErrOutOfBounds},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &g.players[1]
+			err := g.deserialize(bytes.NewReader(tt.data), p, false)
+
+			var derr *DeserializeError
+			if !errors.As(err, &derr) {
+				t.Fatalf("expected a DeserializeError, got %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantType != derr.Type {
+				t.Errorf("expected message type %v, got %v", tt.wantType, derr.Type)
+			}
+			if tt.wantOffset >= 0 && tt.wantOffset != derr.Offset {
+				t.Errorf("expected offset %d, got %d", tt.wantOffset, derr.Offset)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		p := &g.players[1]
+		if err := g.deserialize(bytes.NewReader(frame), p, false); err != nil {
+			t.Fatal(err)
+		}
+		if expected, actual := uint8(0x01), p.SRAM.data[0x340]; expected != actual {
+			t.Errorf("expected sram[$340] == $%02x, got $%02x", expected, actual)
+		}
+	})
+}
+
+func TestGame_HandleNetMessage_BadPackets(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gs.g
+	now := time.Now()
+	g.localClock = func() time.Time { return now }
+
+	frame := testFrame(t, g)
+	gm := &protocol03.GroupMessage{
+		PlayerIndex:  1,
+		BroadcastAll: &protocol03.BroadcastAll{Data: frame[:len(frame)-1]},
+	}
+	b, err := proto.MarshalOptions{}.MarshalAppend(client.MakePacket(0x03).Bytes(), gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// player indexes beyond MaxPlayers:
+	gm = &protocol03.GroupMessage{
+		PlayerIndex:  MaxPlayers,
+		BroadcastAll: &protocol03.BroadcastAll{Data: frame},
+	}
+	badIndex3, err := proto.MarshalOptions{}.MarshalAppend(client.MakePacket(0x03).Bytes(), gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badIndex2 := protocol02.MakePacket(g.client.Group(), protocol02.Broadcast, MaxPlayers)
+	badIndex2.Write(frame)
+
+	var derr *DeserializeError
+	if err = g.handleGroupMessage(gm); !errors.As(err, &derr) || !errors.Is(err, ErrPlayerIndex) {
+		t.Errorf("expected a player index DeserializeError, got %v", err)
+	}
+
+	logger.b.Reset()
+	for _, msg := range [][]byte{
+		{},
+		{0x1F, 0x65},
+		{0x00, 0x00, 0x03},
+		// truncated protocol 02 header:
+		{0x1F, 0x65, 0x02, 0x00},
+		b,
+		badIndex2.Bytes(),
+		badIndex3,
+	} {
+		if err = g.handleNetMessage(msg); err != nil {
+			t.Errorf("expected bad packet % x to be dropped, got %v", msg, err)
+		}
+	}
+
+	if expected, actual := uint64(1), g.players[1].BadPackets; expected != actual {
+		t.Errorf("expected %d bad packets from player, got %d", expected, actual)
+	}
+	if expected, actual := uint64(7), g.netStats.snapshot(time.Now()).BadPackets; expected != actual {
+		t.Errorf("expected %d bad packets in total, got %d", expected, actual)
+	}
+
+	// only the first bad packet within the log interval is logged:
+	if expected, actual := 1, strings.Count(logger.b.String(), "dropped bad packet"); expected != actual {
+		t.Errorf("expected %d logged bad packet, got %d:\n%s", expected, actual, logger.b.String())
+	}
+	logger.b.Reset()
+
+	now = now.Add(badPacketLogInterval)
+	if err = g.handleNetMessage([]byte{}); err != nil {
+		t.Errorf("expected bad packet to be dropped, got %v", err)
+	}
+	if expected, actual := "(6 more bad packets not logged)", logger.b.String(); !strings.Contains(actual, expected) {
+		t.Errorf("expected log to mention %q, got %q", expected, actual)
+	}
+	if expected, actual := uint64(8), g.netStats.snapshot(time.Now()).BadPackets; expected != actual {
+		t.Errorf("expected %d bad packets in total, got %d", expected, actual)
+	}
+}
+
+// FuzzGame_HandleNetMessage checks that no packet, however malformed, panics while being decoded
+func FuzzGame_HandleNetMessage(f *testing.F) {
+	setupTestLogger(f)
+	logger := log.Writer().(*testLogger)
+
+	gs, err := createTestGameSync("VT test", "g1", loopback.NewHub().NewClient("test"), logger)
+	if err != nil {
+		f.Fatal(err)
+	}
+	g := gs.g
+
+	frame := testFrame(f, g)
+	for _, gm := range []*protocol03.GroupMessage{
+		{PlayerIndex: 1, BroadcastAll: &protocol03.BroadcastAll{Data: frame}},
+		{PlayerIndex: 2, BroadcastSector: &protocol03.BroadcastSector{Data: frame}},
+	} {
+		b, err := proto.MarshalOptions{}.MarshalAppend(client.MakePacket(0x03).Bytes(), gm)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	{
+		var group [20]byte
+		pkt := protocol02.MakePacket(group[:], protocol02.Broadcast, 1)
+		pkt.Write(frame)
+		f.Add(pkt.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		_ = g.handleNetMessage(msg)
+		// discard the log of each run:
+		logger.b.Reset()
+	})
+}
+
+func TestGameSync_Loopback_SRAMDelta(t *testing.T) {
+	setupTestLogger(t)
+	logger := log.Writer().(*testLogger)
+
+	hub := loopback.NewHub()
+
+	var gs [2]gameSync
+	for i := range gs {
+		var err error
+		gs[i], err = createTestGameSync("VT test", fmt.Sprintf("g%d", i+1), hub.NewClient("test"), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runFrame := func() {
+		for i := range gs {
+			gs[i].runFrame(t)
+			hub.Flush()
+		}
+	}
+
+	hub.Flush()
+	for i := range gs {
+		gameHandleNet(gs[i].g)
+		gs[i].e.WRAM[0x10] = 0x07
+		gs[i].e.WRAM[0x040C] = 0
+	}
+	// run past the initial keyframes:
+	for f := 0; f < 32; f++ {
+		runFrame()
+	}
+	if gs[0].g.sramKeyframeCountdown[syncItems] == 0 {
+		t.Fatal("g1: expected the next items broadcast to be a delta")
+	}
+
+	// g1 picks up the bow:
+	gs[0].e.WRAM[0xF340] = 0x01
+	for f := 0; f < 32; f++ {
+		runFrame()
+	}
+
+	remote := &gs[1].g.players[gs[0].g.local.Index()]
+	if expected, actual := uint8(0x01), remote.SRAM.data[0x340]; expected != actual {
+		t.Errorf("g2: expected g1 sram[$340] == $%02x, got $%02x", expected, actual)
+	}
+}
@@ -613,6 +613,10 @@ func (g *Game) initSync() {
 			if p.DungeonRoom != g.local.DungeonRoom {
 				return false
 			}
+			// door state is only received from players in the local player's sector:
+			if !p.InSector(g.localSector()) {
+				return false
+			}
 			return true
 		}
 		g.NewSyncable(games.WRAM, 0x0400, doorSync)
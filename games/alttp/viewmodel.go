@@ -3,6 +3,7 @@ package alttp
 import (
 	"fmt"
 	"log"
+	"o2/client/protocol03"
 	"o2/interfaces"
 	"o2/util"
 	"time"
@@ -97,6 +98,95 @@ type PlayerViewModel struct {
 	AbsFinish string `json:"gameFinish"`
 	RelStart  string `json:"relStart"`
 	RelFinish string `json:"relFinish"`
+
+	// Items lists the names of the player's inventory items
+	Items []string `json:"items"`
+
+	// Warning describes how the player's capabilities differ from ours
+	Warning string `json:"warning"`
+}
+
+// NetworkViewModel reports network quality statistics to help diagnose sync problems
+type NetworkViewModel struct {
+	// HasRTT is false until the first echo reply is received
+	HasRTT   bool    `json:"hasRtt"`
+	RTTMs    float64 `json:"rttMs"`
+	JitterMs float64 `json:"jitterMs"`
+	// PacketLoss is the fraction of recent echoes that went unanswered, from 0 to 1
+	PacketLoss float64 `json:"packetLoss"`
+
+	// HasClock is false until the server clock has been estimated from an echo reply
+	HasClock bool `json:"hasClock"`
+	// ClockOffsetMs is the estimated server clock minus the local clock
+	ClockOffsetMs float64 `json:"clockOffsetMs"`
+	// ClockErrorMs bounds how far off the server clock estimate may be
+	ClockErrorMs float64 `json:"clockErrorMs"`
+	// ClockDriftPPM is the estimated drift of the server clock relative to the local clock in parts per million
+	ClockDriftPPM float64 `json:"clockDriftPpm"`
+
+	SentPacketsPerSecond float64 `json:"sentPacketsPerSecond"`
+	SentBytesPerSecond   float64 `json:"sentBytesPerSecond"`
+	RecvPacketsPerSecond float64 `json:"recvPacketsPerSecond"`
+	RecvBytesPerSecond   float64 `json:"recvBytesPerSecond"`
+	// BadPackets counts malformed packets that were dropped
+	BadPackets uint64 `json:"badPackets"`
+
+	MessageTypes []*MessageTypeStatsViewModel `json:"messageTypes"`
+	Players      []*PlayerNetworkViewModel    `json:"players"`
+}
+
+// MessageTypeStatsViewModel reports traffic rates for a single MessageType. Sent broadcasts are counted in full
+// under the first message type they contain; received messages are counted individually.
+type MessageTypeStatsViewModel struct {
+	Name string `json:"name"`
+
+	SentPacketsPerSecond float64 `json:"sentPacketsPerSecond"`
+	SentBytesPerSecond   float64 `json:"sentBytesPerSecond"`
+	RecvPacketsPerSecond float64 `json:"recvPacketsPerSecond"`
+	RecvBytesPerSecond   float64 `json:"recvBytesPerSecond"`
+}
+
+type PlayerNetworkViewModel struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// LastSeenMs approximates the time since the last message from the player, from its Ttl
+	LastSeenMs int `json:"lastSeenMs"`
+	// BadPackets counts malformed packets received from the player that were dropped
+	BadPackets uint64 `json:"badPackets"`
+}
+
+// updateNetworkViewModel recalculates network statistics and notifies the "network" view
+func (g *Game) updateNetworkViewModel() {
+	now := time.Now()
+	g.netStats.updateRates(now)
+
+	if g.viewModels == nil {
+		return
+	}
+
+	vm := g.netStats.snapshot(now)
+	var offset, errorBound time.Duration
+	var drift float64
+	vm.HasClock, offset, drift, errorBound = g.clock.estimate()
+	vm.ClockOffsetMs = durationMs(offset)
+	vm.ClockErrorMs = durationMs(errorBound)
+	vm.ClockDriftPPM = drift * 1e6
+	for _, p := range g.RemotePlayers() {
+		name := p.Name()
+		if name == "" {
+			name = fmt.Sprintf("player #%02x", p.Index())
+		}
+
+		// Ttl is reset to 255 on each message from the player and decremented once per game frame:
+		vm.Players = append(vm.Players, &PlayerNetworkViewModel{
+			Index:      p.Index(),
+			Name:       name,
+			LastSeenMs: (255 - p.TTL()) * 1000 / 60,
+			BadPackets: p.BadPackets,
+		})
+	}
+
+	g.viewModels.NotifyView("network", vm)
 }
 
 func FormatTime(t time.Time) string {
@@ -147,6 +237,40 @@ func (g *Game) updateRunTimer() {
 	g.viewModels.NotifyView("game/run/timer", runTimer)
 }
 
+// inventoryOffsets are the SRAM offsets of the inventory items listed in the players view model, in menu order
+var inventoryOffsets = []uint16{
+	0x340, 0x341, 0x342, 0x343, 0x344, 0x345, 0x346, 0x347, 0x348, 0x349, 0x34A, 0x34B, 0x34C, 0x34D, 0x34E,
+	0x350, 0x351, 0x352, 0x353, 0x354, 0x355, 0x356, 0x357, 0x359, 0x35A, 0x35B,
+	0x35C, 0x35D, 0x35E, 0x35F, 0x37B,
+}
+
+// itemNames returns the names of the inventory items the player has
+func (p *Player) itemNames() (items []string) {
+	items = make([]string, 0, len(inventoryOffsets))
+	if p.SRAM.data == nil {
+		return
+	}
+
+	for _, offs := range inventoryOffsets {
+		v := p.SRAM.data[offs]
+		if v == 0 {
+			continue
+		}
+
+		if offs == 0x343 {
+			items = append(items, fmt.Sprintf("%d bombs", v))
+			continue
+		}
+
+		names := vanillaItemNames[offs]
+		if int(v) > len(names) {
+			continue
+		}
+		items = append(items, names[v-1])
+	}
+	return
+}
+
 func (g *Game) updatePlayersList() {
 	g.shouldUpdatePlayersList = false
 
@@ -221,6 +345,10 @@ func (g *Game) updatePlayersList() {
 
 			AbsStart:  FormatTime(p.GameStartTime),
 			AbsFinish: FormatTime(p.GameFinishTime),
+
+			Items: p.itemNames(),
+
+			Warning: p.CompatibilityWarning,
 		})
 	}
 
@@ -250,6 +378,10 @@ func (g *Game) CommandFor(command string) (interfaces.Command, error) {
 		return &sendCustomAsmCmd{g}, nil
 	case "fixSmallKeys":
 		return &fixSmallKeysCmd{g}, nil
+	case "sendChat":
+		return &sendChatCmd{g}, nil
+	case "ping":
+		return &pingCmd{g}, nil
 	default:
 		return nil, fmt.Errorf("no handler for command=%s", command)
 	}
@@ -298,6 +430,8 @@ type setFieldArgs struct {
 	SyncOverworld    *bool `json:"syncOverworld"`
 	SyncChests       *bool `json:"syncChests"`
 	SyncTunicColor   *bool `json:"syncTunicColor"`
+	ReliableDelivery *bool `json:"reliableDelivery"`
+	CaptureNetwork   *bool `json:"captureNetwork"`
 }
 
 func (c *setFieldCmd) CreateArgs() interfaces.CommandArgs { return &setFieldArgs{} }
@@ -346,6 +480,19 @@ func (c *setFieldCmd) Execute(args interfaces.CommandArgs) error {
 		g.SyncTunicColor = *f.SyncTunicColor
 		g.clean = false
 	}
+	if f.ReliableDelivery != nil {
+		g.ReliableDelivery = *f.ReliableDelivery
+		g.clean = false
+	}
+	if f.CaptureNetwork != nil {
+		g.CaptureNetwork = *f.CaptureNetwork
+		if g.CaptureNetwork {
+			g.startCapture()
+		} else {
+			g.stopCapture()
+		}
+		g.clean = false
+	}
 	if f.PlayerColor != nil {
 		g.local.PlayerColor = *f.PlayerColor
 		g.shouldUpdatePlayersList = true
@@ -386,3 +533,60 @@ func (c *sendCustomAsmCmd) Execute(args interfaces.CommandArgs) error {
 
 	return nil
 }
+
+type sendChatCmd struct{ g *Game }
+type sendChatArgs struct {
+	Text string `json:"text"`
+}
+
+func (c *sendChatCmd) CreateArgs() interfaces.CommandArgs { return &sendChatArgs{} }
+
+func (c *sendChatCmd) Execute(args interfaces.CommandArgs) error {
+	f, ok := args.(*sendChatArgs)
+	if !ok {
+		return fmt.Errorf("invalid args type for command")
+	}
+
+	return c.g.SendChat(f.Text, nil)
+}
+
+type pingCmd struct{ g *Game }
+type pingArgs struct {
+	Text string `json:"text"`
+	// Location, X and Y default to the local player's current position:
+	Location *uint32 `json:"location"`
+	X        *uint16 `json:"x"`
+	Y        *uint16 `json:"y"`
+}
+
+func (c *pingCmd) CreateArgs() interfaces.CommandArgs { return &pingArgs{} }
+
+func (c *pingCmd) Execute(args interfaces.CommandArgs) error {
+	f, ok := args.(*pingArgs)
+	if !ok {
+		return fmt.Errorf("invalid args type for command")
+	}
+
+	g := c.g
+	if g.spectator && (f.Location == nil || f.X == nil || f.Y == nil) {
+		return fmt.Errorf("spectators must ping an explicit location")
+	}
+
+	local := g.LocalPlayer()
+	ping := &protocol03.Ping{
+		Location: local.Location,
+		X:        uint32(local.X),
+		Y:        uint32(local.Y),
+	}
+	if f.Location != nil {
+		ping.Location = *f.Location
+	}
+	if f.X != nil {
+		ping.X = uint32(*f.X)
+	}
+	if f.Y != nil {
+		ping.Y = uint32(*f.Y)
+	}
+
+	return g.SendChat(f.Text, ping)
+}
@@ -18,6 +18,12 @@ type Factory interface {
 	NewGame(rom *snes.ROM) Game
 }
 
+// SpectatorFactory is implemented by factories that can create a Game which only observes a group's players
+// without a ROM or a SNES
+type SpectatorFactory interface {
+	NewSpectator() Game
+}
+
 type Patcher interface {
 	Patch() error
 }
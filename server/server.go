@@ -0,0 +1,472 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"log"
+	"net"
+	"o2/client"
+	"o2/client/protocol03"
+	"o2/util"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxPlayers is the number of player indexes handed out per group; matches alttp.MaxPlayers
+const MaxPlayers = 256
+
+// DefaultClientTimeout is how long a client may go without sending a message before it is removed from its group
+const DefaultClientTimeout = 30 * time.Second
+
+var ErrGroupFull = errors.New("group is full")
+var ErrUnsupportedProtocol = errors.New("unsupported protocol")
+
+// Outgoing is a packet to be sent to a client
+type Outgoing struct {
+	Addr net.Addr
+	Data []byte
+}
+
+type Client struct {
+	Addr  net.Addr
+	Index uint32
+	// ID is the stable player ID the client sends with its messages; nil if it does not send one
+	ID       []byte
+	Sector   uint64
+	LastSeen time.Time
+	// Capabilities is the client's self-description from its last JoinGroup; nil if it did not send one
+	Capabilities *protocol03.Capabilities
+
+	// latest snapshot broadcast by the client per snapshot key; replayed to clients that join later:
+	snapshots map[uint32]*protocol03.GroupMessage
+}
+
+// MaxSnapshots limits the number of snapshot keys kept per client
+const MaxSnapshots = 16
+
+// keepSnapshot keeps a copy of gm if it is a snapshot broadcast
+func (c *Client) keepSnapshot(gm *protocol03.GroupMessage) {
+	key := gm.GetBroadcastAll().GetSnapshot()
+	if key == 0 {
+		return
+	}
+	if _, ok := c.snapshots[key]; !ok && len(c.snapshots) >= MaxSnapshots {
+		return
+	}
+	if c.snapshots == nil {
+		c.snapshots = make(map[uint32]*protocol03.GroupMessage)
+	}
+
+	snapshot := proto.Clone(gm).(*protocol03.GroupMessage)
+	snapshot.Replay = true
+	c.snapshots[key] = snapshot
+}
+
+type Group struct {
+	Name string
+
+	clients [MaxPlayers]*Client
+	byAddr  map[string]*Client
+}
+
+// Clients returns the list of clients in the group ordered by player index
+func (gr *Group) Clients() []*Client {
+	clients := make([]*Client, 0, len(gr.byAddr))
+	for _, c := range gr.clients {
+		if c == nil {
+			continue
+		}
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// sameIDs reports whether both player IDs are known and equal
+func sameIDs(a, b []byte) bool {
+	return len(a) > 0 && bytes.Equal(a, b)
+}
+
+// join returns the client at addr or adds it to the group. A new client is given the requested player index if it
+// is free, otherwise the lowest free player index.
+//
+// A client that reconnects over UDP comes from a new address while its old address is still in the group until it
+// times out, so a new client takes over the index of an existing client with the same player ID and the existing
+// client is evicted. A requested index held by any other client, including one without a player ID, is never taken.
+func (gr *Group) join(addr net.Addr, requested int, id []byte, now time.Time) (c *Client, err error) {
+	key := addr.String()
+	if c = gr.byAddr[key]; c != nil {
+		return
+	}
+
+	index := -1
+	for _, o := range gr.clients {
+		if o != nil && sameIDs(id, o.ID) {
+			index = int(o.Index)
+			break
+		}
+	}
+	if index < 0 && requested >= 0 && requested < MaxPlayers && gr.clients[requested] == nil {
+		index = requested
+	}
+	if o := gr.clientAt(index); o != nil {
+		log.Printf("server: group '%s': player[%02x] %s rejoined from %s\n", gr.Name, o.Index, o.Addr, addr)
+		gr.leave(o)
+	}
+
+	if index < 0 {
+		// assign the lowest free player index:
+		for i := range gr.clients {
+			if gr.clients[i] == nil {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		err = ErrGroupFull
+		return
+	}
+
+	c = &Client{
+		Addr:     addr,
+		Index:    uint32(index),
+		ID:       append([]byte(nil), id...),
+		LastSeen: now,
+	}
+	gr.clients[index] = c
+	gr.byAddr[key] = c
+	return
+}
+
+// clientAt returns the client at the player index or nil if there is none
+func (gr *Group) clientAt(index int) *Client {
+	if index < 0 || index >= MaxPlayers {
+		return nil
+	}
+	return gr.clients[index]
+}
+
+func (gr *Group) leave(c *Client) {
+	gr.clients[c.Index] = nil
+	delete(gr.byAddr, c.Addr.String())
+}
+
+// Server relays protocol 03 GroupMessages between clients in the same group
+type Server struct {
+	// Now returns the current server time; defaults to time.Now
+	Now func() time.Time
+	// ClientTimeout defaults to DefaultClientTimeout
+	ClientTimeout time.Duration
+
+	groupsLock sync.Mutex
+	groups     map[string]*Group
+
+	// connections to send Outgoing packets on:
+	connsLock  sync.Mutex
+	packetConn net.PacketConn
+	streams    map[string]*streamConn
+}
+
+func NewServer() *Server {
+	return &Server{
+		Now:           time.Now,
+		ClientTimeout: DefaultClientTimeout,
+		groups:        make(map[string]*Group),
+		streams:       make(map[string]*streamConn),
+	}
+}
+
+// GroupKey normalizes a group name; group names are case-insensitive with leading and trailing whitespace trimmed
+func GroupKey(group string) string {
+	return strings.ToLower(strings.Trim(group, " \t\r\n\000"))
+}
+
+// Group returns the group by name if it exists
+func (s *Server) Group(name string) (gr *Group, ok bool) {
+	s.groupsLock.Lock()
+	defer s.groupsLock.Unlock()
+
+	gr, ok = s.groups[GroupKey(name)]
+	return
+}
+
+func (s *Server) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// HandlePacket handles a single packet received from addr and returns the packets to send in response
+func (s *Server) HandlePacket(addr net.Addr, b []byte) (out []Outgoing, err error) {
+	var protocol uint8
+	var r io.Reader
+	r, err = client.ParseHeader(b, &protocol)
+	if err != nil {
+		return
+	}
+	if protocol != 0x03 {
+		err = fmt.Errorf("%w %02x", ErrUnsupportedProtocol, protocol)
+		return
+	}
+
+	var pb []byte
+	pb, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	gm := &protocol03.GroupMessage{}
+	if err = proto.Unmarshal(pb, gm); err != nil {
+		return
+	}
+
+	return s.HandleMessage(addr, gm)
+}
+
+// HandleMessage handles a GroupMessage received from addr and returns the packets to send in response
+func (s *Server) HandleMessage(addr net.Addr, gm *protocol03.GroupMessage) (out []Outgoing, err error) {
+	now := s.now()
+
+	s.groupsLock.Lock()
+	defer s.groupsLock.Unlock()
+
+	key := GroupKey(gm.GetGroup())
+	gr, ok := s.groups[key]
+	if !ok {
+		gr = &Group{
+			Name:   key,
+			byAddr: make(map[string]*Client),
+		}
+		s.groups[key] = gr
+		log.Printf("server: group '%s' created\n", key)
+	}
+
+	requested := -1
+	if jg := gm.GetJoinGroup(); jg != nil && jg.RequestedPlayerIndex != nil {
+		requested = int(jg.GetRequestedPlayerIndex())
+	}
+
+	var c *Client
+	c, err = gr.join(addr, requested, gm.GetPlayerId(), now)
+	if err != nil {
+		err = fmt.Errorf("server: group '%s': %w", key, err)
+		return
+	}
+	c.LastSeen = now
+	if protocol03.ReportsSector(gm) {
+		c.Sector = gm.GetPlayerInSector()
+	}
+	if caps := gm.GetJoinGroup().GetCapabilities(); caps != nil {
+		c.Capabilities = caps
+	}
+
+	// stamp the message with the sender's index and server time:
+	gm.PlayerIndex = c.Index
+	gm.ServerTime = now.UnixNano()
+	// only the server replays snapshots:
+	gm.Replay = false
+
+	var b []byte
+	b, err = marshal(gm)
+	if err != nil {
+		return
+	}
+
+	if gm.GetJoinGroup() != nil {
+		// reply only to the sender:
+		out = append(out, Outgoing{Addr: c.Addr, Data: b})
+		// catch the sender up on the rest of the group's state:
+		out, err = gr.replaySnapshots(out, c, now)
+	} else if gm.GetEcho() != nil {
+		// reply only to the sender:
+		out = append(out, Outgoing{Addr: c.Addr, Data: b})
+	} else if gm.GetBroadcastAll() != nil || gm.GetChat() != nil {
+		c.keepSnapshot(gm)
+		for _, o := range gr.clients {
+			if o == nil || o == c {
+				continue
+			}
+			out = append(out, Outgoing{Addr: o.Addr, Data: b})
+		}
+	} else if bs := gm.GetBroadcastSector(); bs != nil {
+		for _, o := range gr.clients {
+			if o == nil || o == c {
+				continue
+			}
+			if o.Sector != bs.GetTargetSector() {
+				continue
+			}
+			out = append(out, Outgoing{Addr: o.Addr, Data: b})
+		}
+	} else if ack := gm.GetAck(); ack != nil {
+		// acks are only of interest to the player that sent the reliable broadcast:
+		if t := ack.GetTargetPlayerIndex(); t < MaxPlayers {
+			if o := gr.clients[t]; o != nil && o != c {
+				out = append(out, Outgoing{Addr: o.Addr, Data: b})
+			}
+		}
+	}
+
+	return
+}
+
+// replaySnapshots appends the latest snapshots of every other client in the group for delivery to c
+func (gr *Group) replaySnapshots(out []Outgoing, c *Client, now time.Time) ([]Outgoing, error) {
+	for _, o := range gr.clients {
+		if o == nil || o == c || len(o.snapshots) == 0 {
+			continue
+		}
+
+		keys := make([]uint32, 0, len(o.snapshots))
+		for key := range o.snapshots {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		for _, key := range keys {
+			snapshot := o.snapshots[key]
+			snapshot.ServerTime = now.UnixNano()
+			b, err := marshal(snapshot)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, Outgoing{Addr: c.Addr, Data: b})
+		}
+	}
+	return out, nil
+}
+
+func marshal(gm *protocol03.GroupMessage) (b []byte, err error) {
+	pkt := client.MakePacket(0x03)
+	b, err = proto.MarshalOptions{}.MarshalAppend(pkt.Bytes(), gm)
+	return
+}
+
+// Leave removes the client at addr from every group it has joined
+func (s *Server) Leave(addr net.Addr) {
+	s.groupsLock.Lock()
+	defer s.groupsLock.Unlock()
+
+	for key, gr := range s.groups {
+		c, ok := gr.byAddr[addr.String()]
+		if !ok {
+			continue
+		}
+
+		gr.leave(c)
+		if len(gr.byAddr) == 0 {
+			delete(s.groups, key)
+		}
+	}
+}
+
+// ExpireClients removes clients that have not sent a message within ClientTimeout and removes empty groups
+func (s *Server) ExpireClients() {
+	now := s.now()
+	timeout := s.ClientTimeout
+	if timeout <= 0 {
+		timeout = DefaultClientTimeout
+	}
+
+	s.groupsLock.Lock()
+	defer s.groupsLock.Unlock()
+
+	for key, gr := range s.groups {
+		for _, c := range gr.Clients() {
+			if now.Sub(c.LastSeen) < timeout {
+				continue
+			}
+
+			log.Printf("server: group '%s': player[%02x] %s timed out\n", key, c.Index, c.Addr)
+			gr.leave(c)
+		}
+
+		if len(gr.byAddr) == 0 {
+			delete(s.groups, key)
+			log.Printf("server: group '%s' removed\n", key)
+		}
+	}
+}
+
+// send writes an Outgoing packet to whichever connection its client is connected by
+func (s *Server) send(o Outgoing) {
+	s.connsLock.Lock()
+	sc := s.streams[o.Addr.String()]
+	pc := s.packetConn
+	s.connsLock.Unlock()
+
+	var err error
+	if sc != nil {
+		err = sc.writePacket(o.Data)
+	} else if pc != nil {
+		_, err = pc.WriteTo(o.Data, o.Addr)
+	}
+	if err != nil {
+		log.Printf("server: %s: write: %v\n", o.Addr, err)
+	}
+}
+
+// expireLoop periodically expires idle clients until done is closed; must run in a goroutine
+func (s *Server) expireLoop(done <-chan struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+	}()
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.ExpireClients()
+		case <-done:
+			return
+		}
+	}
+}
+
+// Serve reads packets from conn and relays them until conn is closed
+func (s *Server) Serve(conn net.PacketConn) (err error) {
+	log.Printf("server: listening on udp %s\n", conn.LocalAddr())
+
+	s.connsLock.Lock()
+	s.packetConn = conn
+	s.connsLock.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go s.expireLoop(done)
+
+	// we only need a single receive buffer:
+	b := make([]byte, 65536)
+	for {
+		var n int
+		var addr net.Addr
+		n, addr, err = conn.ReadFrom(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				err = nil
+			}
+			return
+		}
+
+		var out []Outgoing
+		out, err = s.HandlePacket(addr, b[:n])
+		if err != nil {
+			log.Printf("server: %s: %v\n", addr, err)
+			continue
+		}
+
+		for _, o := range out {
+			s.send(o)
+		}
+	}
+}
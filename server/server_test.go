@@ -0,0 +1,631 @@
+package server
+
+import (
+	"bytes"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"o2/client"
+	"o2/client/protocol03"
+	"o2/streamclient"
+	"o2/udpclient"
+	"testing"
+	"time"
+)
+
+func testAddr(port int) net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
+func testPacket(t testing.TB, gm *protocol03.GroupMessage) []byte {
+	b, err := marshal(gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testUnmarshal(t testing.TB, b []byte) *protocol03.GroupMessage {
+	var protocol uint8
+	r, err := client.ParseHeader(b, &protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protocol != 0x03 {
+		t.Fatalf("protocol = %02x, want 03", protocol)
+	}
+	pb, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gm := &protocol03.GroupMessage{}
+	if err = proto.Unmarshal(pb, gm); err != nil {
+		t.Fatal(err)
+	}
+	return gm
+}
+
+func newTestServer() (s *Server, now *time.Time) {
+	s = NewServer()
+	now = new(time.Time)
+	*now = time.Unix(1_600_000_000, 0)
+	s.Now = func() time.Time { return *now }
+	return
+}
+
+func join(t testing.TB, s *Server, addr net.Addr, group string) uint32 {
+	out, err := s.HandlePacket(addr, testPacket(t, &protocol03.GroupMessage{
+		Group:     group,
+		JoinGroup: &protocol03.JoinGroup{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("join: len(out) = %d, want 1", len(out))
+	}
+	if out[0].Addr.String() != addr.String() {
+		t.Fatalf("join: reply addr = %v, want %v", out[0].Addr, addr)
+	}
+	return testUnmarshal(t, out[0].Data).GetPlayerIndex()
+}
+
+func TestServer_JoinAssignsIndexPerGroup(t *testing.T) {
+	s, _ := newTestServer()
+
+	if i := join(t, s, testAddr(1), "group               "); i != 0 {
+		t.Errorf("index = %d, want 0", i)
+	}
+	if i := join(t, s, testAddr(2), "GROUP"); i != 1 {
+		t.Errorf("index = %d, want 1", i)
+	}
+	// rejoining keeps the same index:
+	if i := join(t, s, testAddr(1), "group"); i != 0 {
+		t.Errorf("index = %d, want 0", i)
+	}
+	// a different group starts counting from 0:
+	if i := join(t, s, testAddr(3), "other"); i != 0 {
+		t.Errorf("index = %d, want 0", i)
+	}
+}
+
+func TestServer_JoinRequestedIndex(t *testing.T) {
+	s, _ := newTestServer()
+
+	joinRequesting := func(addr net.Addr, requested uint32) uint32 {
+		out, err := s.HandlePacket(addr, testPacket(t, &protocol03.GroupMessage{
+			Group:     "group",
+			JoinGroup: &protocol03.JoinGroup{RequestedPlayerIndex: &requested},
+			PlayerId:  []byte(addr.String()),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return testUnmarshal(t, out[0].Data).GetPlayerIndex()
+	}
+
+	if i := joinRequesting(testAddr(1), 5); i != 5 {
+		t.Errorf("index = %d, want requested 5", i)
+	}
+	// an index taken by a different player falls back to the lowest free index:
+	if i := joinRequesting(testAddr(2), 5); i != 0 {
+		t.Errorf("index = %d, want 0", i)
+	}
+	// an existing client keeps its index:
+	if i := joinRequesting(testAddr(1), 7); i != 5 {
+		t.Errorf("index = %d, want 5", i)
+	}
+	if i := joinRequesting(testAddr(3), MaxPlayers); i != 1 {
+		t.Errorf("index = %d, want 1", i)
+	}
+}
+
+func TestServer_RejoinFromNewAddr(t *testing.T) {
+	s, _ := newTestServer()
+
+	joinAs := func(addr net.Addr, id []byte, requested *uint32) uint32 {
+		out, err := s.HandlePacket(addr, testPacket(t, &protocol03.GroupMessage{
+			Group:     "group",
+			JoinGroup: &protocol03.JoinGroup{RequestedPlayerIndex: requested},
+			PlayerId:  id,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return testUnmarshal(t, out[0].Data).GetPlayerIndex()
+	}
+
+	join(t, s, testAddr(1), "group")
+	if i := joinAs(testAddr(2), []byte("player-2"), nil); i != 1 {
+		t.Fatalf("index = %d, want 1", i)
+	}
+	if i := joinAs(testAddr(3), nil, nil); i != 2 {
+		t.Fatalf("index = %d, want 2", i)
+	}
+
+	// the same player ID from a new port takes over the index without requesting it:
+	if i := joinAs(testAddr(12), []byte("player-2"), nil); i != 1 {
+		t.Errorf("index = %d, want 1 for the same player ID", i)
+	}
+	// a requested index held by a client without a player ID is not taken over:
+	requested := uint32(2)
+	if i := joinAs(testAddr(13), nil, &requested); i != 3 {
+		t.Errorf("index = %d, want lowest free 3", i)
+	}
+	// nor is one held by a different player ID:
+	requested = 1
+	if i := joinAs(testAddr(14), []byte("player-4"), &requested); i != 4 {
+		t.Errorf("index = %d, want lowest free 4", i)
+	}
+
+	gr, _ := s.Group("group")
+	clients := gr.Clients()
+	if len(clients) != 5 {
+		t.Fatalf("len(clients) = %d, want 5 with the stale client evicted", len(clients))
+	}
+	for _, c := range clients {
+		if c.Addr.String() == testAddr(2).String() {
+			t.Errorf("stale client %s still in group", c.Addr)
+		}
+	}
+
+	// broadcasts reach every client but the stale one:
+	out, err := s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:        "group",
+		BroadcastAll: &protocol03.BroadcastAll{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []net.Addr{testAddr(12), testAddr(3), testAddr(13), testAddr(14)}
+	if len(out) != len(want) {
+		t.Fatalf("broadcast sent to %v, want %v", out, want)
+	}
+	for i := range want {
+		if out[i].Addr.String() != want[i].String() {
+			t.Errorf("broadcast %d sent to %v, want %v", i, out[i].Addr, want[i])
+		}
+	}
+}
+
+func TestServer_JoinCapabilities(t *testing.T) {
+	s, _ := newTestServer()
+
+	caps := &protocol03.Capabilities{ClientVersion: "v1.2.3", GameName: "ALTTP", SerializationVersion: 0x15}
+	if _, err := s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:     "group",
+		JoinGroup: &protocol03.JoinGroup{Capabilities: caps},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// a client that does not announce capabilities:
+	join(t, s, testAddr(2), "group")
+
+	gr, ok := s.Group("group")
+	if !ok {
+		t.Fatal("group should exist")
+	}
+	clients := gr.Clients()
+	if len(clients) != 2 {
+		t.Fatalf("len(clients) = %d, want 2", len(clients))
+	}
+	if !proto.Equal(clients[0].Capabilities, caps) {
+		t.Errorf("capabilities = %v, want %v", clients[0].Capabilities, caps)
+	}
+	if clients[1].Capabilities != nil {
+		t.Errorf("capabilities = %v, want nil", clients[1].Capabilities)
+	}
+}
+
+func TestServer_Echo(t *testing.T) {
+	s, now := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+
+	out, err := s.HandlePacket(testAddr(2), testPacket(t, &protocol03.GroupMessage{
+		Group:      "group",
+		PlayerTime: 1234,
+		Echo:       &protocol03.Echo{Data: []byte{1, 2, 3}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Addr.String() != testAddr(2).String() {
+		t.Fatalf("echo must reply only to sender; got %v", out)
+	}
+
+	gm := testUnmarshal(t, out[0].Data)
+	if gm.GetServerTime() != now.UnixNano() {
+		t.Errorf("serverTime = %d, want %d", gm.GetServerTime(), now.UnixNano())
+	}
+	if gm.GetPlayerTime() != 1234 {
+		t.Errorf("playerTime = %d, want 1234", gm.GetPlayerTime())
+	}
+	if gm.GetPlayerIndex() != 1 {
+		t.Errorf("playerIndex = %d, want 1", gm.GetPlayerIndex())
+	}
+	if !bytes.Equal(gm.GetEcho().GetData(), []byte{1, 2, 3}) {
+		t.Errorf("echo data = %v", gm.GetEcho().GetData())
+	}
+}
+
+func TestServer_Broadcast(t *testing.T) {
+	s, _ := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+	join(t, s, testAddr(3), "group")
+	join(t, s, testAddr(4), "other")
+
+	// move player 3 into sector 7:
+	if _, err := s.HandlePacket(testAddr(3), testPacket(t, &protocol03.GroupMessage{
+		Group:          "group",
+		PlayerInSector: 7,
+		BroadcastAll:   &protocol03.BroadcastAll{},
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:        "group",
+		BroadcastAll: &protocol03.BroadcastAll{Data: []byte{0x14}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("broadcastAll: len(out) = %d, want 2", len(out))
+	}
+	for _, o := range out {
+		if o.Addr.String() == testAddr(1).String() || o.Addr.String() == testAddr(4).String() {
+			t.Errorf("broadcastAll: unexpected recipient %v", o.Addr)
+		}
+		if gm := testUnmarshal(t, o.Data); gm.GetPlayerIndex() != 0 {
+			t.Errorf("broadcastAll: playerIndex = %d, want 0", gm.GetPlayerIndex())
+		}
+	}
+
+	out, err = s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:           "group",
+		BroadcastSector: &protocol03.BroadcastSector{TargetSector: 7, Data: []byte{0x14}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Addr.String() != testAddr(3).String() {
+		t.Fatalf("broadcastSector: want only %v; got %v", testAddr(3), out)
+	}
+}
+
+func TestServer_SectorOnlyFromLocation(t *testing.T) {
+	s, _ := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+
+	send := func(gm *protocol03.GroupMessage) {
+		t.Helper()
+		gm.Group = "group"
+		if _, err := s.HandlePacket(testAddr(2), testPacket(t, gm)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// move player 2 into sector 7:
+	send(&protocol03.GroupMessage{PlayerInSector: 7, BroadcastAll: &protocol03.BroadcastAll{}})
+
+	// messages that do not carry the sender's current location must not move it:
+	send(&protocol03.GroupMessage{PlayerInSector: 8, Echo: &protocol03.Echo{}})
+	send(&protocol03.GroupMessage{PlayerInSector: 8, Chat: &protocol03.Chat{Text: "hi"}})
+	send(&protocol03.GroupMessage{PlayerInSector: 8, Ack: &protocol03.Ack{}})
+	send(&protocol03.GroupMessage{
+		PlayerInSector: 8,
+		BroadcastAll:   &protocol03.BroadcastAll{},
+		Reliable:       &protocol03.Reliable{Channel: 1, Sequence: 1},
+	})
+	send(&protocol03.GroupMessage{PlayerInSector: 8, BroadcastAll: &protocol03.BroadcastAll{}, Replay: true})
+
+	out, err := s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:           "group",
+		BroadcastSector: &protocol03.BroadcastSector{TargetSector: 7, Data: []byte{0x14}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Addr.String() != testAddr(2).String() {
+		t.Fatalf("broadcastSector: want only %v; got %v", testAddr(2), out)
+	}
+}
+
+func TestServer_Chat(t *testing.T) {
+	s, _ := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+	join(t, s, testAddr(3), "group")
+	join(t, s, testAddr(4), "other")
+
+	// chat goes to everyone else in the group regardless of sector:
+	out, err := s.HandlePacket(testAddr(2), testPacket(t, &protocol03.GroupMessage{
+		Group:          "group",
+		PlayerInSector: 7,
+		Chat: &protocol03.Chat{
+			Text: "over here",
+			Ping: &protocol03.Ping{Location: 0x1012, X: 100, Y: 200},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("chat: len(out) = %d, want 2", len(out))
+	}
+	for _, o := range out {
+		if o.Addr.String() == testAddr(2).String() || o.Addr.String() == testAddr(4).String() {
+			t.Errorf("chat: unexpected recipient %v", o.Addr)
+		}
+		gm := testUnmarshal(t, o.Data)
+		if gm.GetPlayerIndex() != 1 {
+			t.Errorf("chat: playerIndex = %d, want 1", gm.GetPlayerIndex())
+		}
+		if gm.GetChat().GetText() != "over here" || gm.GetChat().GetPing().GetX() != 100 {
+			t.Errorf("chat: unexpected message %v", gm.GetChat())
+		}
+	}
+}
+
+func TestServer_Snapshots(t *testing.T) {
+	s, now := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+
+	broadcast := func(addr net.Addr, snapshot uint32, data byte) {
+		t.Helper()
+		if _, err := s.HandlePacket(addr, testPacket(t, &protocol03.GroupMessage{
+			Group:        "group",
+			BroadcastAll: &protocol03.BroadcastAll{Data: []byte{data}, Snapshot: snapshot},
+			Replay:       true,
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	broadcast(testAddr(1), 2, 0x01)
+	broadcast(testAddr(1), 1, 0x02)
+	broadcast(testAddr(1), 2, 0x03)
+	// not a snapshot:
+	broadcast(testAddr(1), 0, 0x04)
+	broadcast(testAddr(2), 1, 0x05)
+
+	*now = now.Add(time.Second)
+	out, err := s.HandlePacket(testAddr(3), testPacket(t, &protocol03.GroupMessage{
+		Group:     "group",
+		JoinGroup: &protocol03.JoinGroup{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the join reply followed by the latest snapshots ordered by player and key:
+	expected := []struct {
+		playerIndex uint32
+		data        byte
+	}{{0, 0x02}, {0, 0x03}, {1, 0x05}}
+	if len(out) != 1+len(expected) {
+		t.Fatalf("join: len(out) = %d, want %d", len(out), 1+len(expected))
+	}
+	if gm := testUnmarshal(t, out[0].Data); gm.GetJoinGroup() == nil || gm.GetReplay() {
+		t.Errorf("join: expected the join reply first; got %v", gm)
+	}
+	for i, e := range expected {
+		o := out[1+i]
+		if o.Addr.String() != testAddr(3).String() {
+			t.Errorf("snapshot %d: addr = %v, want %v", i, o.Addr, testAddr(3))
+		}
+		gm := testUnmarshal(t, o.Data)
+		if !gm.GetReplay() {
+			t.Errorf("snapshot %d: expected replay to be set", i)
+		}
+		if gm.GetPlayerIndex() != e.playerIndex || !bytes.Equal(gm.GetBroadcastAll().GetData(), []byte{e.data}) {
+			t.Errorf("snapshot %d: got player %d data %x, want player %d data %02x", i, gm.GetPlayerIndex(), gm.GetBroadcastAll().GetData(), e.playerIndex, e.data)
+		}
+		if gm.GetServerTime() != now.UnixNano() {
+			t.Errorf("snapshot %d: expected server time to be restamped", i)
+		}
+	}
+
+	// broadcasts are never relayed with replay set:
+	out, err = s.HandlePacket(testAddr(1), testPacket(t, &protocol03.GroupMessage{
+		Group:        "group",
+		BroadcastAll: &protocol03.BroadcastAll{Snapshot: 1},
+		Replay:       true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range out {
+		if testUnmarshal(t, o.Data).GetReplay() {
+			t.Errorf("broadcastAll: unexpected replay to %v", o.Addr)
+		}
+	}
+
+	// a departed client's snapshots are forgotten:
+	s.Leave(testAddr(1))
+	out, err = s.HandlePacket(testAddr(4), testPacket(t, &protocol03.GroupMessage{
+		Group:     "group",
+		JoinGroup: &protocol03.JoinGroup{},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1+1 {
+		t.Errorf("join: len(out) = %d, want %d", len(out), 1+1)
+	}
+}
+
+func TestServer_Ack(t *testing.T) {
+	s, _ := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+	join(t, s, testAddr(3), "group")
+
+	out, err := s.HandlePacket(testAddr(3), testPacket(t, &protocol03.GroupMessage{
+		Group: "group",
+		Ack:   &protocol03.Ack{TargetPlayerIndex: 1, Channel: 6, Sequence: 10},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Addr.String() != testAddr(2).String() {
+		t.Fatalf("ack: want only %v; got %v", testAddr(2), out)
+	}
+	if gm := testUnmarshal(t, out[0].Data); gm.GetPlayerIndex() != 2 || gm.GetAck().GetSequence() != 10 {
+		t.Errorf("ack: unexpected message %v", gm)
+	}
+
+	// acks to unknown players go nowhere:
+	out, err = s.HandlePacket(testAddr(3), testPacket(t, &protocol03.GroupMessage{
+		Group: "group",
+		Ack:   &protocol03.Ack{TargetPlayerIndex: 200},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("ack: expected no recipients; got %v", out)
+	}
+}
+
+func TestServer_ExpireClients(t *testing.T) {
+	s, now := newTestServer()
+	join(t, s, testAddr(1), "group")
+	join(t, s, testAddr(2), "group")
+
+	*now = now.Add(s.ClientTimeout / 2)
+	join(t, s, testAddr(2), "group")
+
+	*now = now.Add(s.ClientTimeout / 2)
+	s.ExpireClients()
+
+	gr, ok := s.Group("group")
+	if !ok {
+		t.Fatal("group removed but should still exist")
+	}
+	if clients := gr.Clients(); len(clients) != 1 || clients[0].Index != 1 {
+		t.Fatalf("expected only player[01] to remain; got %v", clients)
+	}
+
+	// the freed index is handed out again:
+	if i := join(t, s, testAddr(3), "group"); i != 0 {
+		t.Errorf("index = %d, want 0", i)
+	}
+
+	*now = now.Add(s.ClientTimeout)
+	s.ExpireClients()
+	if _, ok = s.Group("group"); ok {
+		t.Fatal("empty group should be removed")
+	}
+}
+
+func TestServer_ServeUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	s := NewServer()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(conn) }()
+	defer func() {
+		_ = conn.Close()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	c := udpclient.NewUDPClient("test")
+	c.MuteLog(true)
+	if err = c.Connect(conn.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	rsp, err := c.WriteThenReadTimeout(testPacket(t, &protocol03.GroupMessage{
+		Group:     "group",
+		JoinGroup: &protocol03.JoinGroup{},
+	}), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gm := testUnmarshal(t, rsp)
+	if gm.GetJoinGroup() == nil {
+		t.Fatal("expected joinGroup reply")
+	}
+	if gm.GetServerTime() == 0 {
+		t.Error("expected serverTime to be stamped")
+	}
+}
+
+func TestServer_ServeStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	s := NewServer()
+	served := make(chan error, 1)
+	go func() { served <- s.ServeStream(ln) }()
+	defer func() {
+		_ = ln.Close()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	readTimeout := func(c *streamclient.StreamClient) *protocol03.GroupMessage {
+		select {
+		case b := <-c.Read():
+			if b == nil {
+				t.Fatal("unexpected disconnect")
+			}
+			return testUnmarshal(t, b)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for packet")
+		}
+		return nil
+	}
+
+	var clients []*streamclient.StreamClient
+	for i, network := range []string{"tcp", "ws"} {
+		c := streamclient.NewStreamClient(network)
+		c.MuteLog(true)
+		if err = c.Connect(network, ln.Addr().String()); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+		clients = append(clients, c)
+
+		c.Write() <- testPacket(t, &protocol03.GroupMessage{
+			Group:     "group",
+			JoinGroup: &protocol03.JoinGroup{},
+		})
+		if gm := readTimeout(c); gm.GetJoinGroup() == nil || gm.GetPlayerIndex() != uint32(i) {
+			t.Fatalf("%s: unexpected joinGroup reply %v", network, gm)
+		}
+	}
+
+	// tcp -> ws:
+	clients[0].Write() <- testPacket(t, &protocol03.GroupMessage{
+		Group:        "group",
+		BroadcastAll: &protocol03.BroadcastAll{Data: []byte{0x14}},
+	})
+	if gm := readTimeout(clients[1]); gm.GetBroadcastAll() == nil || gm.GetPlayerIndex() != 0 {
+		t.Fatalf("ws: unexpected message %v", gm)
+	}
+
+	// ws -> tcp:
+	clients[1].Write() <- testPacket(t, &protocol03.GroupMessage{
+		Group:        "group",
+		BroadcastAll: &protocol03.BroadcastAll{Data: []byte{0x14}},
+	})
+	if gm := readTimeout(clients[0]); gm.GetBroadcastAll() == nil || gm.GetPlayerIndex() != 1 {
+		t.Fatalf("tcp: unexpected message %v", gm)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"log"
+	"net"
+	"o2/streamclient"
+	"o2/util"
+	"sync"
+)
+
+// streamAddr distinguishes stream clients from UDP clients that happen to share the same ip:port
+type streamAddr struct {
+	net.Addr
+	scheme string
+}
+
+func (a streamAddr) String() string { return a.scheme + "://" + a.Addr.String() }
+
+type streamConn struct {
+	conn net.Conn
+	isWS bool
+
+	writeLock sync.Mutex
+}
+
+func (sc *streamConn) writePacket(b []byte) error {
+	sc.writeLock.Lock()
+	defer sc.writeLock.Unlock()
+
+	if sc.isWS {
+		return wsutil.WriteServerBinary(sc.conn, b)
+	}
+	return streamclient.WriteFrame(sc.conn, b)
+}
+
+// lockedReadWriter serializes control frame replies written by wsutil with packets written by other clients
+type lockedReadWriter struct {
+	io.Reader
+	sc *streamConn
+}
+
+func (rw lockedReadWriter) Write(p []byte) (int, error) {
+	rw.sc.writeLock.Lock()
+	defer rw.sc.writeLock.Unlock()
+	return rw.sc.conn.Write(p)
+}
+
+// ServeStream accepts stream connections from ln and relays their packets until ln is closed. A connection that
+// opens with an HTTP GET request is upgraded to a WebSocket carrying one packet per binary message; any other
+// connection carries packets prefixed by their uint16 little-endian length.
+func (s *Server) ServeStream(ln net.Listener) (err error) {
+	log.Printf("server: listening on tcp %s\n", ln.Addr())
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go s.expireLoop(done)
+
+	for {
+		var conn net.Conn
+		conn, err = ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				err = nil
+			}
+			return
+		}
+
+		go s.serveStreamConn(conn)
+	}
+}
+
+// must run in a goroutine
+func (s *Server) serveStreamConn(conn net.Conn) {
+	addr := streamAddr{Addr: conn.RemoteAddr(), scheme: "tcp"}
+
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+
+		s.connsLock.Lock()
+		delete(s.streams, addr.String())
+		s.connsLock.Unlock()
+
+		s.Leave(addr)
+		_ = conn.Close()
+	}()
+
+	sc := &streamConn{conn: conn}
+	br := bufio.NewReader(conn)
+	rw := lockedReadWriter{Reader: br, sc: sc}
+
+	// sniff for a WebSocket upgrade request:
+	if peek, err := br.Peek(4); err == nil && bytes.Equal(peek, []byte("GET ")) {
+		if _, err = ws.Upgrade(rw); err != nil {
+			log.Printf("server: %s: websocket upgrade: %v\n", addr, err)
+			return
+		}
+		sc.isWS = true
+		addr.scheme = "ws"
+	}
+
+	s.connsLock.Lock()
+	s.streams[addr.String()] = sc
+	s.connsLock.Unlock()
+
+	for {
+		var b []byte
+		var err error
+		if sc.isWS {
+			var op ws.OpCode
+			b, op, err = wsutil.ReadClientData(rw)
+			if err == nil && op != ws.OpBinary {
+				continue
+			}
+		} else {
+			b, err = streamclient.ReadFrame(br)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("server: %s: read: %v\n", addr, err)
+			}
+			return
+		}
+
+		out, err := s.HandlePacket(addr, b)
+		if err != nil {
+			log.Printf("server: %s: %v\n", addr, err)
+			continue
+		}
+
+		for _, o := range out {
+			s.send(o)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package snes
 import (
 	"log"
 	"o2/util"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,10 +15,13 @@ type BaseQueue struct {
 
 	// command execution queue:
 	cq       chan CommandWithCompletion
-	cqClosed bool
+	cqClosed atomic.Bool
 
 	// derived Queue struct:
 	queue Queue
+
+	// limits of the driver's read commands for planning reads:
+	readLimits ReadLimits
 }
 
 func (b *BaseQueue) BaseInit(name string, queue Queue) {
@@ -32,6 +36,25 @@ func (b *BaseQueue) BaseInit(name string, queue Queue) {
 	go b.handleQueue()
 }
 
+// SetReadLimits sets the limits of the driver's read commands that MakePlannedReadCommands plans reads for
+func (b *BaseQueue) SetReadLimits(limits ReadLimits) {
+	b.readLimits = limits
+}
+
+// MakePlannedReadCommands plans the reads with PlanReads and creates a command for each batch with newCommand
+func (b *BaseQueue) MakePlannedReadCommands(reqs []Read, batchComplete Completion, newCommand func(batch []Read) Command) CommandSequence {
+	batches := PlanReads(reqs, b.readLimits)
+
+	seq := make(CommandSequence, 0, len(batches))
+	for _, batch := range batches {
+		seq = append(seq, CommandWithCompletion{
+			Command:    newCommand(batch),
+			Completion: batchComplete,
+		})
+	}
+	return seq
+}
+
 func (b *BaseQueue) Enqueue(cmd CommandWithCompletion) (err error) {
 	// FIXME: no great way I can figure out how to avoid panic on closed channel send below.
 	defer func() {
@@ -41,7 +64,7 @@ func (b *BaseQueue) Enqueue(cmd CommandWithCompletion) (err error) {
 		}
 	}()
 
-	if b.cqClosed {
+	if b.cqClosed.Load() {
 		err = &TerminalError{ErrDeviceDisconnected}
 		return
 	}
@@ -61,10 +84,9 @@ func (b *BaseQueue) handleQueue() {
 
 	q := b.queue
 
-	b.cqClosed = false
 	var err error
 	doClose := func() {
-		if b.cqClosed {
+		if b.cqClosed.Load() {
 			log.Printf("%s: already closed\n", b.name)
 			return
 		}
@@ -82,7 +104,7 @@ func (b *BaseQueue) handleQueue() {
 		}
 
 		log.Printf("%s: closing chan\n", b.name)
-		b.cqClosed = true
+		b.cqClosed.Store(true)
 		close(b.cq)
 		log.Printf("%s: closed chan\n", b.name)
 	}
@@ -0,0 +1,19 @@
+package emulated
+
+import "o2/snes"
+
+type DeviceDescriptor struct {
+	snes.DeviceDescriptorBase
+}
+
+func (d *DeviceDescriptor) Base() *snes.DeviceDescriptorBase {
+	return &d.DeviceDescriptorBase
+}
+
+func (d *DeviceDescriptor) GetId() string {
+	return "emulated"
+}
+
+func (d *DeviceDescriptor) GetDisplayName() string {
+	return "Emulated SNES"
+}
@@ -0,0 +1,49 @@
+package emulated
+
+import (
+	"log"
+	"o2/snes"
+	"o2/util"
+	"o2/util/env"
+)
+
+const driverName = "emulated"
+
+type Driver struct{}
+
+func (d *Driver) DisplayOrder() int {
+	return 1001
+}
+
+func (d *Driver) DisplayName() string {
+	return "Emulated SNES"
+}
+
+func (d *Driver) DisplayDescription() string {
+	return "Run the patched ROM headless in a CPU-only SNES emulator for testing"
+}
+
+func (d *Driver) Open(desc snes.DeviceDescriptor) (snes.Queue, error) {
+	q, err := NewQueue(FrameDuration)
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (d *Driver) Detect() ([]snes.DeviceDescriptor, error) {
+	return []snes.DeviceDescriptor{
+		&DeviceDescriptor{},
+	}, nil
+}
+
+func (d *Driver) Empty() snes.DeviceDescriptor {
+	return &DeviceDescriptor{}
+}
+
+func init() {
+	if util.IsTruthy(env.GetOrDefault("O2_EMULATED_ENABLE", "0")) {
+		log.Printf("enabling emulated snes driver\n")
+		snes.Register(driverName, &Driver{})
+	}
+}
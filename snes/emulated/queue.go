@@ -0,0 +1,270 @@
+package emulated
+
+import (
+	"fmt"
+	"github.com/alttpo/snes/emulator"
+	"github.com/alttpo/snes/emulator/cpu65c816"
+	"log"
+	"o2/snes"
+	"sync"
+	"time"
+)
+
+// FrameDuration is the time between frames of an NTSC SNES:
+// 5,369,317.5/89,341.5 ~= 60.0988 frames / sec ~= 16,639,265.605 ns / frame
+const FrameDuration = 16_639_265 * time.Nanosecond
+
+const (
+	// DefaultBootPC is where booting stops; ALTTP's main game loop which is reached after NMI is enabled at $00:802F
+	DefaultBootPC = 0x00_8034
+	// DefaultFrameStartPC is where each frame starts; ALTTP's main game loop after waiting for NMI, which increments
+	// the frame counter at $1A before its `JSL Module_MainRouting` at $00:8056 that the O2 patch hooks
+	DefaultFrameStartPC = 0x00_8051
+	// DefaultFrameEndPC is where each frame ends; right after the `JSL` at $00:8056 returns
+	DefaultFrameEndPC = 0x00_805A
+	// DefaultMaxCycles limits the CPU cycles of a boot or frame that never reaches its end, e.g. when waiting on
+	// hardware that is not emulated
+	DefaultMaxCycles = 0x10_0000
+)
+
+// Queue runs a ROM in a CPU-only SNES emulator and serves reads and writes against its live memory. There is no PPU,
+// APU or NMI; instead each frame runs the CPU from FrameStartPC until FrameEndPC.
+type Queue struct {
+	snes.BaseQueue
+
+	// BootPC, FrameStartPC, FrameEndPC and MaxCycles may be changed before a ROM is booted:
+	BootPC       uint32
+	FrameStartPC uint32
+	FrameEndPC   uint32
+	MaxCycles    uint64
+
+	lock   sync.Mutex
+	system *emulator.System
+	booted bool
+	// CPU state after booting; restored at the start of each frame:
+	bootCPU cpu65c816.CPU
+	frames  uint64
+	stuck   bool
+
+	// uploaded ROM contents by path:
+	roms map[string][]byte
+
+	closed      chan struct{}
+	closeOnce   sync.Once
+	frameTicker *time.Ticker
+}
+
+// NewQueue creates a Queue that steps a frame every frameDuration once a ROM is booted; if frameDuration is 0 then
+// frames only run when StepFrame is called
+func NewQueue(frameDuration time.Duration) (q *Queue, err error) {
+	q = &Queue{
+		BootPC:       DefaultBootPC,
+		FrameStartPC: DefaultFrameStartPC,
+		FrameEndPC:   DefaultFrameEndPC,
+		MaxCycles:    DefaultMaxCycles,
+		system:       &emulator.System{},
+		roms:         make(map[string][]byte),
+		closed:       make(chan struct{}),
+	}
+	if err = q.system.CreateEmulator(); err != nil {
+		return nil, err
+	}
+
+	q.BaseInit(driverName, q)
+	q.SetReadLimits(snes.ReadLimits{MaxBatch: 1})
+
+	if frameDuration > 0 {
+		q.frameTicker = time.NewTicker(frameDuration)
+		go q.runFrames()
+	}
+	return
+}
+
+func (q *Queue) runFrames() {
+	for {
+		select {
+		case <-q.frameTicker.C:
+			q.StepFrame()
+		case <-q.closed:
+			return
+		}
+	}
+}
+
+func (q *Queue) IsTerminalError(err error) bool {
+	return false
+}
+
+func (q *Queue) Closed() <-chan struct{} {
+	return q.closed
+}
+
+func (q *Queue) Close() error {
+	q.closeOnce.Do(func() {
+		if q.frameTicker != nil {
+			q.frameTicker.Stop()
+		}
+		close(q.closed)
+	})
+	return nil
+}
+
+// Frames returns the number of frames run since the last boot
+func (q *Queue) Frames() uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.frames
+}
+
+// StepFrame runs one frame of the booted ROM and reports whether it reached FrameEndPC within MaxCycles
+func (q *Queue) StepFrame() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !q.booted {
+		return false
+	}
+
+	s := q.system
+	s.CPU = q.bootCPU
+	s.SetPC(q.FrameStartPC)
+	ok := s.RunUntil(q.FrameEndPC, q.MaxCycles)
+	q.frames++
+
+	if !ok && !q.stuck {
+		// only log the first of what is likely every frame getting stuck:
+		log.Printf("%s: frame %d did not reach $%06x; stopped at $%06x\n", driverName, q.frames, q.FrameEndPC, s.GetPC())
+	}
+	q.stuck = !ok
+	return ok
+}
+
+// boot loads the ROM contents into the emulator and runs its reset routine until BootPC
+func (q *Queue) boot(rom []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	s := q.system
+	if len(rom) > len(s.ROM) {
+		return fmt.Errorf("%s: ROM size %#x exceeds %#x", driverName, len(rom), len(s.ROM))
+	}
+
+	q.booted = false
+	q.frames = 0
+	q.stuck = false
+
+	copy(s.ROM[:], rom)
+	clear(s.ROM[len(rom):])
+	clear(s.WRAM[:])
+	clear(s.SRAM[:])
+
+	s.CPU.Reset()
+	if !s.RunUntil(q.BootPC, q.MaxCycles) {
+		return fmt.Errorf("%s: boot did not reach $%06x; stopped at $%06x", driverName, q.BootPC, s.GetPC())
+	}
+
+	q.bootCPU = s.CPU
+	q.booted = true
+	return nil
+}
+
+// memory returns the emulated memory at the FX Pak Pro address space range [address, address+size) or nil if the
+// range is not mapped
+func (q *Queue) memory(address uint32, size int) []byte {
+	s := q.system
+	end := address + uint32(size)
+
+	if address >= 0xF5_0000 && end <= 0xF7_0000 {
+		return s.WRAM[address-0xF5_0000 : end-0xF5_0000]
+	}
+	if address >= 0xE0_0000 && end <= 0xE0_0000+uint32(len(s.SRAM)) {
+		return s.SRAM[address-0xE0_0000 : end-0xE0_0000]
+	}
+	if end <= 0xE0_0000 {
+		return s.ROM[address:end]
+	}
+	return nil
+}
+
+func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) snes.CommandSequence {
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return &readCommand{batch[0]}
+	})
+}
+
+func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) snes.CommandSequence {
+	seq := make(snes.CommandSequence, 0, len(reqs))
+	for _, req := range reqs {
+		seq = append(seq, snes.CommandWithCompletion{
+			Command:    &writeCommand{req},
+			Completion: batchComplete,
+		})
+	}
+	return seq
+}
+
+type readCommand struct {
+	Request snes.Read
+}
+
+func (r *readCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) error {
+	q, ok := queue.(*Queue)
+	if !ok {
+		return fmt.Errorf("queue is not of expected internal type")
+	}
+
+	// copy out the data since the emulator keeps running:
+	data := make([]byte, r.Request.Size)
+	q.lock.Lock()
+	if m := q.memory(r.Request.Address, len(data)); m != nil {
+		copy(data, m)
+	}
+	q.lock.Unlock()
+
+	completed := r.Request.Completion
+	if completed != nil {
+		completed(snes.Response{
+			IsWrite: false,
+			Address: r.Request.Address,
+			Size:    r.Request.Size,
+			Extra:   r.Request.Extra,
+			Data:    data,
+		})
+	}
+
+	return nil
+}
+
+type writeCommand struct {
+	Request snes.Write
+}
+
+func (r *writeCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) error {
+	q, ok := queue.(*Queue)
+	if !ok {
+		return fmt.Errorf("queue is not of expected internal type")
+	}
+
+	q.lock.Lock()
+	m := q.memory(r.Request.Address, len(r.Request.Data))
+	if m != nil {
+		copy(m, r.Request.Data)
+	}
+	q.lock.Unlock()
+
+	if m == nil {
+		return fmt.Errorf("%s: write to unmapped address $%06x", driverName, r.Request.Address)
+	}
+
+	completed := r.Request.Completion
+	if completed != nil {
+		completed(snes.Response{
+			IsWrite: true,
+			Address: r.Request.Address,
+			Size:    r.Request.Size,
+			Extra:   r.Request.Extra,
+			Data:    r.Request.Data,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package emulated
+
+import (
+	"fmt"
+	"o2/snes"
+	"strings"
+)
+
+func (q *Queue) MakeUploadROMCommands(folder string, filename string, rom []byte) (path string, cmds snes.CommandSequence) {
+	// let the folder and filename be joined correctly:
+	folder = strings.TrimRight(folder, "/")
+	filename = strings.TrimLeft(filename, "/")
+	path = strings.Join([]string{folder, filename}, "/")
+
+	cmds = snes.CommandSequence{
+		snes.CommandWithCompletion{Command: &uploadCommand{path: path, rom: rom}},
+	}
+	return
+}
+
+func (q *Queue) MakeBootROMCommands(path string) snes.CommandSequence {
+	return snes.CommandSequence{
+		snes.CommandWithCompletion{Command: &bootCommand{path: path}},
+	}
+}
+
+type uploadCommand struct {
+	path string
+	rom  []byte
+}
+
+func (c *uploadCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) error {
+	q, ok := queue.(*Queue)
+	if !ok {
+		return fmt.Errorf("queue is not of expected internal type")
+	}
+
+	rom := make([]byte, len(c.rom))
+	copy(rom, c.rom)
+
+	q.lock.Lock()
+	q.roms[c.path] = rom
+	q.lock.Unlock()
+	return nil
+}
+
+type bootCommand struct {
+	path string
+}
+
+func (c *bootCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) error {
+	q, ok := queue.(*Queue)
+	if !ok {
+		return fmt.Errorf("queue is not of expected internal type")
+	}
+
+	q.lock.Lock()
+	rom, ok := q.roms[c.path]
+	q.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("%s: boot: no ROM uploaded to '%s'", driverName, c.path)
+	}
+
+	return q.boot(rom)
+}
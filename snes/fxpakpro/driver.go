@@ -163,10 +163,14 @@ func (d *Driver) Open(ddg snes.DeviceDescriptor) (snes.Queue, error) {
 		closed: make(chan struct{}),
 	}
 	c.BaseInit(driverName, c)
+	c.SetReadLimits(ReadLimits)
 
 	return c, err
 }
 
+// ReadLimits describes the VGET command: it carries up to 8 reads of up to 255 bytes each
+var ReadLimits = snes.ReadLimits{MaxSize: 255, MaxBatch: 8}
+
 func init() {
 	if util.IsTruthy(env.GetOrDefault("O2_FXPAKPRO_DISABLE", "0")) {
 		log.Printf("disabling fxpakpro snes driver\n")
@@ -41,28 +41,9 @@ func (q *Queue) Close() (err error) {
 }
 
 func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) (cmds snes.CommandSequence) {
-	cmds = make(snes.CommandSequence, 0, len(reqs)/8+1)
-
-	for len(reqs) >= 8 {
-		// queue up a VGET command:
-		batch := reqs[:8]
-		cmds = append(cmds, snes.CommandWithCompletion{
-			Command:    q.newVGET(batch),
-			Completion: batchComplete,
-		})
-
-		// move to next batch:
-		reqs = reqs[8:]
-	}
-
-	if len(reqs) > 0 && len(reqs) <= 8 {
-		cmds = append(cmds, snes.CommandWithCompletion{
-			Command:    q.newVGET(reqs),
-			Completion: batchComplete,
-		})
-	}
-
-	return
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return q.newVGET(batch)
+	})
 }
 
 func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) (cmds snes.CommandSequence) {
@@ -26,6 +26,7 @@ func (d *Driver) DisplayDescription() string {
 func (d *Driver) Open(desc snes.DeviceDescriptor) (snes.Queue, error) {
 	c := &Queue{}
 	c.BaseInit(driverName, c)
+	c.SetReadLimits(snes.ReadLimits{MaxBatch: 1})
 	c.Init()
 	return c, nil
 }
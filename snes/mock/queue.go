@@ -53,14 +53,9 @@ func (q *Queue) Init() {
 }
 
 func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) snes.CommandSequence {
-	seq := make(snes.CommandSequence, 0, len(reqs))
-	for _, req := range reqs {
-		seq = append(seq, snes.CommandWithCompletion{
-			Command:    &readCommand{req},
-			Completion: batchComplete,
-		})
-	}
-	return seq
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return &readCommand{batch[0]}
+	})
 }
 
 func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) snes.CommandSequence {
@@ -0,0 +1,203 @@
+package nwa
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the first TCP port emulators listen on for emu-nwaccess connections; further emulator instances use
+// the following ports
+const DefaultPort = 0xBEEF
+
+const timeout = time.Second * 5
+
+var (
+	ErrClosed = fmt.Errorf("connection is closed")
+)
+
+// ReplyError is an error reply from the emulator to a command; the connection is still usable
+type ReplyError struct {
+	Command string
+	Code    string
+	Reason  string
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("nwa: %s: error %s: %s", e.Command, e.Code, e.Reason)
+}
+
+// Reply is either an ASCII reply as a list of key:value maps or a binary reply
+type Reply struct {
+	ASCII  []map[string]string
+	Binary []byte
+}
+
+// Get returns the value of the key in the first map of an ASCII reply
+func (r *Reply) Get(key string) string {
+	if len(r.ASCII) == 0 {
+		return ""
+	}
+	return r.ASCII[0][key]
+}
+
+// Client is a connection to an emulator speaking the emu-nwaccess protocol
+type Client struct {
+	addr string
+
+	lock sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func Dial(addr string, dialTimeout time.Duration) (c *Client, err error) {
+	var conn net.Conn
+	conn, err = net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return
+	}
+
+	c = &Client{
+		addr: addr,
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}
+	return
+}
+
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Command sends an ASCII command and reads its reply
+func (c *Client) Command(cmd string, args ...string) (rsp Reply, err error) {
+	return c.send(cmd, args, nil)
+}
+
+// BinaryCommand sends a binary command followed by its data block and reads its reply
+func (c *Client) BinaryCommand(cmd string, data []byte, args ...string) (rsp Reply, err error) {
+	return c.send("b"+cmd, args, data)
+}
+
+func (c *Client) send(cmd string, args []string, data []byte) (rsp Reply, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		err = ErrClosed
+		return
+	}
+
+	// any failure to talk to the emulator leaves the connection in an unknown state:
+	defer func() {
+		var replyError *ReplyError
+		if err != nil && !errors.As(err, &replyError) {
+			_ = c.conn.Close()
+			c.conn = nil
+		}
+	}()
+
+	if err = c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(cmd)
+	if len(args) > 0 {
+		sb.WriteByte(' ')
+		sb.WriteString(strings.Join(args, ";"))
+	}
+	sb.WriteByte('\n')
+
+	b := []byte(sb.String())
+	if data != nil {
+		// binary block:
+		var hdr [5]byte
+		binary.BigEndian.PutUint32(hdr[1:], uint32(len(data)))
+		b = append(b, hdr[:]...)
+		b = append(b, data...)
+	}
+	if _, err = c.conn.Write(b); err != nil {
+		return
+	}
+
+	rsp, err = c.readReply()
+	if err != nil {
+		return
+	}
+
+	// check for an error reply:
+	if len(rsp.ASCII) > 0 {
+		if code, ok := rsp.ASCII[0]["error"]; ok {
+			err = &ReplyError{Command: cmd, Code: code, Reason: rsp.ASCII[0]["reason"]}
+		}
+	}
+	return
+}
+
+func (c *Client) readReply() (rsp Reply, err error) {
+	var kind byte
+	kind, err = c.r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	switch kind {
+	case 0:
+		// binary reply:
+		var hdr [4]byte
+		if _, err = io.ReadFull(c.r, hdr[:]); err != nil {
+			return
+		}
+		rsp.Binary = make([]byte, binary.BigEndian.Uint32(hdr[:]))
+		_, err = io.ReadFull(c.r, rsp.Binary)
+		return
+	case '\n':
+		// ASCII reply of key:value lines ending with an empty line; a repeated key starts a new map:
+		m := map[string]string{}
+		for {
+			var line string
+			line, err = c.r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				break
+			}
+
+			key, value, _ := strings.Cut(line, ":")
+			if _, dup := m[key]; dup {
+				rsp.ASCII = append(rsp.ASCII, m)
+				m = map[string]string{}
+			}
+			m[key] = value
+		}
+		if len(m) > 0 {
+			rsp.ASCII = append(rsp.ASCII, m)
+		}
+		return
+	default:
+		err = fmt.Errorf("nwa: unexpected reply type $%02x", kind)
+		return
+	}
+}
@@ -0,0 +1,31 @@
+package nwa
+
+import (
+	"fmt"
+	"o2/snes"
+)
+
+type DeviceDescriptor struct {
+	snes.DeviceDescriptorBase
+
+	Addr         string `json:"addr"`
+	Emulator     string `json:"emulator"`
+	Version      string `json:"version"`
+	IsGameLoaded bool   `json:"isGameLoaded"`
+}
+
+func (d *DeviceDescriptor) Base() *snes.DeviceDescriptorBase {
+	return &d.DeviceDescriptorBase
+}
+
+func (d *DeviceDescriptor) GetId() string {
+	// JSON unmarshaled descriptors only come back with the id:
+	if d.Addr == "" {
+		return d.Id
+	}
+	return d.Addr
+}
+
+func (d *DeviceDescriptor) GetDisplayName() string {
+	return fmt.Sprintf("%s %s at %s", d.Emulator, d.Version, d.Addr)
+}
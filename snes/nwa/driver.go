@@ -0,0 +1,191 @@
+package nwa
+
+import (
+	"fmt"
+	"log"
+	"o2/snes"
+	"o2/util"
+	"o2/util/env"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const driverName = "nwa"
+
+// the number of ports starting at DefaultPort that are scanned for emulators:
+const defaultPortCount = 5
+
+var logDetector = false
+
+type Driver struct {
+	addresses []string
+	// local folder that ROMs are uploaded to for LOAD_GAME:
+	romDir string
+
+	lock    sync.Mutex
+	devices []snes.DeviceDescriptor
+	opened  snes.Queue
+}
+
+func NewDriver(addresses []string, romDir string) *Driver {
+	return &Driver{
+		addresses: addresses,
+		romDir:    romDir,
+	}
+}
+
+func (d *Driver) DisplayOrder() int {
+	return 4
+}
+
+func (d *Driver) DisplayName() string {
+	return "NWA"
+}
+
+func (d *Driver) DisplayDescription() string {
+	return "Connect to an emulator with emu-nwaccess support, e.g. snes9x-nwa or bsnes-plus-nwa"
+}
+
+// dial connects to the emulator and fetches its EMULATOR_INFO
+func dial(addr string, dialTimeout time.Duration) (c *Client, info Reply, err error) {
+	c, err = Dial(addr, dialTimeout)
+	if err != nil {
+		return
+	}
+
+	if _, err = c.Command("MY_NAME_IS", "O2"); err != nil {
+		_ = c.Close()
+		return
+	}
+	if info, err = c.Command("EMULATOR_INFO"); err != nil {
+		_ = c.Close()
+		return
+	}
+	return
+}
+
+// supports reports whether the EMULATOR_INFO lists the command
+func supports(info Reply, command string) bool {
+	for _, name := range strings.Split(info.Get("commands"), ",") {
+		if strings.TrimSpace(name) == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Driver) Detect() (devices []snes.DeviceDescriptor, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// stop auto-detection if connected already:
+	if d.opened != nil {
+		devices = d.devices
+		return
+	}
+
+	devices = make([]snes.DeviceDescriptor, 0, len(d.addresses))
+	for _, addr := range d.addresses {
+		c, info, err := dial(addr, time.Millisecond*100)
+		if err != nil {
+			if logDetector {
+				log.Printf("nwa: detect: %s: %v\n", addr, err)
+			}
+			continue
+		}
+
+		var status Reply
+		status, err = c.Command("EMULATION_STATUS")
+		_ = c.Close()
+		if err != nil {
+			if logDetector {
+				log.Printf("nwa: detect: %s: %v\n", addr, err)
+			}
+			continue
+		}
+
+		state := status.Get("state")
+		descriptor := &DeviceDescriptor{
+			Addr:         addr,
+			Emulator:     info.Get("name"),
+			Version:      info.Get("version"),
+			IsGameLoaded: state == "running" || state == "paused",
+		}
+		snes.MarshalDeviceDescriptor(descriptor)
+		devices = append(devices, descriptor)
+	}
+
+	d.devices = devices
+	return
+}
+
+func (d *Driver) Open(desc snes.DeviceDescriptor) (q snes.Queue, err error) {
+	descriptor, ok := desc.(*DeviceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("nwa: open: descriptor is not of expected type")
+	}
+
+	c, info, err := dial(descriptor.GetId(), time.Second*5)
+	if err != nil {
+		return nil, fmt.Errorf("nwa: open: %w", err)
+	}
+
+	qu := &Queue{
+		c:      c,
+		romDir: d.romDir,
+		closed: make(chan struct{}),
+	}
+	if supports(info, "LOAD_GAME") {
+		rq := &ROMControlQueue{Queue: qu}
+		rq.BaseInit(driverName, rq)
+		q = rq
+	} else {
+		qu.BaseInit(driverName, qu)
+		q = qu
+	}
+
+	// record that this device is opened:
+	d.lock.Lock()
+	d.opened = q
+	d.lock.Unlock()
+	go func() {
+		<-q.Closed()
+		d.lock.Lock()
+		d.opened = nil
+		d.lock.Unlock()
+	}()
+
+	return
+}
+
+func (d *Driver) Empty() snes.DeviceDescriptor {
+	return &DeviceDescriptor{}
+}
+
+func init() {
+	if util.IsTruthy(env.GetOrDefault("O2_NWA_DISABLE", "0")) {
+		log.Printf("disabling nwa snes driver\n")
+		return
+	}
+
+	// comma-delimited list of host:port pairs:
+	hostsStr := env.GetOrSupply("O2_NWA_HOSTS", func() string {
+		// each emulator instance listens on the next free port starting from DefaultPort:
+		hosts := make([]string, 0, defaultPortCount)
+		for i := 0; i < defaultPortCount; i++ {
+			hosts = append(hosts, fmt.Sprintf("localhost:%d", DefaultPort+i))
+		}
+		return strings.Join(hosts, ",")
+	})
+
+	if util.IsTruthy(env.GetOrDefault("O2_NWA_DETECT_LOG", "0")) {
+		logDetector = true
+		log.Printf("enabling nwa detector logging")
+	}
+
+	romDir := env.GetOrSupply("O2_NWA_ROM_DIR", os.TempDir)
+
+	snes.Register(driverName, NewDriver(strings.Split(hostsStr, ","), romDir))
+}
@@ -0,0 +1,425 @@
+package nwa
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"o2/snes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEmulator is a local emu-nwaccess server with WRAM, SRAM and CARTROM memories
+type fakeEmulator struct {
+	t  *testing.T
+	ln net.Listener
+
+	loadGame bool
+
+	lock     sync.Mutex
+	memory   map[string][]byte
+	commands []string
+	loaded   string
+}
+
+func newFakeEmulator(t *testing.T, loadGame bool) *fakeEmulator {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &fakeEmulator{
+		t:        t,
+		ln:       ln,
+		loadGame: loadGame,
+		memory: map[string][]byte{
+			"WRAM":    make([]byte, 0x20000),
+			"SRAM":    make([]byte, 0x8000),
+			"CARTROM": make([]byte, 0x100000),
+		},
+	}
+	for name, m := range e.memory {
+		for i := range m {
+			m[i] = byte(i) ^ name[0]
+		}
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go e.serve(conn)
+		}
+	}()
+	return e
+}
+
+func (e *fakeEmulator) Addr() string {
+	return e.ln.Addr().String()
+}
+
+func (e *fakeEmulator) Commands() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]string(nil), e.commands...)
+}
+
+// Memory returns a copy of the emulator's memory
+func (e *fakeEmulator) Memory(memory string, offset uint32, size int) []byte {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]byte(nil), e.memory[memory][offset:offset+uint32(size)]...)
+}
+
+func asciiReply(w io.Writer, pairs ...string) {
+	var sb strings.Builder
+	sb.WriteByte('\n')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		sb.WriteString(pairs[i] + ":" + pairs[i+1] + "\n")
+	}
+	sb.WriteByte('\n')
+	_, _ = io.WriteString(w, sb.String())
+}
+
+func binaryReply(w io.Writer, data []byte) {
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(data)))
+	_, _ = w.Write(append(hdr[:], data...))
+}
+
+func parseNumber(s string) (uint64, error) {
+	if strings.HasPrefix(s, "$") {
+		return strconv.ParseUint(s[1:], 16, 32)
+	}
+	return strconv.ParseUint(s, 10, 32)
+}
+
+// regions parses the memory name and offset;length pairs of a memory command
+func (e *fakeEmulator) regions(args []string) (m []byte, regions [][2]uint64, err error) {
+	if len(args) == 0 || len(args)%2 != 1 {
+		return nil, nil, fmt.Errorf("bad arguments")
+	}
+	m, ok := e.memory[args[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown memory %q", args[0])
+	}
+	for i := 1; i < len(args); i += 2 {
+		var offset, length uint64
+		if offset, err = parseNumber(args[i]); err != nil {
+			return
+		}
+		if length, err = parseNumber(args[i+1]); err != nil {
+			return
+		}
+		if offset+length > uint64(len(m)) {
+			return nil, nil, fmt.Errorf("out of range")
+		}
+		regions = append(regions, [2]uint64{offset, length})
+	}
+	return
+}
+
+func (e *fakeEmulator) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		cmd, argStr, _ := strings.Cut(line, " ")
+		var args []string
+		if argStr != "" {
+			args = strings.Split(argStr, ";")
+		}
+
+		var data []byte
+		if strings.HasPrefix(cmd, "b") {
+			var hdr [5]byte
+			if _, err = io.ReadFull(r, hdr[:]); err != nil {
+				return
+			}
+			data = make([]byte, binary.BigEndian.Uint32(hdr[1:]))
+			if _, err = io.ReadFull(r, data); err != nil {
+				return
+			}
+		}
+
+		e.lock.Lock()
+		e.commands = append(e.commands, line)
+		e.handle(conn, cmd, args, data)
+		e.lock.Unlock()
+	}
+}
+
+func (e *fakeEmulator) handle(w io.Writer, cmd string, args []string, data []byte) {
+	switch cmd {
+	case "MY_NAME_IS":
+		asciiReply(w, "name", args[0])
+	case "EMULATOR_INFO":
+		commands := "EMULATOR_INFO,EMULATION_STATUS,MY_NAME_IS,CORE_READ,bCORE_WRITE"
+		if e.loadGame {
+			commands += ",LOAD_GAME"
+		}
+		asciiReply(w, "name", "fake", "version", "1.0", "nwa_version", "1.0", "id", "1", "commands", commands)
+	case "EMULATION_STATUS":
+		asciiReply(w, "state", "running", "game", "test")
+	case "CORE_READ":
+		m, regions, err := e.regions(args)
+		if err != nil {
+			asciiReply(w, "error", "invalid_argument", "reason", err.Error())
+			return
+		}
+		var out []byte
+		for _, rg := range regions {
+			out = append(out, m[rg[0]:rg[0]+rg[1]]...)
+		}
+		binaryReply(w, out)
+	case "bCORE_WRITE":
+		m, regions, err := e.regions(args)
+		if err != nil {
+			asciiReply(w, "error", "invalid_argument", "reason", err.Error())
+			return
+		}
+		for _, rg := range regions {
+			copy(m[rg[0]:rg[0]+rg[1]], data)
+			data = data[rg[1]:]
+		}
+		asciiReply(w)
+	case "LOAD_GAME":
+		if !e.loadGame {
+			asciiReply(w, "error", "invalid_command", "reason", "unsupported")
+			return
+		}
+		e.loaded = strings.Join(args, ";")
+		asciiReply(w)
+	default:
+		asciiReply(w, "error", "invalid_command", "reason", "unknown command "+cmd)
+	}
+}
+
+// wait enqueues the commands and waits for them all to complete
+func wait(t *testing.T, q snes.Queue, seq snes.CommandSequence) (errs []error) {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for i := range seq {
+		wg.Add(1)
+		seq[i].Completion = func(cmd snes.Command, err error) {
+			if err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+			wg.Done()
+		}
+	}
+	if err := seq.EnqueueTo(q); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for commands")
+	}
+	return
+}
+
+func openFake(t *testing.T, d *Driver) (q snes.Queue, device *DeviceDescriptor) {
+	devices, err := d.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("expected 1 device, got %d", len(devices))
+	}
+	device = devices[0].(*DeviceDescriptor)
+
+	q, err = d.Open(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.Close() })
+	return
+}
+
+func TestDriver_Detect(t *testing.T) {
+	e := newFakeEmulator(t, false)
+
+	// find a port with nothing listening:
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := ln.Addr().String()
+	_ = ln.Close()
+
+	d := NewDriver([]string{closedAddr, e.Addr()}, t.TempDir())
+	devices, err := d.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Fatalf("expected 1 device, got %d", len(devices))
+	}
+
+	device := devices[0].(*DeviceDescriptor)
+	if expected, actual := e.Addr(), device.Id; expected != actual {
+		t.Errorf("expected id %q, got %q", expected, actual)
+	}
+	if expected, actual := "fake 1.0 at "+e.Addr(), device.DisplayName; expected != actual {
+		t.Errorf("expected display name %q, got %q", expected, actual)
+	}
+	if !device.IsGameLoaded {
+		t.Errorf("expected game to be loaded")
+	}
+
+	commands := e.Commands()
+	if len(commands) == 0 || commands[0] != "MY_NAME_IS O2" {
+		t.Errorf("expected MY_NAME_IS first, got %q", commands)
+	}
+}
+
+func TestQueue_ReadWrite(t *testing.T) {
+	e := newFakeEmulator(t, false)
+	d := NewDriver([]string{e.Addr()}, t.TempDir())
+	q, _ := openFake(t, d)
+
+	if _, ok := q.(snes.ROMControl); ok {
+		t.Errorf("expected no ROMControl without LOAD_GAME")
+	}
+
+	// write to WRAM and SRAM:
+	errs := wait(t, q, q.MakeWriteCommands([]snes.Write{
+		{Address: 0xF5_0010, Size: 2, Data: []byte{0x07, 0x01}},
+		{Address: 0xF5_F342, Size: 1, Data: []byte{0x01}},
+		{Address: 0xE0_0100, Size: 3, Data: []byte{0xAA, 0xBB, 0xCC}},
+	}, nil))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if !bytes.Equal(e.Memory("WRAM", 0x10, 2), []byte{0x07, 0x01}) || e.Memory("WRAM", 0xF342, 1)[0] != 0x01 {
+		t.Errorf("WRAM not written")
+	}
+	if !bytes.Equal(e.Memory("SRAM", 0x100, 3), []byte{0xAA, 0xBB, 0xCC}) {
+		t.Errorf("SRAM not written")
+	}
+
+	// read back from each memory:
+	reads := []struct {
+		address uint32
+		size    uint8
+		memory  string
+		offset  uint32
+	}{
+		{0xF5_0010, 0xF0, "WRAM", 0x10},
+		{0xF5_0100, 0x36, "WRAM", 0x100},
+		{0xE0_0100, 0x03, "SRAM", 0x100},
+		{0x00_7FC0, 0x20, "CARTROM", 0x7FC0},
+		{0xF5_F340, 0x10, "WRAM", 0xF340},
+	}
+	got := make([][]byte, len(reads))
+	reqs := make([]snes.Read, len(reads))
+	for i, rd := range reads {
+		i := i
+		reqs[i] = snes.Read{
+			Address:    rd.address,
+			Size:       rd.size,
+			Completion: func(rsp snes.Response) { got[i] = rsp.Data },
+		}
+	}
+	errs = wait(t, q, q.MakeReadCommands(reqs, nil))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for i, rd := range reads {
+		expected := e.Memory(rd.memory, rd.offset, int(rd.size))
+		if !bytes.Equal(expected, got[i]) {
+			t.Errorf("read %d: expected %x, got %x", i, expected, got[i])
+		}
+	}
+
+	// an error reply does not close the queue:
+	errs = wait(t, q, q.MakeReadCommands([]snes.Read{{Address: 0xF9_0000, Size: 1}}, nil))
+	if len(errs) != 1 {
+		t.Fatalf("expected an error reading an unsupported address")
+	}
+	errs = wait(t, q, q.MakeWriteCommands([]snes.Write{{Address: 0xE0_7FFF, Size: 2, Data: []byte{1, 2}}}, nil))
+	if len(errs) != 1 || q.IsTerminalError(errs[0]) {
+		t.Fatalf("expected a non-terminal error reply, got %v", errs)
+	}
+	select {
+	case <-q.Closed():
+		t.Fatal("queue closed after an error reply")
+	default:
+	}
+
+	// while opened, detection reports the opened device without connecting:
+	n := len(e.Commands())
+	if devices, _ := d.Detect(); len(devices) != 1 {
+		t.Errorf("expected the opened device to be detected")
+	}
+	if len(e.Commands()) != n {
+		t.Errorf("expected no commands sent while detecting an opened device")
+	}
+}
+
+func TestQueue_ROMControl(t *testing.T) {
+	e := newFakeEmulator(t, true)
+	romDir := t.TempDir()
+	d := NewDriver([]string{e.Addr()}, romDir)
+	q, _ := openFake(t, d)
+
+	rc, ok := q.(snes.ROMControl)
+	if !ok {
+		t.Fatal("expected ROMControl with LOAD_GAME")
+	}
+
+	rom := []byte("test rom contents")
+	path, seq := rc.MakeUploadROMCommands("o2", "test.sfc", rom)
+	if expected := filepath.Join(romDir, "o2", "test.sfc"); expected != path {
+		t.Errorf("expected path %q, got %q", expected, path)
+	}
+	seq = append(seq, rc.MakeBootROMCommands(path)...)
+	if errs := wait(t, q, seq); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if written, err := os.ReadFile(path); err != nil || !bytes.Equal(written, rom) {
+		t.Errorf("expected ROM written to %q: %v", path, err)
+	}
+	e.lock.Lock()
+	loaded := e.loaded
+	e.lock.Unlock()
+	if loaded != path {
+		t.Errorf("expected LOAD_GAME %q, got %q", path, loaded)
+	}
+
+	// reads still work through the ROMControl queue:
+	var data []byte
+	errs := wait(t, q, q.MakeReadCommands([]snes.Read{
+		{Address: 0xF5_001A, Size: 1, Completion: func(rsp snes.Response) { data = rsp.Data }},
+	}, nil))
+	if len(errs) > 0 || len(data) != 1 || data[0] != e.Memory("WRAM", 0x1A, 1)[0] {
+		t.Errorf("expected read through ROMControl queue; errs=%v data=%x", errs, data)
+	}
+}
@@ -0,0 +1,228 @@
+package nwa
+
+import (
+	"errors"
+	"fmt"
+	"o2/snes"
+	"sync"
+)
+
+type Queue struct {
+	snes.BaseQueue
+
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	c *Client
+	// local folder that ROMs are uploaded to for LOAD_GAME:
+	romDir string
+}
+
+// ROMControlQueue is the Queue of an emulator that supports LOAD_GAME
+type ROMControlQueue struct {
+	*Queue
+}
+
+// queueOf returns the Queue of either queue type
+func queueOf(queue snes.Queue) (*Queue, error) {
+	switch q := queue.(type) {
+	case *Queue:
+		return q, nil
+	case *ROMControlQueue:
+		return q.Queue, nil
+	default:
+		return nil, fmt.Errorf("queue is not of expected internal type")
+	}
+}
+
+var (
+	ErrUnsupportedAddress = fmt.Errorf("address is not supported")
+)
+
+func (q *Queue) IsTerminalError(err error) bool {
+	var replyError *ReplyError
+	if errors.As(err, &replyError) {
+		// the emulator rejected the command but the connection is fine:
+		return false
+	}
+	if errors.Is(err, ErrUnsupportedAddress) {
+		return false
+	}
+	return true
+}
+
+func (q *Queue) Closed() <-chan struct{} {
+	return q.closed
+}
+
+func (q *Queue) Close() (err error) {
+	q.closeOnce.Do(func() {
+		err = q.c.Close()
+		close(q.closed)
+	})
+	return
+}
+
+// memoryDomain maps an FX Pak Pro address to an emulator memory name and offset
+func memoryDomain(address uint32) (memory string, offset uint32, err error) {
+	switch {
+	case address >= 0xF5_0000 && address < 0xF7_0000:
+		return "WRAM", address - 0xF5_0000, nil
+	case address >= 0xE0_0000 && address < 0xF0_0000:
+		return "SRAM", address - 0xE0_0000, nil
+	case address < 0xE0_0000:
+		return "CARTROM", address, nil
+	default:
+		return "", 0, fmt.Errorf("nwa: $%06x: %w", address, ErrUnsupportedAddress)
+	}
+}
+
+// region formats an offset;length pair for a memory command
+func region(offset uint32, length int) string {
+	return fmt.Sprintf("$%x;$%x", offset, length)
+}
+
+func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) snes.CommandSequence {
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return &readCommand{batch}
+	})
+}
+
+func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) snes.CommandSequence {
+	return snes.CommandSequence{
+		snes.CommandWithCompletion{
+			Command:    &writeCommand{reqs},
+			Completion: batchComplete,
+		},
+	}
+}
+
+type readCommand struct {
+	Batch []snes.Read
+}
+
+func (cmd *readCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) (err error) {
+	q, err := queueOf(queue)
+	if err != nil {
+		return
+	}
+
+	// read each run of requests in the same memory with a single CORE_READ:
+	batch := cmd.Batch
+	for len(batch) > 0 {
+		var memory string
+		memory, _, err = memoryDomain(batch[0].Address)
+		if err != nil {
+			return
+		}
+
+		n := 0
+		size := 0
+		regions := make([]string, 0, len(batch))
+		for _, req := range batch {
+			var m string
+			var offset uint32
+			m, offset, err = memoryDomain(req.Address)
+			if err != nil {
+				return
+			}
+			if m != memory {
+				break
+			}
+			regions = append(regions, region(offset, int(req.Size)))
+			size += int(req.Size)
+			n++
+		}
+
+		var rsp Reply
+		rsp, err = q.c.Command("CORE_READ", append([]string{memory}, regions...)...)
+		if err != nil {
+			return
+		}
+		keepAlive <- struct{}{}
+
+		if len(rsp.Binary) != size {
+			err = fmt.Errorf("nwa: CORE_READ %s: expected %d bytes; got %d", memory, size, len(rsp.Binary))
+			return
+		}
+
+		data := rsp.Binary
+		for _, req := range batch[:n] {
+			if completed := req.Completion; completed != nil {
+				completed(snes.Response{
+					IsWrite: false,
+					Address: req.Address,
+					Size:    req.Size,
+					Extra:   req.Extra,
+					Data:    data[:req.Size],
+				})
+			}
+			data = data[req.Size:]
+		}
+
+		batch = batch[n:]
+	}
+
+	return
+}
+
+type writeCommand struct {
+	Batch []snes.Write
+}
+
+func (cmd *writeCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) (err error) {
+	q, err := queueOf(queue)
+	if err != nil {
+		return
+	}
+
+	// write each run of requests to the same memory with a single bCORE_WRITE:
+	batch := cmd.Batch
+	for len(batch) > 0 {
+		var memory string
+		memory, _, err = memoryDomain(batch[0].Address)
+		if err != nil {
+			return
+		}
+
+		n := 0
+		var data []byte
+		args := []string{memory}
+		for _, req := range batch {
+			var m string
+			var offset uint32
+			m, offset, err = memoryDomain(req.Address)
+			if err != nil {
+				return
+			}
+			if m != memory {
+				break
+			}
+			args = append(args, region(offset, len(req.Data)))
+			data = append(data, req.Data...)
+			n++
+		}
+
+		_, err = q.c.BinaryCommand("CORE_WRITE", data, args...)
+		if err != nil {
+			return
+		}
+		keepAlive <- struct{}{}
+
+		for _, req := range batch[:n] {
+			if completed := req.Completion; completed != nil {
+				completed(snes.Response{
+					IsWrite: true,
+					Address: req.Address,
+					Size:    req.Size,
+					Extra:   req.Extra,
+					Data:    req.Data,
+				})
+			}
+		}
+
+		batch = batch[n:]
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+package nwa
+
+import (
+	"o2/snes"
+	"os"
+	"path/filepath"
+)
+
+// MakeUploadROMCommands writes the ROM under the queue's local ROM folder for the emulator to load from; this only works
+// with emulators running on the same machine
+func (q *ROMControlQueue) MakeUploadROMCommands(folder string, filename string, rom []byte) (path string, cmds snes.CommandSequence) {
+	path = filepath.Join(q.romDir, folder, filename)
+	cmds = snes.CommandSequence{
+		snes.CommandWithCompletion{Command: &uploadROM{path: path, rom: rom}},
+	}
+	return
+}
+
+func (q *ROMControlQueue) MakeBootROMCommands(path string) snes.CommandSequence {
+	return snes.CommandSequence{
+		snes.CommandWithCompletion{Command: &bootROM{path: path}},
+	}
+}
+
+type uploadROM struct {
+	path string
+	rom  []byte
+}
+
+func (c *uploadROM) Execute(queue snes.Queue, keepAlive snes.KeepAlive) (err error) {
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return
+	}
+	return os.WriteFile(c.path, c.rom, 0644)
+}
+
+type bootROM struct {
+	path string
+}
+
+func (c *bootROM) Execute(queue snes.Queue, keepAlive snes.KeepAlive) (err error) {
+	q, err := queueOf(queue)
+	if err != nil {
+		return
+	}
+
+	_, err = q.c.Command("LOAD_GAME", c.path)
+	return
+}
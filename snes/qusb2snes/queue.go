@@ -83,12 +83,9 @@ func (q *Queue) Init() (err error) {
 }
 
 func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) snes.CommandSequence {
-	seq := make(snes.CommandSequence, 0, len(reqs))
-	seq = append(seq, snes.CommandWithCompletion{
-		Command:    &readCommand{reqs},
-		Completion: batchComplete,
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return &readCommand{batch}
 	})
-	return seq
 }
 
 func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) snes.CommandSequence {
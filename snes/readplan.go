@@ -0,0 +1,228 @@
+package snes
+
+import "sort"
+
+// MaxReadSize is the largest Size a single Read can request
+const MaxReadSize = 0xFF
+
+// ReadLimits describes what a driver's read commands can carry; PlanReads shapes reads to fit within them
+type ReadLimits struct {
+	// MaxSize is the most bytes a single read may request; 0 means MaxReadSize
+	MaxSize int
+	// MaxBatch is the most reads a single command may carry; 0 means no limit
+	MaxBatch int
+	// MaxGap is the most unrequested bytes between two reads that still lets them be merged into one read
+	MaxGap int
+}
+
+type readSpan struct {
+	start   uint32
+	end     uint32
+	members []int
+	// index of the last requested read in this span:
+	last int
+}
+
+// readSlice is the part of a planned read's data that belongs to an original read
+type readSlice struct {
+	member    int
+	dataOffs  int
+	reqOffs   int
+	size      int
+	assembled *readAssembly
+}
+
+// readAssembly collects the data of an original read that is split across multiple planned reads
+type readAssembly struct {
+	data      []byte
+	remaining int
+}
+
+// PlanReads merges adjacent or overlapping reads, splits them to fit limits.MaxSize and groups them into batches of at
+// most limits.MaxBatch reads. The Completion of each planned read fans out to the Completions of the original reads it
+// covers with their own slices of the data. Reads are only merged within the same 64KiB bank.
+//
+// A merged read takes the position of the last original read it covers, so a read that was requested last (e.g. to
+// validate all the reads before it) is still read last. When such a merged read has to be split, the pieces that carry
+// the last original read's bytes are read after the rest of its pieces.
+func PlanReads(reqs []Read, limits ReadLimits) (batches [][]Read) {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	maxSize := limits.MaxSize
+	if maxSize <= 0 || maxSize > MaxReadSize {
+		maxSize = MaxReadSize
+	}
+	gap := uint32(0)
+	if limits.MaxGap > 0 {
+		gap = uint32(limits.MaxGap)
+	}
+
+	// sweep the reads in address order to find the spans to read:
+	order := make([]int, len(reqs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return reqs[order[i]].Address < reqs[order[j]].Address
+	})
+
+	spans := make([]*readSpan, 0, len(reqs))
+	var span *readSpan
+	for _, i := range order {
+		req := &reqs[i]
+		start, end := req.Address, req.Address+uint32(req.Size)
+		if req.Size == 0 {
+			// nothing to read; complete it with the first planned read:
+			end = start
+		}
+
+		if span != nil && start <= span.end+gap && (end-1)>>16 == span.start>>16 && end > start {
+			span.members = append(span.members, i)
+			if end > span.end {
+				span.end = end
+			}
+			if i > span.last {
+				span.last = i
+			}
+			continue
+		}
+
+		span = &readSpan{start: start, end: end, members: []int{i}, last: i}
+		spans = append(spans, span)
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].last < spans[j].last
+	})
+
+	// split the spans into planned reads:
+	planned := make([]Read, 0, len(spans))
+	for _, span := range spans {
+		assemblies := make(map[int]*readAssembly)
+		// pieces that carry bytes of the span's last requested read are read after the others:
+		lastPieces := make([]Read, 0, 1)
+		for start := span.start; start < span.end || start == span.start; start += uint32(maxSize) {
+			end := start + uint32(maxSize)
+			if end > span.end {
+				end = span.end
+			}
+
+			slices := make([]readSlice, 0, len(span.members))
+			for _, m := range span.members {
+				req := &reqs[m]
+				reqStart, reqEnd := req.Address, req.Address+uint32(req.Size)
+
+				if reqStart == reqEnd {
+					// empty reads complete with the first planned read of their span:
+					if start == span.start {
+						slices = append(slices, readSlice{member: m})
+					}
+					continue
+				}
+
+				// intersect the original read with this planned read:
+				lo, hi := reqStart, reqEnd
+				if lo < start {
+					lo = start
+				}
+				if hi > end {
+					hi = end
+				}
+				if lo >= hi {
+					continue
+				}
+
+				s := readSlice{
+					member:   m,
+					dataOffs: int(lo - start),
+					reqOffs:  int(lo - reqStart),
+					size:     int(hi - lo),
+				}
+				if reqStart < start || reqEnd > end {
+					// the original read is split across planned reads:
+					a, ok := assemblies[m]
+					if !ok {
+						a = &readAssembly{
+							data:      make([]byte, req.Size),
+							remaining: int(req.Size),
+						}
+						assemblies[m] = a
+					}
+					s.assembled = a
+				}
+				slices = append(slices, s)
+			}
+			hasLast := false
+			for _, s := range slices {
+				if s.member == span.last {
+					hasLast = true
+				}
+			}
+
+			piece := Read{
+				Address:    start,
+				Size:       uint8(end - start),
+				Completion: fanOutRead(reqs, slices),
+			}
+			if hasLast {
+				lastPieces = append(lastPieces, piece)
+			} else {
+				planned = append(planned, piece)
+			}
+
+			if end == span.end {
+				break
+			}
+		}
+		planned = append(planned, lastPieces...)
+	}
+
+	// group the planned reads into batches:
+	maxBatch := limits.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = len(planned)
+	}
+	batches = make([][]Read, 0, (len(planned)+maxBatch-1)/maxBatch)
+	for len(planned) > maxBatch {
+		batches = append(batches, planned[:maxBatch:maxBatch])
+		planned = planned[maxBatch:]
+	}
+	batches = append(batches, planned)
+
+	return
+}
+
+func fanOutRead(reqs []Read, slices []readSlice) func(Response) {
+	return func(rsp Response) {
+		for _, s := range slices {
+			if s.dataOffs+s.size > len(rsp.Data) {
+				// the driver returned less data than requested:
+				continue
+			}
+
+			req := &reqs[s.member]
+			data := rsp.Data[s.dataOffs : s.dataOffs+s.size]
+			if a := s.assembled; a != nil {
+				copy(a.data[s.reqOffs:], data)
+				a.remaining -= s.size
+				if a.remaining > 0 {
+					continue
+				}
+				data = a.data
+			}
+
+			if req.Completion == nil {
+				continue
+			}
+			req.Completion(Response{
+				IsWrite: false,
+				Address: req.Address,
+				Size:    req.Size,
+				Extra:   req.Extra,
+				Data:    data,
+			})
+		}
+	}
+}
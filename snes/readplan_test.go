@@ -0,0 +1,141 @@
+package snes
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// testMemory serves planned reads from a flat memory where each byte is the low byte of its address
+func testMemory(t *testing.T, batches [][]Read) {
+	for _, batch := range batches {
+		for _, req := range batch {
+			if int(req.Size) > MaxReadSize {
+				t.Fatalf("planned read of %d bytes", req.Size)
+			}
+			data := make([]byte, req.Size)
+			for i := range data {
+				data[i] = byte(req.Address + uint32(i))
+			}
+			req.Completion(Response{Address: req.Address, Size: req.Size, Data: data})
+		}
+	}
+}
+
+func plannedRanges(batches [][]Read) string {
+	s := ""
+	for _, batch := range batches {
+		s += "["
+		for i, req := range batch {
+			if i > 0 {
+				s += " "
+			}
+			s += fmt.Sprintf("%06x+%02x", req.Address, req.Size)
+		}
+		s += "]"
+	}
+	return s
+}
+
+func TestPlanReads(t *testing.T) {
+	tests := []struct {
+		name   string
+		reqs   [][2]uint32
+		limits ReadLimits
+		want   string
+	}{
+		{
+			name: "adjacent and overlapping",
+			reqs: [][2]uint32{{0xF50100, 0x10}, {0xF50110, 0x10}, {0xF50108, 0x04}, {0xF50200, 0x10}},
+			want: "[f50100+20 f50200+10]",
+		},
+		{
+			name: "split oversized",
+			reqs: [][2]uint32{{0xF50010, 0xF0}, {0xF50100, 0x36}},
+			want: "[f50010+ff f5010f+27]",
+		},
+		{
+			name:   "smaller max size",
+			reqs:   [][2]uint32{{0xF50000, 0x30}},
+			limits: ReadLimits{MaxSize: 0x10},
+			want:   "[f50000+10 f50010+10 f50020+10]",
+		},
+		{
+			name:   "batches",
+			reqs:   [][2]uint32{{0xF50000, 1}, {0xF50010, 1}, {0xF50020, 1}},
+			limits: ReadLimits{MaxBatch: 2},
+			want:   "[f50000+01 f50010+01][f50020+01]",
+		},
+		{
+			name:   "gap",
+			reqs:   [][2]uint32{{0xF50000, 4}, {0xF50008, 4}, {0xF50020, 4}},
+			limits: ReadLimits{MaxGap: 4},
+			want:   "[f50000+0c f50020+04]",
+		},
+		{
+			name: "bank boundary",
+			reqs: [][2]uint32{{0xF5FFF0, 0x10}, {0xF60000, 0x10}},
+			want: "[f5fff0+10 f60000+10]",
+		},
+		{
+			name: "last read stays last",
+			reqs: [][2]uint32{{0xF50100, 0x10}, {0xF50200, 0x10}, {0xF50110, 0x10}},
+			want: "[f50200+10 f50100+20]",
+		},
+		{
+			name: "last read stays last when split",
+			reqs: [][2]uint32{{0xF50100, 0x36}, {0xF50010, 0xF0}},
+			want: "[f5010f+27 f50010+ff]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs := make([]Read, len(tt.reqs))
+			got := make([]*Response, len(tt.reqs))
+			for i, r := range tt.reqs {
+				i := i
+				reqs[i] = Read{
+					Address: r[0],
+					Size:    uint8(r[1]),
+					Extra:   i,
+					Completion: func(rsp Response) {
+						if got[i] != nil {
+							t.Errorf("read %d completed twice", i)
+						}
+						got[i] = &rsp
+					},
+				}
+			}
+
+			batches := PlanReads(reqs, tt.limits)
+			if actual := plannedRanges(batches); actual != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, actual)
+			}
+
+			testMemory(t, batches)
+			for i, req := range reqs {
+				rsp := got[i]
+				if rsp == nil {
+					t.Errorf("read %d did not complete", i)
+					continue
+				}
+				if rsp.Address != req.Address || rsp.Size != req.Size || rsp.Extra != i {
+					t.Errorf("read %d: unexpected response %+v", i, *rsp)
+				}
+				expected := make([]byte, req.Size)
+				for j := range expected {
+					expected[j] = byte(req.Address + uint32(j))
+				}
+				if !bytes.Equal(expected, rsp.Data) {
+					t.Errorf("read %d: expected data %x, got %x", i, expected, rsp.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanReads_Empty(t *testing.T) {
+	if batches := PlanReads(nil, ReadLimits{}); len(batches) != 0 {
+		t.Errorf("expected no batches, got %d", len(batches))
+	}
+}
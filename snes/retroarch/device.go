@@ -12,6 +12,9 @@ type DeviceDescriptor struct {
 	addr *net.UDPAddr
 
 	IsGameLoaded bool `json:"isGameLoaded"`
+	// System is the system id of the loaded core and Content is the name of the loaded content, if known
+	System  string `json:"system"`
+	Content string `json:"content"`
 }
 
 func (d *DeviceDescriptor) Base() *snes.DeviceDescriptorBase {
@@ -27,5 +30,8 @@ func (d *DeviceDescriptor) GetId() string {
 }
 
 func (d *DeviceDescriptor) GetDisplayName() string {
+	if d.Content != "" {
+		return fmt.Sprintf("RetroArch at %s (%s)", d.addr, d.Content)
+	}
 	return fmt.Sprintf("RetroArch at %s", d.addr)
 }
@@ -1,6 +1,7 @@
 package retroarch
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -8,7 +9,9 @@ import (
 	"o2/udpclient"
 	"o2/util"
 	"o2/util/env"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const driverName = "retroarch"
@@ -71,9 +74,19 @@ func (d *Driver) Open(desc snes.DeviceDescriptor) (q snes.Queue, err error) {
 	// fill back in the addr for the descriptor:
 	descriptor.addr = c.addr
 
+	// refuse to connect to cores for other systems:
+	if err = c.GetStatus(); err != nil {
+		log.Printf("retroarch: open: status: %v\n", err)
+		err = nil
+	}
+	if status := c.Status(); !status.IsSNES() {
+		return nil, fmt.Errorf("retroarch: open: loaded content '%s' is for system '%s' and not the SNES", status.Content, status.System)
+	}
+
 	c.MuteLog(false)
 	qu := &Queue{c: c}
 	qu.BaseInit(driverName, qu)
+	qu.SetReadLimits(snes.ReadLimits{MaxBatch: 8})
 	qu.Init()
 
 	q = qu
@@ -95,61 +108,113 @@ func (d *Driver) Detect() (devices []snes.DeviceDescriptor, err error) {
 		return
 	}
 
-	devices = make([]snes.DeviceDescriptor, 0, len(d.detectors))
+	// probe all detectors at once so that unresponsive ports don't add up:
+	found := make([]*DeviceDescriptor, len(d.detectors))
+	var wg sync.WaitGroup
 	for i, detector := range d.detectors {
-		detector.MuteLog(true)
-		if !detector.IsConnected() {
-			// "connect" to this UDP endpoint:
-			detector.version = ""
-			err = detector.Connect(detector.addr)
-			if err != nil {
-				if logDetector {
-					log.Printf("retroarch: detect: detector[%d]: connect: %v\n", i, err)
-				}
-				continue
-			}
+		wg.Add(1)
+		go func(i int, detector *RAClient) {
+			defer wg.Done()
+			found[i] = detect(i, detector)
+		}(i, detector)
+	}
+	wg.Wait()
+
+	devices = make([]snes.DeviceDescriptor, 0, len(d.detectors))
+	for _, descriptor := range found {
+		if descriptor == nil {
+			continue
 		}
+		devices = append(devices, descriptor)
+	}
 
-		// not a valid device without a version detected:
-		if !detector.HasVersion() {
-			err = detector.Version()
-			if err != nil {
-				if logDetector {
-					log.Printf("retroarch: detect: detector[%d]: version: %v\n", i, err)
-				}
-				continue
+	d.devices = devices
+	err = nil
+	return
+}
+
+// detect probes a RetroArch instance and returns its descriptor or nil if there is no usable instance
+func detect(i int, detector *RAClient) *DeviceDescriptor {
+	detector.MuteLog(true)
+	if !detector.IsConnected() {
+		// "connect" to this UDP endpoint:
+		detector.version = ""
+		err := detector.Connect(detector.addr)
+		if err != nil {
+			if logDetector {
+				log.Printf("retroarch: detect: detector[%d]: connect: %v\n", i, err)
 			}
+			return nil
 		}
-		if !detector.HasVersion() {
-			continue
-		}
+	}
 
-		// issue a sample read:
-		var data []byte
-		data, err = detector.ReadMemory(0x40FFC0, 32)
+	// not a valid device without a version detected:
+	if !detector.HasVersion() {
+		err := detector.Version()
 		if err != nil {
-			err = nil
-			detector.version = ""
-			continue
+			if logDetector {
+				log.Printf("retroarch: detect: detector[%d]: version: %v\n", i, err)
+			}
+			return nil
 		}
+	}
+	if !detector.HasVersion() {
+		return nil
+	}
 
-		descriptor := &DeviceDescriptor{
-			DeviceDescriptorBase: snes.DeviceDescriptorBase{},
-			addr:                 detector.addr,
+	// find out what core and content is loaded; the status is unknown if this fails:
+	if err := detector.GetStatus(); err != nil {
+		if logDetector {
+			log.Printf("retroarch: detect: detector[%d]: status: %v\n", i, err)
 		}
+	}
+	status := detector.Status()
+	if !status.IsSNES() {
+		if logDetector {
+			log.Printf("retroarch: detect: detector[%d]: refusing non-SNES system '%s'\n", i, status.System)
+		}
+		return nil
+	}
 
-		if len(data) != 32 {
-			descriptor.IsGameLoaded = false
-		} else {
-			descriptor.IsGameLoaded = true
+	descriptor := &DeviceDescriptor{
+		DeviceDescriptorBase: snes.DeviceDescriptorBase{},
+		addr:                 detector.addr,
+		System:               status.System,
+		Content:              status.Content,
+	}
+
+	if status.State == "CONTENTLESS" {
+		descriptor.IsGameLoaded = false
+	} else {
+		// issue a sample read:
+		data, err := detector.ReadMemory(0x40FFC0, 32)
+		if err != nil && !errors.Is(err, ErrNoMemoryMap) {
+			detector.version = ""
+			return nil
 		}
 
-		snes.MarshalDeviceDescriptor(descriptor)
-		devices = append(devices, descriptor)
+		descriptor.IsGameLoaded = len(data) == 32
 	}
 
-	d.devices = devices
-	err = nil
+	snes.MarshalDeviceDescriptor(descriptor)
+	return descriptor
+}
+
+// parsePortRange parses either a single port or an inclusive range of ports like "55355-55362"
+func parsePortRange(s string) (first int, last int, err error) {
+	firstStr, lastStr, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	if first, err = strconv.Atoi(strings.TrimSpace(firstStr)); err != nil {
+		return
+	}
+	last = first
+	if isRange {
+		if last, err = strconv.Atoi(strings.TrimSpace(lastStr)); err != nil {
+			return
+		}
+	}
+	if first < 1 || last > 65535 || last < first {
+		err = fmt.Errorf("invalid port range '%s'", s)
+	}
 	return
 }
 
@@ -166,14 +231,20 @@ func init() {
 	// comma-delimited list of host:port pairs:
 	hostsStr := env.GetOrSupply("O2_RETROARCH_HOSTS", func() string {
 		// default network_cmd_port for RA is UDP 55355. we want to support connecting to multiple
-		// instances so let's auto-detect RA instances listening on UDP ports in the range
-		// [55355..55362]. realistically we probably won't be running any more than a few instances on
-		// the same machine at one time. i picked 8 since i currently have an 8-core CPU :)
+		// instances so let's auto-detect RA instances listening on UDP ports in a range of ports
+		// starting at 55355. realistically we probably won't be running any more than a few instances on
+		// the same machine at one time.
+		host := env.GetOrDefault("O2_RETROARCH_HOST", "localhost")
+		first, last, err := parsePortRange(env.GetOrDefault("O2_RETROARCH_PORTS", "55355-55362"))
+		if err != nil {
+			log.Printf("retroarch: O2_RETROARCH_PORTS: %v\n", err)
+			first, last = 55355, 55355
+		}
+
 		var sb strings.Builder
-		const count = 1
-		for i := 0; i < count; i++ {
-			sb.WriteString(fmt.Sprintf("localhost:%d", 55355+i))
-			if i < count-1 {
+		for port := first; port <= last; port++ {
+			sb.WriteString(net.JoinHostPort(host, strconv.Itoa(port)))
+			if port < last {
 				sb.WriteByte(',')
 			}
 		}
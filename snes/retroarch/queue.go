@@ -56,28 +56,9 @@ func (q *Queue) Init() {
 }
 
 func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) (cmds snes.CommandSequence) {
-	cmds = make(snes.CommandSequence, 0, len(reqs)/8+1)
-
-	for len(reqs) >= 8 {
-		// queue up a batch read command:
-		batch := reqs[:8]
-		cmds = append(cmds, snes.CommandWithCompletion{
-			Command:    &readCommand{batch},
-			Completion: batchComplete,
-		})
-
-		// move to next batch:
-		reqs = reqs[8:]
-	}
-
-	if len(reqs) > 0 && len(reqs) <= 8 {
-		cmds = append(cmds, snes.CommandWithCompletion{
-			Command:    &readCommand{reqs},
-			Completion: batchComplete,
-		})
-	}
-
-	return cmds
+	return q.MakePlannedReadCommands(reqs, batchComplete, func(batch []snes.Read) snes.Command {
+		return &readCommand{batch}
+	})
 }
 
 func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) (cmds snes.CommandSequence) {
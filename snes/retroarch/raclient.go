@@ -2,8 +2,10 @@ package retroarch
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/alttpo/snes/mapping/lorom"
+	"io"
 	"log"
 	"net"
 	"o2/snes"
@@ -12,6 +14,9 @@ import (
 	"time"
 )
 
+// ErrNoMemoryMap is returned when READ_CORE_MEMORY cannot read an address, e.g. because the core has no memory map
+var ErrNoMemoryMap = fmt.Errorf("no memory map")
+
 type RAClient struct {
 	udpclient.UDPClient
 
@@ -19,6 +24,65 @@ type RAClient struct {
 
 	version string
 	useRCR  bool
+
+	// from the last GET_STATUS:
+	status Status
+	// set when this version does not reply to GET_STATUS:
+	noStatus bool
+}
+
+// Status is the reply to GET_STATUS
+type Status struct {
+	// State is CONTENTLESS, PLAYING or PAUSED; empty if GET_STATUS is not supported
+	State string
+	// System is the system id of the loaded core, e.g. "super_nes"
+	System string
+	// Content is the name of the loaded content
+	Content string
+	// CRC32 of the loaded content, if reported
+	CRC32 string
+}
+
+// snesSystems are the system ids of SNES cores:
+var snesSystems = map[string]bool{
+	"super_nes": true,
+	"snes":      true,
+}
+
+func (s Status) HasContent() bool {
+	return s.State == "PLAYING" || s.State == "PAUSED"
+}
+
+// IsSNES reports whether the loaded content is for the SNES; it is assumed so when the status is unknown
+func (s Status) IsSNES() bool {
+	if !s.HasContent() {
+		return true
+	}
+	return snesSystems[s.System]
+}
+
+func parseStatus(rsp string) (s Status, err error) {
+	rsp = strings.TrimSpace(rsp)
+	if !strings.HasPrefix(rsp, "GET_STATUS ") {
+		err = fmt.Errorf("retroarch: unexpected GET_STATUS response '%s'", rsp)
+		return
+	}
+	rsp = strings.TrimPrefix(rsp, "GET_STATUS ")
+
+	// GET_STATUS PLAYING super_nes,content name,crc32=0123abcd
+	var details string
+	s.State, details, _ = strings.Cut(rsp, " ")
+	if details == "" {
+		return
+	}
+
+	s.System, details, _ = strings.Cut(details, ",")
+	s.Content = details
+	if i := strings.LastIndex(details, ",crc32="); i >= 0 {
+		s.Content = details[:i]
+		s.CRC32 = details[i+len(",crc32="):]
+	}
+	return
 }
 
 func (c *RAClient) GetId() string {
@@ -38,6 +102,7 @@ func (c *RAClient) Version() (err error) {
 
 	log.Printf("retroarch: version %s", string(rsp))
 	c.version = string(rsp)
+	c.noStatus = false
 
 	// parse the version string:
 	var n int
@@ -78,7 +143,49 @@ func (c *RAClient) Version() (err error) {
 	return
 }
 
+// GetStatus asks which core and content is loaded; only RetroArch versions with READ_CORE_MEMORY support GET_STATUS
+func (c *RAClient) GetStatus() (err error) {
+	c.status = Status{}
+	if c.useRCR || c.noStatus {
+		return
+	}
+
+	var rsp []byte
+	rsp, err = c.WriteThenReadTimeout([]byte("GET_STATUS\n"), time.Second*5)
+	if errors.Is(err, udpclient.ErrTimeout) {
+		// don't wait on this again:
+		c.noStatus = true
+		return
+	}
+	if err != nil {
+		return
+	}
+	if rsp == nil {
+		err = fmt.Errorf("retroarch: GET_STATUS: no response")
+		return
+	}
+
+	c.status, err = parseStatus(string(rsp))
+	return
+}
+
+func (c *RAClient) Status() Status {
+	return c.status
+}
+
+// ReadMemory reads from the bus address and falls back from READ_CORE_MEMORY to READ_CORE_RAM if the core has no
+// memory map for READ_CORE_MEMORY to use
 func (c *RAClient) ReadMemory(busAddr uint32, size uint8) (data []byte, err error) {
+	data, err = c.readMemory(busAddr, size)
+	if errors.Is(err, ErrNoMemoryMap) && !c.useRCR {
+		log.Printf("retroarch: %v; falling back to READ_CORE_RAM\n", err)
+		c.useRCR = true
+		data, err = c.readMemory(busAddr, size)
+	}
+	return
+}
+
+func (c *RAClient) readMemory(busAddr uint32, size uint8) (data []byte, err error) {
 	var sb strings.Builder
 	if c.useRCR {
 		sb.WriteString("READ_CORE_RAM ")
@@ -209,6 +316,15 @@ func (c *RAClient) parseReadMemoryResponse(r *bytes.Reader, expectedAddr uint32,
 		return
 	}
 
+	// READ_CORE_MEMORY replies with -1 and a reason when it cannot read:
+	var rest []byte
+	rest, _ = io.ReadAll(r)
+	if fields := strings.Fields(string(rest)); len(fields) > 0 && fields[0] == "-1" {
+		err = fmt.Errorf("retroarch: read %06x: %s: %w", addr, strings.Join(fields[1:], " "), ErrNoMemoryMap)
+		return
+	}
+	r = bytes.NewReader(rest)
+
 	data = make([]byte, 0, size)
 	for {
 		var v byte
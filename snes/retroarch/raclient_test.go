@@ -0,0 +1,83 @@
+package retroarch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		rsp    string
+		want   Status
+		isSNES bool
+	}{
+		{"GET_STATUS CONTENTLESS\n", Status{State: "CONTENTLESS"}, true},
+		{
+			"GET_STATUS PLAYING super_nes,Zelda no Densetsu, Kamigami no Triforce (Japan),crc32=3322effc\n",
+			Status{State: "PLAYING", System: "super_nes", Content: "Zelda no Densetsu, Kamigami no Triforce (Japan)", CRC32: "3322effc"},
+			true,
+		},
+		{"GET_STATUS PAUSED super_nes,alttp", Status{State: "PAUSED", System: "super_nes", Content: "alttp"}, true},
+		{"GET_STATUS PLAYING nes,Zelda,crc32=ba322865", Status{State: "PLAYING", System: "nes", Content: "Zelda", CRC32: "ba322865"}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseStatus(tt.rsp)
+		if err != nil {
+			t.Errorf("parseStatus(%q): %v", tt.rsp, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStatus(%q) = %+v, want %+v", tt.rsp, got, tt.want)
+		}
+		if got.IsSNES() != tt.isSNES {
+			t.Errorf("parseStatus(%q).IsSNES() = %v, want %v", tt.rsp, got.IsSNES(), tt.isSNES)
+		}
+	}
+
+	if _, err := parseStatus("VERSION 1.9.0"); err == nil {
+		t.Errorf("expected an error for an unexpected response")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		s           string
+		first, last int
+		ok          bool
+	}{
+		{"55355", 55355, 55355, true},
+		{"55355-55362", 55355, 55362, true},
+		{" 55355 - 55356 ", 55355, 55356, true},
+		{"55362-55355", 0, 0, false},
+		{"0-10", 0, 0, false},
+		{"abc", 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, err := parsePortRange(tt.s)
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePortRange(%q): unexpected error %v", tt.s, err)
+			continue
+		}
+		if tt.ok && (first != tt.first || last != tt.last) {
+			t.Errorf("parsePortRange(%q) = %d, %d, want %d, %d", tt.s, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestParseReadMemoryResponse(t *testing.T) {
+	c := &RAClient{}
+
+	data, err := c.parseReadMemoryResponse(bytes.NewReader([]byte("READ_CORE_MEMORY 40ffc0 01 0a ff\n")), 0x40FFC0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x01, 0x0a, 0xff}) {
+		t.Errorf("expected data 010aff, got %x", data)
+	}
+
+	_, err = c.parseReadMemoryResponse(bytes.NewReader([]byte("READ_CORE_MEMORY 40ffc0 -1 no memory map defined\n")), 0x40FFC0, 3)
+	if !errors.Is(err, ErrNoMemoryMap) {
+		t.Errorf("expected ErrNoMemoryMap, got %v", err)
+	}
+}
@@ -75,13 +75,7 @@ func (d *Driver) Open(ddg snes.DeviceDescriptor) (snes.Queue, error) {
 
 	dd := ddg.(*DeviceDescriptor)
 
-	c := &Queue{
-		memoryClient:     NewDeviceMemoryClient(d.cc),
-		filesystemClient: NewDeviceFilesystemClient(d.cc),
-		uri:              dd.Uri,
-		closed:           make(chan struct{}),
-	}
-	c.BaseInit(driverName, c)
+	c := newQueue(d.cc, dd.Uri)
 
 	return c, err
 }
@@ -1,12 +1,19 @@
 package sni
 
 import (
+	"context"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"log"
 	"o2/snes"
+	"sync/atomic"
 )
 
+// readLimits splits planned reads into batches the size of an FX Pak Pro VGET command so that SNI can work on the
+// next batch while the reply to the previous one is on its way:
+var readLimits = snes.ReadLimits{MaxBatch: 8}
+
 type Queue struct {
 	snes.BaseQueue
 
@@ -16,6 +23,66 @@ type Queue struct {
 	uri              string
 	memoryClient     DeviceMemoryClient
 	filesystemClient DeviceFilesystemClient
+
+	// persistent streams for reads and writes:
+	reads  *memoryStream[*MultiReadMemoryRequest, *MultiReadMemoryResponse]
+	writes *memoryStream[*MultiWriteMemoryRequest, *MultiWriteMemoryResponse]
+	// set when the SNI service does not support the read or write stream; read by tests so must be atomic:
+	unaryReads  atomic.Bool
+	unaryWrites atomic.Bool
+}
+
+func newQueue(cc grpc.ClientConnInterface, uri string) *Queue {
+	q := &Queue{
+		memoryClient:     NewDeviceMemoryClient(cc),
+		filesystemClient: NewDeviceFilesystemClient(cc),
+		uri:              uri,
+		closed:           make(chan struct{}),
+	}
+	q.reads = newMemoryStream(func(ctx context.Context) (streamClient[*MultiReadMemoryRequest, *MultiReadMemoryResponse], error) {
+		return q.memoryClient.StreamRead(ctx)
+	})
+	q.writes = newMemoryStream(func(ctx context.Context) (streamClient[*MultiWriteMemoryRequest, *MultiWriteMemoryResponse], error) {
+		return q.memoryClient.StreamWrite(ctx)
+	})
+	q.BaseInit(driverName, q)
+	return q
+}
+
+// multiReads pipelines the requests over the read stream or falls back to a MultiRead call per request if streams are
+// not supported
+func (q *Queue) multiReads(reqs []*MultiReadMemoryRequest) ([]*MultiReadMemoryResponse, error) {
+	if !q.unaryReads.Load() {
+		rsps, err := q.reads.DoAll(reqs)
+		if status.Code(err) != codes.Unimplemented {
+			return rsps, err
+		}
+		log.Printf("sni: StreamRead not supported; falling back to MultiRead\n")
+		q.unaryReads.Store(true)
+	}
+
+	rsps := make([]*MultiReadMemoryResponse, 0, len(reqs))
+	for _, req := range reqs {
+		rsp, err := q.memoryClient.MultiRead(context.TODO(), req)
+		if err != nil {
+			return nil, err
+		}
+		rsps = append(rsps, rsp)
+	}
+	return rsps, nil
+}
+
+// multiWrite sends the request over the write stream or falls back to a MultiWrite call if streams are not supported
+func (q *Queue) multiWrite(req *MultiWriteMemoryRequest) (*MultiWriteMemoryResponse, error) {
+	if !q.unaryWrites.Load() {
+		rsp, err := q.writes.Do(req)
+		if status.Code(err) != codes.Unimplemented {
+			return rsp, err
+		}
+		log.Printf("sni: StreamWrite not supported; falling back to MultiWrite\n")
+		q.unaryWrites.Store(true)
+	}
+	return q.memoryClient.MultiWrite(context.TODO(), req)
 }
 
 func (q *Queue) IsTerminalError(err error) bool {
@@ -41,6 +108,9 @@ func (q *Queue) Close() (err error) {
 		return
 	}
 
+	q.reads.Close()
+	q.writes.Close()
+
 	// make sure closed channel is closed:
 	close(q.closed)
 	q.isClosed = true
@@ -49,15 +119,13 @@ func (q *Queue) Close() (err error) {
 }
 
 func (q *Queue) MakeReadCommands(reqs []snes.Read, batchComplete snes.Completion) (cmds snes.CommandSequence) {
-	cmds = make(snes.CommandSequence, 0, 1)
-
-	// queue up a MultiRead command:
-	cmds = append(cmds, snes.CommandWithCompletion{
-		Command:    &multiReadCommand{reqs: reqs},
-		Completion: batchComplete,
-	})
-
-	return
+	// queue up a single command that pipelines a MultiRead request per planned batch:
+	return snes.CommandSequence{
+		snes.CommandWithCompletion{
+			Command:    &multiReadCommand{batches: snes.PlanReads(reqs, readLimits)},
+			Completion: batchComplete,
+		},
+	}
 }
 
 func (q *Queue) MakeWriteCommands(reqs []snes.Write, batchComplete snes.Completion) (cmds snes.CommandSequence) {
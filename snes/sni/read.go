@@ -1,66 +1,74 @@
 package sni
 
 import (
-	"context"
 	"fmt"
 	"o2/snes"
 )
 
 type multiReadCommand struct {
-	reqs []snes.Read
+	// planned batches of reads; each is sent as its own MultiRead request:
+	batches [][]snes.Read
 }
 
 func (m *multiReadCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive) (err error) {
 	q := queue.(*Queue)
 
-	req := MultiReadMemoryRequest{
-		Uri:      q.uri,
-		Requests: make([]*ReadMemoryRequest, len(m.reqs)),
-	}
-	for i := range m.reqs {
-		sr := &m.reqs[i]
-		req.Requests[i] = &ReadMemoryRequest{
-			RequestAddress:      sr.Address,
-			RequestAddressSpace: AddressSpace_FxPakPro,
-			// TODO: undo this hard-coding of LoROM mapping but since we only have ALTTP game right now it's ok
-			RequestMemoryMapping: MemoryMapping_LoROM,
-			Size:                 uint32(sr.Size),
+	reqs := make([]*MultiReadMemoryRequest, len(m.batches))
+	for i, batch := range m.batches {
+		req := &MultiReadMemoryRequest{
+			Uri:      q.uri,
+			Requests: make([]*ReadMemoryRequest, len(batch)),
+		}
+		for j := range batch {
+			sr := &batch[j]
+			req.Requests[j] = &ReadMemoryRequest{
+				RequestAddress:      sr.Address,
+				RequestAddressSpace: AddressSpace_FxPakPro,
+				// TODO: undo this hard-coding of LoROM mapping but since we only have ALTTP game right now it's ok
+				RequestMemoryMapping: MemoryMapping_LoROM,
+				Size:                 uint32(sr.Size),
+			}
 		}
+		reqs[i] = req
 	}
 
 	// TODO: yuck
 	keepAlive <- struct{}{}
 
-	var rsp *MultiReadMemoryResponse
-	rsp, err = q.memoryClient.MultiRead(context.TODO(), &req)
+	var rsps []*MultiReadMemoryResponse
+	rsps, err = q.multiReads(reqs)
 	if err != nil {
 		return
 	}
-	if rsp == nil {
-		err = fmt.Errorf("unexpected nil response")
-		return
-	}
-
-	for i, sp := range rsp.Responses {
-		// TODO: yuck
-		keepAlive <- struct{}{}
 
-		sr := m.reqs[i]
-		if sr.Address != sp.RequestAddress {
-			err = fmt.Errorf("mismatched address between request and response")
+	for i, rsp := range rsps {
+		if rsp == nil {
+			err = fmt.Errorf("unexpected nil response")
 			return
 		}
-		if sr.Completion == nil {
-			continue
-		}
 
-		sr.Completion(snes.Response{
-			IsWrite: false,
-			Address: sr.Address,
-			Size:    sr.Size,
-			Data:    sp.Data,
-			Extra:   sr.Extra,
-		})
+		batch := m.batches[i]
+		for j, sp := range rsp.Responses {
+			// TODO: yuck
+			keepAlive <- struct{}{}
+
+			sr := batch[j]
+			if sr.Address != sp.RequestAddress {
+				err = fmt.Errorf("mismatched address between request and response")
+				return
+			}
+			if sr.Completion == nil {
+				continue
+			}
+
+			sr.Completion(snes.Response{
+				IsWrite: false,
+				Address: sr.Address,
+				Size:    sr.Size,
+				Data:    sp.Data,
+				Extra:   sr.Extra,
+			})
+		}
 	}
 
 	return
@@ -0,0 +1,203 @@
+package sni
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// streamTimeout is how long to wait for the reply to a request sent on a stream
+const streamTimeout = time.Second * 5
+
+var (
+	ErrStreamClosed  = fmt.Errorf("sni: stream is closed")
+	ErrStreamTimeout = fmt.Errorf("sni: timed out waiting for stream reply")
+)
+
+// streamClient is a bidirectional gRPC stream client, e.g. DeviceMemory_StreamReadClient
+type streamClient[Req any, Rsp any] interface {
+	Send(Req) error
+	Recv() (Rsp, error)
+}
+
+type streamResult[Rsp any] struct {
+	rsp Rsp
+	err error
+}
+
+// memoryStream keeps a bidirectional stream open and pipelines requests over it: DoAll and concurrent calls to Do send
+// requests without waiting for the replies to earlier ones and each reply is matched to the oldest request still
+// waiting for one. After any error the stream is dropped and the next request opens a new stream.
+type memoryStream[Req any, Rsp any] struct {
+	open func(ctx context.Context) (streamClient[Req, Rsp], error)
+
+	// sendLock orders Sends the same as pending and is held across Send so that lock stays free for receive while
+	// Send is blocked on flow control:
+	sendLock sync.Mutex
+
+	lock    sync.Mutex
+	closed  bool
+	client  streamClient[Req, Rsp]
+	cancel  context.CancelFunc
+	pending []chan streamResult[Rsp]
+}
+
+func newMemoryStream[Req any, Rsp any](open func(ctx context.Context) (streamClient[Req, Rsp], error)) *memoryStream[Req, Rsp] {
+	return &memoryStream[Req, Rsp]{open: open}
+}
+
+// Do sends the request on the stream and waits for its reply; it is safe to call concurrently and requests from
+// concurrent calls are pipelined
+func (s *memoryStream[Req, Rsp]) Do(req Req) (rsp Rsp, err error) {
+	var client streamClient[Req, Rsp]
+	var result chan streamResult[Rsp]
+	client, result, err = s.send(req)
+	if err != nil {
+		return
+	}
+	return s.wait(client, result)
+}
+
+// DoAll sends all the requests on the stream before it waits for any reply so that the requests are pipelined; it
+// returns the replies in request order or the first error
+func (s *memoryStream[Req, Rsp]) DoAll(reqs []Req) (rsps []Rsp, err error) {
+	clients := make([]streamClient[Req, Rsp], 0, len(reqs))
+	results := make([]chan streamResult[Rsp], 0, len(reqs))
+	for _, req := range reqs {
+		client, result, sendErr := s.send(req)
+		if sendErr != nil {
+			err = sendErr
+			break
+		}
+		clients = append(clients, client)
+		results = append(results, result)
+	}
+
+	// wait for every reply that is owed even after an error so that none of them outlives the call:
+	rsps = make([]Rsp, 0, len(results))
+	for i, result := range results {
+		rsp, waitErr := s.wait(clients[i], result)
+		if waitErr != nil {
+			if err == nil {
+				err = waitErr
+			}
+			continue
+		}
+		rsps = append(rsps, rsp)
+	}
+	if err != nil {
+		rsps = nil
+	}
+	return
+}
+
+// send sends the request on the stream, opening it first if needed, and returns the channel its reply is delivered to
+func (s *memoryStream[Req, Rsp]) send(req Req) (client streamClient[Req, Rsp], result chan streamResult[Rsp], err error) {
+	result = make(chan streamResult[Rsp], 1)
+
+	s.sendLock.Lock()
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		s.sendLock.Unlock()
+		err = ErrStreamClosed
+		return
+	}
+	if s.client == nil {
+		// (re)open the stream:
+		ctx, cancel := context.WithCancel(context.Background())
+		client, err = s.open(ctx)
+		if err != nil {
+			cancel()
+			s.lock.Unlock()
+			s.sendLock.Unlock()
+			return
+		}
+		s.client, s.cancel = client, cancel
+		go s.receive(client)
+	}
+
+	client = s.client
+	s.pending = append(s.pending, result)
+	s.lock.Unlock()
+
+	sendErr := client.Send(req)
+	s.sendLock.Unlock()
+	if sendErr != nil {
+		// the reason the stream broke comes from Recv but don't count on it; reset delivers the error to result:
+		s.lock.Lock()
+		s.reset(client, sendErr)
+		s.lock.Unlock()
+	}
+	return
+}
+
+// wait waits for the reply to a request sent on the stream by send
+func (s *memoryStream[Req, Rsp]) wait(client streamClient[Req, Rsp], result chan streamResult[Rsp]) (Rsp, error) {
+	timer := time.NewTimer(streamTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-result:
+		return r.rsp, r.err
+	case <-timer.C:
+		s.lock.Lock()
+		s.reset(client, ErrStreamTimeout)
+		s.lock.Unlock()
+		// reset either delivered the timeout or a reply raced in ahead of it:
+		r := <-result
+		return r.rsp, r.err
+	}
+}
+
+// receive delivers replies from the stream to the waiting requests in order
+func (s *memoryStream[Req, Rsp]) receive(client streamClient[Req, Rsp]) {
+	for {
+		rsp, err := client.Recv()
+
+		s.lock.Lock()
+		if s.client != client {
+			// this stream was already dropped:
+			s.lock.Unlock()
+			return
+		}
+		if err == nil && len(s.pending) == 0 {
+			err = fmt.Errorf("sni: unexpected stream reply with no request waiting")
+		}
+		if err != nil {
+			s.reset(client, err)
+			s.lock.Unlock()
+			return
+		}
+
+		result := s.pending[0]
+		s.pending = s.pending[1:]
+		s.lock.Unlock()
+
+		result <- streamResult[Rsp]{rsp: rsp}
+	}
+}
+
+// reset drops the stream and fails all waiting requests with err; must be called with the lock held
+func (s *memoryStream[Req, Rsp]) reset(client streamClient[Req, Rsp], err error) {
+	if s.client != client {
+		return
+	}
+
+	s.cancel()
+	s.client, s.cancel = nil, nil
+	for _, result := range s.pending {
+		result <- streamResult[Rsp]{err: err}
+	}
+	s.pending = nil
+}
+
+func (s *memoryStream[Req, Rsp]) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.client != nil {
+		s.reset(s.client, ErrStreamClosed)
+	}
+}
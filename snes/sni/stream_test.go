@@ -0,0 +1,419 @@
+package sni
+
+import (
+	"bytes"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"o2/snes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeMemoryServer serves reads with each byte being the low byte of its address
+type fakeMemoryServer struct {
+	UnimplementedDeviceMemoryServer
+
+	noReadStream  bool
+	noWriteStream bool
+
+	// number of streams opened and the number of stream replies before the next stream breaks; 0 never breaks:
+	streams    atomic.Int32
+	breakAfter atomic.Int32
+	// number of requests the next stream receives before it replies to any of them:
+	holdReplies atomic.Int32
+
+	lock   sync.Mutex
+	memory map[uint32]byte
+}
+
+func (s *fakeMemoryServer) read(reqs []*ReadMemoryRequest) *MultiReadMemoryResponse {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rsp := &MultiReadMemoryResponse{Responses: make([]*ReadMemoryResponse, len(reqs))}
+	for i, req := range reqs {
+		data := make([]byte, req.Size)
+		for j := range data {
+			address := req.RequestAddress + uint32(j)
+			if v, ok := s.memory[address]; ok {
+				data[j] = v
+			} else {
+				data[j] = byte(address)
+			}
+		}
+		rsp.Responses[i] = &ReadMemoryResponse{RequestAddress: req.RequestAddress, Data: data}
+	}
+	return rsp
+}
+
+func (s *fakeMemoryServer) write(reqs []*WriteMemoryRequest) *MultiWriteMemoryResponse {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rsp := &MultiWriteMemoryResponse{Responses: make([]*WriteMemoryResponse, len(reqs))}
+	for i, req := range reqs {
+		for j, v := range req.Data {
+			s.memory[req.RequestAddress+uint32(j)] = v
+		}
+		rsp.Responses[i] = &WriteMemoryResponse{RequestAddress: req.RequestAddress, Size: uint32(len(req.Data))}
+	}
+	return rsp
+}
+
+func (s *fakeMemoryServer) MultiRead(ctx context.Context, req *MultiReadMemoryRequest) (*MultiReadMemoryResponse, error) {
+	return s.read(req.Requests), nil
+}
+
+func (s *fakeMemoryServer) MultiWrite(ctx context.Context, req *MultiWriteMemoryRequest) (*MultiWriteMemoryResponse, error) {
+	return s.write(req.Requests), nil
+}
+
+func (s *fakeMemoryServer) StreamRead(stream DeviceMemory_StreamReadServer) error {
+	if s.noReadStream {
+		return s.UnimplementedDeviceMemoryServer.StreamRead(stream)
+	}
+
+	s.streams.Add(1)
+	replies := s.breakAfter.Swap(0)
+	hold := s.holdReplies.Swap(0)
+	held := make([]*MultiReadMemoryRequest, 0, hold)
+	for n := int32(1); ; n++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n == replies+1 && replies > 0 {
+			return status.Error(codes.Unavailable, "stream broke")
+		}
+		if n <= hold {
+			held = append(held, req)
+			if n < hold {
+				continue
+			}
+		} else {
+			held = append(held[:0], req)
+		}
+		for _, req := range held {
+			if err = stream.Send(s.read(req.Requests)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *fakeMemoryServer) StreamWrite(stream DeviceMemory_StreamWriteServer) error {
+	if s.noWriteStream {
+		return s.UnimplementedDeviceMemoryServer.StreamWrite(stream)
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(s.write(req.Requests)); err != nil {
+			return err
+		}
+	}
+}
+
+// newFakeSNI starts an in-process SNI service and returns a connection to it
+func newFakeSNI(tb testing.TB, srv *fakeMemoryServer) *grpc.ClientConn {
+	srv.memory = make(map[uint32]byte)
+
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterDeviceMemoryServer(s, srv)
+	go func() { _ = s.Serve(ln) }()
+	tb.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = cc.Close() })
+	return cc
+}
+
+func newTestQueue(tb testing.TB, srv *fakeMemoryServer) *Queue {
+	q := newQueue(newFakeSNI(tb, srv), "fake://device")
+	tb.Cleanup(func() { _ = q.Close() })
+	return q
+}
+
+// run enqueues the commands and waits for them all to complete
+func run(tb testing.TB, q *Queue, seq snes.CommandSequence) (errs []error) {
+	done := make(chan error, len(seq))
+	for i := range seq {
+		seq[i].Completion = func(cmd snes.Command, err error) { done <- err }
+	}
+	if err := seq.EnqueueTo(q); err != nil {
+		tb.Fatal(err)
+	}
+	for range seq {
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-time.After(5 * time.Second):
+			tb.Fatal("timed out waiting for commands")
+		}
+	}
+	return
+}
+
+func readAll(tb testing.TB, q *Queue, reqs []snes.Read) [][]byte {
+	data := make([][]byte, len(reqs))
+	for i := range reqs {
+		i := i
+		reqs[i].Completion = func(rsp snes.Response) { data[i] = rsp.Data }
+	}
+	if errs := run(tb, q, q.MakeReadCommands(reqs, nil)); len(errs) > 0 {
+		tb.Fatal(errs)
+	}
+	return data
+}
+
+func expectedData(address uint32, size uint8) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(address + uint32(i))
+	}
+	return data
+}
+
+func TestQueue_Stream(t *testing.T) {
+	srv := &fakeMemoryServer{}
+	q := newTestQueue(t, srv)
+
+	errs := run(t, q, q.MakeWriteCommands([]snes.Write{
+		{Address: 0xF5_0010, Size: 2, Data: []byte{0x07, 0x01}},
+	}, nil))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	for frame := 0; frame < 3; frame++ {
+		data := readAll(t, q, []snes.Read{
+			{Address: 0xF5_0010, Size: 0xF0},
+			{Address: 0xE0_0000, Size: 0x10},
+		})
+		expected := expectedData(0xF5_0010, 0xF0)
+		expected[0], expected[1] = 0x07, 0x01
+		if !bytes.Equal(expected, data[0]) {
+			t.Errorf("expected %x, got %x", expected, data[0])
+		}
+		if expected := expectedData(0xE0_0000, 0x10); !bytes.Equal(expected, data[1]) {
+			t.Errorf("expected %x, got %x", expected, data[1])
+		}
+	}
+
+	// all reads went over a single stream:
+	if expected, actual := int32(1), srv.streams.Load(); expected != actual {
+		t.Errorf("expected %d read stream, got %d", expected, actual)
+	}
+	if q.unaryReads.Load() || q.unaryWrites.Load() {
+		t.Errorf("expected streams to be used")
+	}
+}
+
+func TestQueue_StreamReconnect(t *testing.T) {
+	srv := &fakeMemoryServer{}
+	q := newTestQueue(t, srv)
+
+	srv.breakAfter.Store(1)
+	readAll(t, q, []snes.Read{{Address: 0xF5_0000, Size: 1}})
+
+	// the stream breaks on the second read:
+	errs := run(t, q, q.MakeReadCommands([]snes.Read{{Address: 0xF5_0000, Size: 1}}, nil))
+	if len(errs) != 1 || status.Code(errs[0]) != codes.Unavailable {
+		t.Fatalf("expected an Unavailable error, got %v", errs)
+	}
+	if q.IsTerminalError(errs[0]) {
+		t.Errorf("expected a broken stream to not be terminal")
+	}
+
+	// and is reopened for the next read:
+	data := readAll(t, q, []snes.Read{{Address: 0xF5_0123, Size: 2}})
+	if expected := expectedData(0xF5_0123, 2); !bytes.Equal(expected, data[0]) {
+		t.Errorf("expected %x, got %x", expected, data[0])
+	}
+	if expected, actual := int32(2), srv.streams.Load(); expected != actual {
+		t.Errorf("expected %d read streams, got %d", expected, actual)
+	}
+}
+
+func TestQueue_PipelinesBatches(t *testing.T) {
+	srv := &fakeMemoryServer{}
+	q := newTestQueue(t, srv)
+
+	// the stream only replies once it has received every batch, so the batches must all be in flight at once:
+	reqs := frameReads()
+	batches := snes.PlanReads(reqs, readLimits)
+	if len(batches) < 2 {
+		t.Fatalf("expected multiple batches, got %d", len(batches))
+	}
+	srv.holdReplies.Store(int32(len(batches)))
+
+	data := readAll(t, q, reqs)
+	for i, req := range reqs {
+		if expected := expectedData(req.Address, req.Size); !bytes.Equal(expected, data[i]) {
+			t.Errorf("read %d: expected %x, got %x", i, expected, data[i])
+		}
+	}
+}
+
+func TestQueue_UnaryFallback(t *testing.T) {
+	srv := &fakeMemoryServer{noReadStream: true, noWriteStream: true}
+	q := newTestQueue(t, srv)
+
+	reqs := frameReads()
+	data := readAll(t, q, reqs)
+	for i, req := range reqs {
+		if expected := expectedData(req.Address, req.Size); !bytes.Equal(expected, data[i]) {
+			t.Errorf("read %d: expected %x, got %x", i, expected, data[i])
+		}
+	}
+	if !q.unaryReads.Load() {
+		t.Errorf("expected reads to fall back to unary calls")
+	}
+}
+
+func TestQueue_UnaryFallbackWritesOnly(t *testing.T) {
+	srv := &fakeMemoryServer{noWriteStream: true}
+	q := newTestQueue(t, srv)
+
+	errs := run(t, q, q.MakeWriteCommands([]snes.Write{
+		{Address: 0xF5_0200, Size: 1, Data: []byte{0x55}},
+	}, nil))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	data := readAll(t, q, []snes.Read{{Address: 0xF5_0200, Size: 1}})
+	if expected := []byte{0x55}; !bytes.Equal(expected, data[0]) {
+		t.Errorf("expected %x, got %x", expected, data[0])
+	}
+
+	// a missing write stream must not force reads off their stream:
+	if !q.unaryWrites.Load() {
+		t.Errorf("expected writes to fall back to unary calls")
+	}
+	if q.unaryReads.Load() {
+		t.Errorf("expected reads to stay on the stream")
+	}
+	if expected, actual := int32(1), srv.streams.Load(); expected != actual {
+		t.Errorf("expected %d read stream, got %d", expected, actual)
+	}
+}
+
+func TestMemoryStream_Pipelined(t *testing.T) {
+	srv := &fakeMemoryServer{}
+	q := newTestQueue(t, srv)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(address uint32) {
+			defer wg.Done()
+			rsp, err := q.reads.Do(&MultiReadMemoryRequest{Requests: []*ReadMemoryRequest{{RequestAddress: address, Size: 4}}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if actual := rsp.Responses[0].RequestAddress; actual != address {
+				t.Errorf("expected reply for $%06x, got $%06x", address, actual)
+			}
+		}(0xF5_0000 + uint32(i)*0x10)
+	}
+	wg.Wait()
+}
+
+// frameReads is a typical list of reads for a frame that reads SRAM
+func frameReads() []snes.Read {
+	return []snes.Read{
+		{Address: 0xF5_F000, Size: 0xFE},
+		{Address: 0xF5_F0FE, Size: 0xFE},
+		{Address: 0xF5_F1FC, Size: 0x54},
+		{Address: 0xF5_F280, Size: 0xC0},
+		{Address: 0xF5_0100, Size: 0x36},
+		{Address: 0xF5_02E0, Size: 0x08},
+		{Address: 0xF5_0400, Size: 0x20},
+		{Address: 0xF5_1980, Size: 0x6A},
+		{Address: 0xF5_F340, Size: 0xFF},
+		{Address: 0xF5_F43F, Size: 0xC1},
+		{Address: 0xF5_C6E0, Size: 0x20},
+		{Address: 0xF5_0010, Size: 0xF0},
+	}
+}
+
+// frameRequests plans frameReads into a MultiRead request per batch
+func frameRequests() []*MultiReadMemoryRequest {
+	batches := snes.PlanReads(frameReads(), readLimits)
+	reqs := make([]*MultiReadMemoryRequest, len(batches))
+	for i, batch := range batches {
+		reqs[i] = &MultiReadMemoryRequest{Uri: "fake://device"}
+		for _, rd := range batch {
+			reqs[i].Requests = append(reqs[i].Requests, &ReadMemoryRequest{RequestAddress: rd.Address, Size: uint32(rd.Size)})
+		}
+	}
+	return reqs
+}
+
+func BenchmarkMultiRead_Unary(b *testing.B) {
+	q := newTestQueue(b, &fakeMemoryServer{})
+	reqs := frameRequests()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			if _, err := q.memoryClient.MultiRead(context.Background(), req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMultiRead_Stream(b *testing.B) {
+	q := newTestQueue(b, &fakeMemoryServer{})
+	reqs := frameRequests()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			if _, err := q.reads.Do(req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMultiRead_StreamPipelined(b *testing.B) {
+	q := newTestQueue(b, &fakeMemoryServer{})
+	reqs := frameRequests()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := q.reads.DoAll(reqs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package sni
 
 import (
-	"context"
 	"fmt"
 	"o2/snes"
 )
@@ -32,7 +31,7 @@ func (m *multiWriteCommand) Execute(queue snes.Queue, keepAlive snes.KeepAlive)
 	keepAlive <- struct{}{}
 
 	var rsp *MultiWriteMemoryResponse
-	rsp, err = q.memoryClient.MultiWrite(context.TODO(), &req)
+	rsp, err = q.multiWrite(&req)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,266 @@
+package streamclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"io"
+	"log"
+	"net"
+	"o2/util"
+	"sync"
+	"time"
+)
+
+// MaxFrameSize is the largest packet that can be carried in a length-prefixed frame
+const MaxFrameSize = 0xFFFF
+
+var ErrFrameTooLarge = errors.New("frame too large")
+var ErrUnsupportedNetwork = errors.New("unsupported network")
+
+// WriteFrame writes b prefixed by its uint16 little-endian length
+func WriteFrame(w io.Writer, b []byte) (err error) {
+	if len(b) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	frame := make([]byte, 2+len(b))
+	binary.LittleEndian.PutUint16(frame, uint16(len(b)))
+	copy(frame[2:], b)
+
+	_, err = w.Write(frame)
+	return
+}
+
+// ReadFrame reads a single uint16 little-endian length-prefixed frame
+func ReadFrame(r io.Reader) (b []byte, err error) {
+	var size uint16
+	if err = binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return
+	}
+
+	b = make([]byte, size)
+	_, err = io.ReadFull(r, b)
+	return
+}
+
+// StreamClient carries packets over a TCP stream, either length-prefixed ("tcp") or as binary WebSocket
+// messages ("ws"). It mirrors the channel-based API of udpclient.UDPClient.
+type StreamClient struct {
+	name string
+
+	network string
+	c       net.Conn
+
+	muteLog bool
+
+	lock        sync.Mutex
+	writeLock   sync.Mutex
+	isConnected bool
+	read        chan []byte
+	write       chan []byte
+}
+
+func NewStreamClient(name string) *StreamClient {
+	return &StreamClient{
+		name:  name,
+		read:  make(chan []byte, 64),
+		write: make(chan []byte, 64),
+	}
+}
+
+func (c *StreamClient) MuteLog(muted bool) {
+	c.muteLog = muted
+}
+
+func (c *StreamClient) Write() chan<- []byte { return c.write }
+func (c *StreamClient) Read() <-chan []byte  { return c.read }
+
+func (c *StreamClient) IsConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.isConnected
+}
+
+func (c *StreamClient) log(fmt string, args ...interface{}) {
+	if c.muteLog {
+		return
+	}
+	log.Printf(fmt, args...)
+}
+
+// Connect dials the server at address (host:port) using network "tcp" or "ws"
+func (c *StreamClient) Connect(network string, address string) (err error) {
+	c.log("%s: connect to server '%s://%s'\n", c.name, network, address)
+
+	if c.IsConnected() {
+		return fmt.Errorf("%s: already connected", c.name)
+	}
+
+	var conn net.Conn
+	var br *bufio.Reader
+	switch network {
+	case "tcp":
+		conn, err = net.DialTimeout("tcp", address, 10*time.Second)
+	case "ws":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		conn, br, _, err = ws.Dial(ctx, "ws://"+address+"/")
+	default:
+		err = fmt.Errorf("%s: %w '%s'", c.name, ErrUnsupportedNetwork, network)
+	}
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	c.network = network
+	c.c = conn
+	c.isConnected = true
+	c.lock.Unlock()
+
+	c.log("%s: connected to server '%s://%s'\n", c.name, network, address)
+
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+
+	go c.readLoop(conn, br)
+	go c.writeLoop(conn)
+
+	return
+}
+
+func (c *StreamClient) Disconnect() {
+	c.lock.Lock()
+	if !c.isConnected {
+		c.lock.Unlock()
+		return
+	}
+	c.isConnected = false
+	conn := c.c
+	c.c = nil
+	c.lock.Unlock()
+
+	c.log("%s: disconnect from server '%s'\n", c.name, conn.RemoteAddr())
+
+	// close the underlying connection to unblock readLoop:
+	if err := conn.Close(); err != nil {
+		c.log("%s: close: %v\n", c.name, err)
+	}
+
+	// signal a disconnect took place:
+	c.read <- nil
+	c.write <- nil
+
+	c.log("%s: disconnected from server '%s'\n", c.name, conn.RemoteAddr())
+}
+
+func (c *StreamClient) Close() {
+	if c.read != nil {
+		close(c.read)
+	}
+	if c.write != nil {
+		close(c.write)
+	}
+	c.read = nil
+	c.write = nil
+}
+
+// lockedReadWriter serializes control frame replies written by wsutil with packets written by writeLoop
+type lockedReadWriter struct {
+	io.Reader
+	w    io.Writer
+	lock *sync.Mutex
+}
+
+func (rw lockedReadWriter) Write(p []byte) (int, error) {
+	rw.lock.Lock()
+	defer rw.lock.Unlock()
+	return rw.w.Write(p)
+}
+
+func (c *StreamClient) readPacket(rw io.ReadWriter) (b []byte, err error) {
+	if c.network != "ws" {
+		return ReadFrame(rw)
+	}
+
+	for {
+		var op ws.OpCode
+		b, op, err = wsutil.ReadServerData(rw)
+		if err != nil {
+			return
+		}
+		if op == ws.OpBinary {
+			return
+		}
+	}
+}
+
+func (c *StreamClient) writePacket(conn net.Conn, b []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.network != "ws" {
+		return WriteFrame(conn, b)
+	}
+	return wsutil.WriteClientBinary(conn, b)
+}
+
+// must run in a goroutine
+func (c *StreamClient) readLoop(conn net.Conn, br *bufio.Reader) {
+	c.log("%s: readLoop started\n", c.name)
+
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+
+		c.Disconnect()
+		c.log("%s: disconnected; readLoop exited\n", c.name)
+	}()
+
+	rw := lockedReadWriter{Reader: br, w: conn, lock: &c.writeLock}
+	for c.IsConnected() {
+		b, err := c.readPacket(rw)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				c.log("%s: read: %s\n", c.name, err)
+			}
+			return
+		}
+
+		c.read <- b
+	}
+}
+
+// must run in a goroutine
+func (c *StreamClient) writeLoop(conn net.Conn) {
+	c.log("%s: writeLoop started\n", c.name)
+
+	defer func() {
+		if err := recover(); err != nil {
+			util.LogPanic(err)
+		}
+
+		c.Disconnect()
+		c.log("%s: disconnected; writeLoop exited\n", c.name)
+	}()
+
+	for w := range c.write {
+		if w == nil {
+			return
+		}
+
+		if err := c.writePacket(conn, w); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				c.log("%s: write: %s\n", c.name, err)
+			}
+			return
+		}
+	}
+}
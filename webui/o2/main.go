@@ -5,6 +5,7 @@ import (
 	"github.com/skratchdot/open-golang/open"
 	"log"
 	"net"
+	"o2/client"
 	"o2/engine"
 	"o2/util"
 	"o2/util/env"
@@ -17,8 +18,10 @@ import (
 
 // include these SNES drivers:
 import (
+	_ "o2/snes/emulated"
 	_ "o2/snes/fxpakpro"
 	_ "o2/snes/mock"
+	_ "o2/snes/nwa"
 	_ "o2/snes/qusb2snes"
 	_ "o2/snes/retroarch"
 	_ "o2/snes/sni"
@@ -102,6 +105,7 @@ func main() {
 	// construct our viewModel:
 	viewModel := engine.NewViewModel()
 	viewModel.SetViewModel("o2", &O2ViewModel{Version: version})
+	client.Version = version
 
 	// construct the web server:
 	webServer := NewWebServer(listenAddr)
@@ -1,4 +1,4 @@
-import {GameALTTPViewModel, GameViewProps, TimestampedNotification} from "../viewmodel";
+import {GameALTTPViewModel, GameViewProps, NetworkViewModel, TimestampedNotification} from "../viewmodel";
 import {useEffect, useRef, useState} from "preact/hooks";
 import {Fragment} from "preact";
 import {setField} from "../util";
@@ -20,6 +20,8 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
     const [syncOverworld, setsyncOverworld] = useState(true);
     const [syncChests, setsyncChests] = useState(true);
     const [syncTunicColor, setsyncTunicColor] = useState(true);
+    const [reliableDelivery, setreliableDelivery] = useState(false);
+    const [captureNetwork, setcaptureNetwork] = useState(false);
 
     const [notifHistory, setNotifHistory] = useState([] as TimestampedNotification[]);
     const historyTextarea = useRef(null);
@@ -29,6 +31,11 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
 
     const [runTimer, setrunTimer] = useState("");
 
+    const [chatText, set_chatText] = useState("");
+
+    const [showNetwork, set_showNetwork] = useState(false);
+    const network = vm.network as NetworkViewModel;
+
     useEffect(() => {
         setplayerColor(game.playerColor);
         const blu5 = (game.playerColor & 0x7E00) >> 10;
@@ -47,6 +54,8 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
         setsyncOverworld(game.syncOverworld);
         setsyncChests(game.syncChests);
         setsyncTunicColor(game.syncTunicColor);
+        setreliableDelivery(game.reliableDelivery);
+        setcaptureNetwork(game.captureNetwork);
     }, [game]);
 
     useEffect(() => {
@@ -67,6 +76,11 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
 
     const sendGameCommand = ch.command.bind(ch, "game");
 
+    const sendChat = (command: string) => {
+        sendGameCommand(command, {text: chatText});
+        set_chatText("");
+    };
+
     const getTargetChecked = (e: Event) => (e.target as HTMLInputElement).checked;
 
     // BGR order from MSB to LSB, 0bbbbbgggggrrrrr
@@ -238,6 +252,24 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
                            onChange={setField.bind(this, sendGameCommand, setsyncChests, "syncChests", getTargetChecked)}
                     />Sync Chests
                 </label></div>
+
+                <div><label for="reliableDelivery"
+                       title="Acknowledge and retransmit item and progress updates sent to other players">
+                    <input type="checkbox"
+                           id="reliableDelivery"
+                           checked={reliableDelivery}
+                           onChange={setField.bind(this, sendGameCommand, setreliableDelivery, "reliableDelivery", getTargetChecked)}
+                    />Reliable Delivery
+                </label></div>
+
+                <div><label for="captureNetwork"
+                       title="Record all network packets to a file in the captures folder for offline replay">
+                    <input type="checkbox"
+                           id="captureNetwork"
+                           checked={captureNetwork}
+                           onChange={setField.bind(this, sendGameCommand, setcaptureNetwork, "captureNetwork", getTargetChecked)}
+                    />Capture Network
+                </label></div>
             </div>
         </div>
         <div style="grid-row: 1; grid-column: 2; display: flex">
@@ -257,7 +289,9 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
                     <div class="mono" title="Player index">{("00" + p.index.toString(16).toUpperCase()).slice(-3)}</div>
                     <div class="mono" title="Team number">{p.team}</div>
                     <div style={"color: " + hexrgb24(bgr16torgb24(p.color)) + "; white-space: nowrap"}
-                         title="Player name">{p.name}</div>
+                         title={p.warning ? "Warning: " + p.warning : (p.items?.length ? p.items.join(", ") : "Player name")}>{p.name}{
+                        p.warning && <span style="color: orange"> &#x26A0;</span>
+                    }</div>
                     <div
                         style={"color: " + (((p.location & 0x10000) != 0) ? "green" : "cyan") + "; white-space: nowrap"}
                         title="Location">{
@@ -291,6 +325,51 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
                 >Execute
                 </button>
 
+                <div style="grid-column: 1 / span 2">
+                    <label for="showNetwork">
+                        <input type="checkbox"
+                               id="showNetwork"
+                               checked={showNetwork}
+                               onChange={e => set_showNetwork((e.target as HTMLInputElement).checked)}
+                        />Network Stats
+                    </label>
+                </div>
+                {showNetwork && network && (
+                    <div class="mono"
+                         style="grid-column: 1 / span 2; display: grid; grid-template-columns: 2fr 1fr 1fr 1fr 1fr; grid-column-gap: 0.5em; font-size: 0.8rem">
+                        <div style="grid-column: 1 / span 5">
+                            RTT: {network.hasRtt ? `${network.rttMs.toFixed(0)} ms ± ${network.jitterMs.toFixed(0)} ms` : "N/A"}
+                            &nbsp;&ndash;&nbsp;loss: {(network.packetLoss * 100).toFixed(0)}%
+                            &nbsp;&ndash;&nbsp;<span title="Malformed packets dropped">bad: {network.badPackets}</span>
+                        </div>
+                        <div style="grid-column: 1 / span 5" title="Estimated server clock minus local clock">
+                            clock: {network.hasClock ? `${network.clockOffsetMs.toFixed(0)} ms ± ${network.clockErrorMs.toFixed(0)} ms, drift ${network.clockDriftPpm.toFixed(1)} ppm` : "N/A"}
+                        </div>
+                        <div style="font-weight: bold; text-decoration: underline">type</div>
+                        <div style="font-weight: bold; text-decoration: underline" title="Packets sent per second">tx/s</div>
+                        <div style="font-weight: bold; text-decoration: underline" title="Bytes sent per second">tx B/s</div>
+                        <div style="font-weight: bold; text-decoration: underline" title="Messages received per second">rx/s</div>
+                        <div style="font-weight: bold; text-decoration: underline" title="Bytes received per second">rx B/s</div>
+                        <div>total</div>
+                        <div>{network.sentPacketsPerSecond.toFixed(1)}</div>
+                        <div>{network.sentBytesPerSecond.toFixed(0)}</div>
+                        <div>{network.recvPacketsPerSecond.toFixed(1)}</div>
+                        <div>{network.recvBytesPerSecond.toFixed(0)}</div>
+                        {(network.messageTypes || []).map(t => (<Fragment key={t.name}>
+                            <div>{t.name}</div>
+                            <div>{t.sentPacketsPerSecond.toFixed(1)}</div>
+                            <div>{t.sentBytesPerSecond.toFixed(0)}</div>
+                            <div>{t.recvPacketsPerSecond.toFixed(1)}</div>
+                            <div>{t.recvBytesPerSecond.toFixed(0)}</div>
+                        </Fragment>))}
+                        {(network.players || []).map(p => (<Fragment key={p.index.toString()}>
+                            <div style="grid-column: 1 / span 2">{p.name}</div>
+                            <div title="Malformed packets dropped from player">{p.badPackets > 0 ? `${p.badPackets} bad` : ""}</div>
+                            <div style="grid-column: 4 / span 2" title="Time since last message from player">{p.lastSeenMs} ms</div>
+                        </Fragment>))}
+                    </div>
+                )}
+
                 <div style="grid-column: 1 / span 2; margin-top: 0.5em">
                     updates:
                 </div>
@@ -302,6 +381,21 @@ export function GameViewALTTP({ch, vm}: GameViewProps) {
                         </Fragment>))}
                     </div>
                 </div>
+                <div style="grid-column: 1 / span 2; display: grid; grid-template-columns: 6fr 1fr 1fr;">
+                    <input type="text"
+                           maxLength={200}
+                           placeholder="chat"
+                           value={chatText}
+                           onInput={e => set_chatText((e.target as HTMLInputElement).value)}
+                           onKeyDown={e => { if (e.key === "Enter" && chatText.trim() !== "") { sendChat("sendChat"); } }}
+                    />
+                    <button type="button"
+                            disabled={chatText.trim() === ""}
+                            onClick={e => sendChat("sendChat")}>Send</button>
+                    <button type="button"
+                            title="Ping your current location to the other players"
+                            onClick={e => sendChat("ping")}>Ping</button>
+                </div>
             </div>
         </div>
     </div>;
@@ -15,14 +15,27 @@ function ServerView({ch, server}: ServerProps) {
     const [groupName, setGroupName] = useState('');
     const [playerName, setPlayerName] = useState('');
     const [team, setTeam] = useState(0);
+    const [groupSecret, setGroupSecret] = useState('');
 
     useEffect(() => {
         setHostName(server?.hostName);
         setGroupName(server?.groupName);
         setPlayerName(server?.playerName);
         setTeam(server?.team);
+        setGroupSecret(server?.groupSecret);
     }, [server]);
 
+    // tick once a second while retrying to count down to the next reconnect attempt:
+    const [now, setNow] = useState(Date.now());
+    useEffect(() => {
+        if (server?.connectionState !== "retrying") {
+            return;
+        }
+        setNow(Date.now());
+        const timer = setInterval(() => setNow(Date.now()), 1000);
+        return () => clearInterval(timer);
+    }, [server?.connectionState, server?.retryAt]);
+
     // NOTE: `ch` can be null during app init
     const sendServerCommand = ch?.command?.bind(ch, "server");
 
@@ -36,8 +49,26 @@ function ServerView({ch, server}: ServerProps) {
         sendServerCommand('disconnect', {});
     };
 
+    const cmdSpectate = (e: Event) => {
+        e.preventDefault();
+        sendServerCommand(server?.isSpectating ? 'stopSpectating' : 'spectate', {});
+    };
+
+    const connectionStatus = () => {
+        switch (server?.connectionState) {
+            case "connecting":
+                return "Connecting...";
+            case "retrying":
+                return `Connection lost; retrying in ${Math.max(0, Math.ceil((server.retryAt - now) / 1000))} s`;
+            case "connected":
+                return "Connected";
+            default:
+                return "Disconnected";
+        }
+    };
+
     const connectButton = () => {
-        if (server?.isConnected) {
+        if (server?.isConnected || server?.connectionState === "retrying") {
             return <button type="button"
                            style="grid-column: 1 / span 2"
                            onClick={cmdDisconnect.bind(this)}>Disconnect</button>;
@@ -61,7 +92,7 @@ and enter a group name you wish to join. Groups are created on the fly by whoeve
         <input type="text"
                value={hostName}
                disabled={server?.isConnected}
-               title="Connect to an O2 server (default is `alttp.online`)"
+               title="Connect to an O2 server (default is `alttp.online`); prefix with `tcp://` or `ws://` if your network blocks UDP; enter `lan://` to play with peers on your local network without a server"
                id="hostName"
                onInput={setField.bind(this, sendServerCommand, setHostName, "hostName", getTargetValueString)}/>
         <label for="groupName">Group:</label>
@@ -70,6 +101,13 @@ and enter a group name you wish to join. Groups are created on the fly by whoeve
                title="A group name uniquely identifies the group of players you wish to sync items and progress with; max 20 characters, case-insensitive, leading and trailing whitespace are trimmed"
                id="groupName"
                onInput={setField.bind(this, sendServerCommand, setGroupName, "groupName", getTargetValueString)}/>
+        <label for="groupSecret">Group Secret:</label>
+        <input type="password"
+               value={groupSecret}
+               title={"Optional password shared by all players in the group; messages from players without the same secret are dropped" +
+                   (server?.droppedPackets ? ` (${server.droppedPackets} dropped)` : "")}
+               id="groupSecret"
+               onInput={setField.bind(this, sendServerCommand, setGroupSecret, "groupSecret", getTargetValueString)}/>
 
         <label for="playerName">Player Name:</label>
         <input type="text"
@@ -87,6 +125,11 @@ and enter a group name you wish to join. Groups are created on the fly by whoeve
                onInput={setField.bind(this, sendServerCommand, setTeam, "team", getTargetValueInt)}/>
 
         {connectButton()}
+        <button type="button"
+                style="grid-column: 1 / span 2"
+                title="Watch the group's players without a ROM or SNES; nothing is synced to or from the group"
+                onClick={cmdSpectate.bind(this)}>{server?.isSpectating ? "Stop Spectating" : "Spectate"}</button>
+        <div style="grid-column: 1 / span 2">{connectionStatus()}</div>
     </div>;
 }
 
@@ -10,6 +10,7 @@ export interface ViewModel {
     rom?: ROMViewModel;
     server?: ServerViewModel;
     game?: GameViewModel;
+    network?: NetworkViewModel;
 }
 
 export interface TimestampedNotification {
@@ -56,11 +57,53 @@ export interface ROMViewModel {
 
 export interface ServerViewModel {
     isConnected: boolean;
+    connectionState: string;
+    // time of the next reconnect attempt while retrying, in milliseconds since the Unix epoch:
+    retryAt: number;
 
     hostName: string;
     groupName: string;
     playerName: string;
     team: number;
+    groupSecret: string;
+    droppedPackets: number;
+    isSpectating: boolean;
+}
+
+export interface NetworkViewModel {
+    hasRtt: boolean;
+    rttMs: number;
+    jitterMs: number;
+    hasClock: boolean;
+    clockOffsetMs: number;
+    clockErrorMs: number;
+    clockDriftPpm: number;
+    packetLoss: number;
+
+    sentPacketsPerSecond: number;
+    sentBytesPerSecond: number;
+    recvPacketsPerSecond: number;
+    recvBytesPerSecond: number;
+    badPackets: number;
+
+    messageTypes: MessageTypeStatsViewModel[];
+    players: PlayerNetworkViewModel[];
+}
+
+export interface MessageTypeStatsViewModel {
+    name: string;
+
+    sentPacketsPerSecond: number;
+    sentBytesPerSecond: number;
+    recvPacketsPerSecond: number;
+    recvBytesPerSecond: number;
+}
+
+export interface PlayerNetworkViewModel {
+    index: number;
+    name: string;
+    lastSeenMs: number;
+    badPackets: number;
 }
 
 export interface GameViewModel {
@@ -79,6 +122,8 @@ export interface GameALTTPViewModel extends GameViewModel {
     syncOverworld: boolean;
     syncChests: boolean;
     syncTunicColor: boolean;
+    reliableDelivery: boolean;
+    captureNetwork: boolean;
 }
 
 export type GameViewProps = {